@@ -0,0 +1,49 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// EnableAdmin registers the /admin endpoints on the mux. Requests to them must
+// include token as a bearer token in the Authorization header.
+func (s *SearchApp) EnableAdmin(token string) {
+	s.mux.HandleFunc("/admin/cache", s.requireToken(token, s.AdminCache))
+}
+
+// requireToken wraps h so that it's only called for requests that include
+// token as a bearer token, i.e. an Authorization header of "Bearer " and the
+// token. Every request is refused if token is empty.
+func (s *SearchApp) requireToken(token string, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		given, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || token == "" || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		h(w, r)
+	}
+}
+
+// AdminCache handles requests to /admin/cache. GET returns the cache stats and
+// DELETE flushes the cache.
+func (s *SearchApp) AdminCache(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		jsonstr, err := json.Marshal(s.cache.Stats())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(jsonstr)
+	case "DELETE":
+		s.cache.Flush()
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.Header().Set("Allow", "GET, DELETE")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRequireToken(t *testing.T) {
+	s := &SearchApp{}
+	ok := func(w http.ResponseWriter, r *http.Request) {}
+
+	for _, test := range []struct {
+		token  string
+		header string
+		want   int
+	}{
+		{"secret", "Bearer secret", http.StatusOK},
+		{"secret", "", http.StatusUnauthorized},
+		{"secret", "secret", http.StatusUnauthorized},
+		{"secret", "Basic secret", http.StatusUnauthorized},
+		{"secret", "Bearer", http.StatusUnauthorized},
+		{"secret", "Bearer ", http.StatusUnauthorized},
+		{"secret", "Bearer secret2", http.StatusUnauthorized},
+		{"secret", "Bearer Bearer secret", http.StatusUnauthorized},
+		{"", "", http.StatusUnauthorized},
+		{"", "Bearer ", http.StatusUnauthorized},
+	} {
+		req := httptest.NewRequest("GET", "/admin/cache", nil)
+		if test.header != "" {
+			req.Header.Set("Authorization", test.header)
+		}
+		w := httptest.NewRecorder()
+		s.requireToken(test.token, ok)(w, req)
+		if w.Code != test.want {
+			t.Errorf("token %q, header %q: got status %d, want %d", test.token, test.header, w.Code, test.want)
+		}
+	}
+}
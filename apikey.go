@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+)
+
+// apiKeyHeader is the request header that clients can supply their own OMDb
+// API key in, when client keys are allowed.
+const apiKeyHeader = "X-OMDb-Key"
+
+// apiKeyPattern matches the format of OMDb API keys, which are 8 letters and
+// digits.
+var apiKeyPattern = regexp.MustCompile(`^[A-Za-z0-9]{8}$`)
+
+// apiKeyKey is the context key for the OMDb API key to use for a request.
+type apiKeyKey struct{}
+
+// WithAPIKey returns a copy of ctx that makes OMDBAPI calls with it use key
+// instead of the OMDBAPI's own key.
+func WithAPIKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, apiKeyKey{}, key)
+}
+
+// apiKeyFrom returns the API key in ctx, and false if it doesn't have one.
+func apiKeyFrom(ctx context.Context) (string, bool) {
+	key, ok := ctx.Value(apiKeyKey{}).(string)
+	return key, ok && key != ""
+}
+
+// validAPIKey returns an error if key isn't in the format of an OMDb API key.
+func validAPIKey(key string) error {
+	if !apiKeyPattern.MatchString(key) {
+		return fmt.Errorf("the %s header must be an OMDb API key of 8 letters and digits", apiKeyHeader)
+	}
+	return nil
+}
+
+// withRequestKey returns u with its API key replaced by the one in ctx, if
+// there is one.
+func withRequestKey(ctx context.Context, u *url.URL) *url.URL {
+	key, ok := apiKeyFrom(ctx)
+	if !ok {
+		return u
+	}
+
+	n := *u
+	v := n.Query()
+	v.Set("apikey", key)
+	n.RawQuery = v.Encode()
+	return &n
+}
+
+// flightKey returns the key that a search cached under key shares upstream
+// calls under. Searches made with a client's key only share calls with others
+// made with the same key, so that one client's bad key doesn't fail searches
+// made with another.
+func flightKey(ctx context.Context, key string) string {
+	if k, ok := apiKeyFrom(ctx); ok {
+		return key + "|key:" + k
+	}
+	return key
+}
+
+// clientKeys passes requests on to next with the API key from their
+// X-OMDb-Key header, if they have one, in their context. Requests with a
+// malformed key get a 400.
+func clientKeys(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(apiKeyHeader)
+		if key == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if err := validAPIKey(key); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(WithAPIKey(r.Context(), key)))
+	})
+}
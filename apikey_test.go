@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"testing"
+)
+
+// keyedOMDb returns a handler that accepts only the API keys in keys.
+func keyedOMDb(keys ...string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		for _, key := range keys {
+			if r.URL.Query().Get("apikey") == key {
+				writeResults(w, 1, resultsFor("tt1")...)
+				return
+			}
+		}
+		w.WriteHeader(http.StatusUnauthorized)
+		writeError(w, invalidAPIKeyMessage)
+	}
+}
+
+func TestWithAPIKey(t *testing.T) {
+	omdb := newFakeOMDb(t, keyedOMDb(testKey, "client01"))
+	api := newTestAPI(t, omdb)
+
+	ctx := WithAPIKey(context.Background(), "client01")
+	if _, err := api.Search(ctx, &SearchRequest{Title: "alien"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := api.Search(context.Background(), &SearchRequest{Title: "alien"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := omdb.query(0).Get("apikey"); got != "client01" {
+		t.Errorf("got key %q with a key in the context, want client01", got)
+	}
+	if got := omdb.query(1).Get("apikey"); got != testKey {
+		t.Errorf("got key %q without one, want the OMDBAPI's", got)
+	}
+
+	// The debug URL is redacted whichever key is used.
+	_, u, _ := api.SearchDebug(ctx, &SearchRequest{Title: "alien"})
+	if got := u.Query().Get("apikey"); got != "***" {
+		t.Errorf("got key %q in the debug URL, want it redacted", got)
+	}
+}
+
+func TestAPIKeyFrom(t *testing.T) {
+	if _, ok := apiKeyFrom(context.Background()); ok {
+		t.Error("got a key from an empty context")
+	}
+	if _, ok := apiKeyFrom(WithAPIKey(context.Background(), "")); ok {
+		t.Error("got an empty key")
+	}
+	if key, ok := apiKeyFrom(WithAPIKey(context.Background(), "client01")); !ok || key != "client01" {
+		t.Errorf("got %q, want client01", key)
+	}
+
+	ctx := WithAPIKey(context.Background(), "client01")
+	if flightKey(ctx, "s=alien") == flightKey(context.Background(), "s=alien") {
+		t.Error("got the same flight key with and without a client key")
+	}
+}
+
+func TestClientKeys(t *testing.T) {
+	omdb := newFakeOMDb(t, keyedOMDb(testKey, "client01"))
+	s := newTestApp(t, omdb, Config{ClientKeys: true})
+
+	for _, test := range []struct {
+		key  string
+		want int
+	}{
+		{"", http.StatusOK},
+		{"client01", http.StatusOK},
+		{"client02", http.StatusUnauthorized},
+		{"short", http.StatusBadRequest},
+		{"client-1", http.StatusBadRequest},
+	} {
+		req := newSearchRequest(`{"title":"alien"}`)
+		if test.key != "" {
+			req.Header.Set(apiKeyHeader, test.key)
+		}
+		if w := serveRequest(s, req); w.Code != test.want {
+			t.Errorf("%q: got status %d, want %d: %s", test.key, w.Code, test.want, w.Body)
+		}
+	}
+	if n := omdb.calls(); n != 3 {
+		t.Errorf("got %d calls to OMDb, want 3", n)
+	}
+}
+
+func TestClientKeysDisabled(t *testing.T) {
+	omdb := newFakeOMDb(t, keyedOMDb(testKey))
+	s := newTestApp(t, omdb, Config{})
+
+	req := newSearchRequest(`{"title":"alien"}`)
+	req.Header.Set(apiKeyHeader, "client01")
+	if w := serveRequest(s, req); w.Code != http.StatusOK {
+		t.Errorf("got status %d, want 200", w.Code)
+	}
+	if got := omdb.query(0).Get("apikey"); got != testKey {
+		t.Errorf("got key %q, want the app's own", got)
+	}
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// The limits on /export/posters. Every entry is a poster download, so they're
+// far lower than for the other exports.
+const (
+	defaultPosterEntries = 50
+	maxPosterEntries     = 100
+	maxPosterSize        = 5 << 20
+)
+
+// PosterManifestEntry is a single result in the manifest.json of a poster
+// archive. File is the path of the poster in the archive, and is empty if
+// the result has no poster or it couldn't be fetched, in which case Error
+// says why.
+type PosterManifestEntry struct {
+	*SearchResult
+	File  string `json:",omitempty"`
+	Error string `json:",omitempty"`
+}
+
+// posterEntryName returns the name of the ith poster in an archive, for the
+// result with the IMDb ID id. The ID comes from OMDb, so it's cut down to
+// lowercase letters and digits rather than trusted as part of a path.
+func posterEntryName(i int, id string) string {
+	id = strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return -1
+	}, strings.ToLower(path.Base(id)))
+
+	name := fmt.Sprintf("posters/%03d", i+1)
+	if id != "" {
+		name += "-" + id
+	}
+	return name
+}
+
+// writePoster fetches the poster at rawURL and writes it to zw as name,
+// returning the name with the poster's extension.
+func (s *SearchApp) writePoster(r *http.Request, zw *zip.Writer, name, rawURL string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || !s.allowedPosterURL(u) {
+		return "", errors.New("poster URL is not allowed")
+	}
+
+	req, err := http.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := s.fetchPoster(req.WithContext(r.Context()))
+	if err != nil {
+		return "", errors.New("poster could not be fetched")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("poster could not be fetched, status %d", resp.StatusCode)
+	}
+
+	ext := path.Ext(u.Path)
+	if ext == "" {
+		ext = ".jpg"
+	}
+	name += ext
+
+	// Images are already compressed, so they're stored as they are.
+	f, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Store})
+	if err != nil {
+		return "", err
+	}
+
+	n, err := io.Copy(f, io.LimitReader(resp.Body, maxPosterSize+1))
+	if err != nil {
+		return name, fmt.Errorf("poster was cut short: %s", err)
+	}
+	if n > maxPosterSize {
+		return name, fmt.Errorf("poster was cut short at %d bytes", maxPosterSize)
+	}
+	return name, nil
+}
+
+// ExportPosters handles requests to /export/posters. It searches for the title
+// query parameter and streams a ZIP archive of the posters of the results,
+// along with a manifest.json of the results. The number of entries is capped
+// by the max_entries query parameter. A poster that can't be fetched is
+// reported in the manifest rather than failing the whole archive.
+func (s *SearchApp) ExportPosters(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.NotFound(w, r)
+		return
+	}
+
+	q, err := s.query(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	searchRequest := NewSearchRequest(q.Get("title"))
+	searchRequest.Type = q.Get("type")
+	searchRequest.ReleaseYear = q.Get("year")
+	s.applyMiddleware(r, searchRequest)
+
+	if err := s.validate(searchRequest); err != nil {
+		s.searchError(w, r, searchRequest, err)
+		return
+	}
+
+	if !s.typeAllowed(searchRequest.Type) {
+		s.searchError(w, r, searchRequest, fmt.Errorf("%w: %q", ErrTypeNotAllowed, searchRequest.Type))
+		return
+	}
+
+	maxEntries := defaultPosterEntries
+	if v := q.Get("max_entries"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxPosterEntries {
+			msg := fmt.Sprintf("max_entries must be between 1 and %d", maxPosterEntries)
+			http.Error(w, msg, http.StatusBadRequest)
+			return
+		}
+		maxEntries = n
+	}
+
+	// The results are small, so they're all collected before the archive is
+	// started, which means search errors can still get an error response.
+	var results []*SearchResult
+	extendWriteDeadline(w)
+	err = s.omdb.SearchStream(r.Context(), searchRequest, maxEntries, func(page []*SearchResult) error {
+		extendWriteDeadline(w)
+		for _, result := range page {
+			if s.typeAllowed(result.Type) {
+				results = append(results, result)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		s.searchError(w, r, searchRequest, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", `attachment; filename="posters.zip"`)
+
+	zw := zip.NewWriter(w)
+	manifest := make([]*PosterManifestEntry, len(results))
+	for i, result := range results {
+		entry := &PosterManifestEntry{SearchResult: result}
+		manifest[i] = entry
+
+		if r.Context().Err() != nil {
+			entry.Error = r.Context().Err().Error()
+			continue
+		}
+
+		if !hasPoster(result) {
+			entry.Error = "no poster"
+			continue
+		}
+
+		extendWriteDeadline(w)
+		name := posterEntryName(i, result.IMDBID)
+		if entry.File, err = s.writePoster(r, zw, name, result.Poster); err != nil {
+			entry.Error = err.Error()
+		}
+
+		if f, ok := w.(http.Flusher); ok {
+			zw.Flush()
+			f.Flush()
+		}
+	}
+
+	// Once the archive has started the status can't be changed, so failures
+	// are only logged.
+	extendWriteDeadline(w)
+	f, err := zw.Create("manifest.json")
+	if err == nil {
+		enc := json.NewEncoder(f)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(manifest)
+	}
+	if err == nil {
+		err = zw.Close()
+	}
+	if err != nil {
+		log.Printf("poster export of %q failed: %s", searchRequest.Title, err)
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// FrontendConfig is the JSON document returned by /config.json. It lets the
+// frontend find the API when the service isn't served from the root path.
+type FrontendConfig struct {
+	BasePath  string `json:"basePath"`
+	SearchURL string `json:"searchURL"`
+}
+
+// SetBasePath serves all of the routes under basePath, e.g. /omdb, for when
+// the service is behind a reverse proxy that routes a sub-path to it.
+func (s *SearchApp) SetBasePath(basePath string) {
+	basePath = strings.Trim(basePath, "/")
+	if basePath == "" {
+		s.basePath = ""
+		return
+	}
+	s.basePath = "/" + basePath
+}
+
+// Handler returns the http.Handler for all of the routes, under the base path
+// if there is one, with the origin check if it's enabled.
+func (s *SearchApp) Handler() http.Handler {
+	var h http.Handler = s.mux
+	if s.basePath != "" {
+		m := http.NewServeMux()
+		m.Handle(s.basePath+"/", http.StripPrefix(s.basePath, s.mux))
+		m.Handle(s.basePath, http.RedirectHandler(s.basePath+"/", http.StatusMovedPermanently))
+		h = m
+	}
+
+	if s.clientKeys {
+		h = clientKeys(h)
+	}
+	if s.allowedOrigins != nil {
+		h = s.checkOrigin(h)
+	}
+	return h
+}
+
+// FrontendConfig handles requests to /config.json.
+func (s *SearchApp) FrontendConfig(w http.ResponseWriter, r *http.Request) {
+	jsonstr, err := json.Marshal(&FrontendConfig{
+		BasePath:  s.basePath,
+		SearchURL: s.basePath + "/search",
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(jsonstr)
+}
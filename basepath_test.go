@@ -0,0 +1,71 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestBasePath(t *testing.T) {
+	dir := newSiteDir(t, map[string]string{"search.html": "<h1>search</h1>"})
+	omdb := newFakeOMDb(t, pagedResults(1))
+	s := newTestApp(t, omdb, Config{BasePath: "/omdb/", Landing: LandingIndex, SiteDir: dir})
+
+	for _, test := range []struct {
+		method, target, body string
+		want                 int
+	}{
+		{"POST", "/omdb/search", `{"title":"alien"}`, http.StatusOK},
+		{"GET", "/omdb/", "", http.StatusOK},
+		{"GET", "/omdb/config.json", "", http.StatusOK},
+		{"GET", "/omdb", "", http.StatusMovedPermanently},
+		{"POST", "/search", `{"title":"alien"}`, http.StatusNotFound},
+		{"GET", "/", "", http.StatusNotFound},
+		{"GET", "/omdbsearch", "", http.StatusNotFound},
+	} {
+		if w := send(s, test.method, test.target, test.body); w.Code != test.want {
+			t.Errorf("%s %s: got status %d, want %d", test.method, test.target, w.Code, test.want)
+		}
+	}
+
+	if w := send(s, "GET", "/omdb/", ""); w.Body.String() != "<h1>search</h1>" {
+		t.Errorf("got %q, want the search page", w.Body)
+	}
+	if w := send(s, "GET", "/omdb", ""); w.Header().Get("Location") != "/omdb/" {
+		t.Errorf("got a redirect to %q, want /omdb/", w.Header().Get("Location"))
+	}
+	if n := omdb.calls(); n != 1 {
+		t.Errorf("got %d calls to OMDb, want 1", n)
+	}
+}
+
+func TestFrontendConfig(t *testing.T) {
+	for basePath, want := range map[string]FrontendConfig{
+		"":       {BasePath: "", SearchURL: "/search"},
+		"omdb":   {BasePath: "/omdb", SearchURL: "/omdb/search"},
+		"/a/b/":  {BasePath: "/a/b", SearchURL: "/a/b/search"},
+		"/":      {BasePath: "", SearchURL: "/search"},
+		"/omdb/": {BasePath: "/omdb", SearchURL: "/omdb/search"},
+	} {
+		s := newTestApp(t, newFakeOMDb(t, pagedResults(0)), Config{BasePath: basePath})
+
+		var got FrontendConfig
+		w := send(s, "GET", strings.TrimSuffix(want.BasePath, "/")+"/config.json", "")
+		if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+			t.Fatalf("%q: decoding %q: %s", basePath, w.Body, err)
+		}
+		if got != want {
+			t.Errorf("%q: got %+v, want %+v", basePath, got, want)
+		}
+	}
+}
+
+func TestBasePathJobLocation(t *testing.T) {
+	s := newTestApp(t, newFakeOMDb(t, pagedResults(1)), Config{BasePath: "/omdb"})
+
+	w := send(s, "POST", "/omdb/jobs/search", `{"title":"alien"}`)
+	if loc := w.Header().Get("Location"); !strings.HasPrefix(loc, "/omdb/jobs/") {
+		t.Errorf("got Location %q, want it under the base path", loc)
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"unicode/utf8"
+)
+
+// defaultBodyLogLimit is the number of bytes of each body that's logged when
+// body logging is enabled.
+const defaultBodyLogLimit = 4096
+
+// secretFieldPattern matches the string values of JSON fields that may hold
+// secrets, so that they can be redacted from logged bodies.
+var secretFieldPattern = regexp.MustCompile(`(?i)("(?:api_?key|token|password|secret)"\s*:\s*)"(?:[^"\\]|\\.)*"`)
+
+// SetBodyLogging logs the JSON request bodies of searches and the response
+// bodies from OMDb, up to limit bytes of each, with the API keys and any
+// secret fields redacted. It's very verbose and is only for diagnosing
+// problems with real OMDb payloads. Zero disables it.
+func (o *OMDBAPI) SetBodyLogging(limit int) {
+	o.bodyLogLimit = limit
+}
+
+// loggedBody returns body as it's logged: redacted, then cut to the body log
+// limit, so that a secret is never partly logged by being cut short.
+func (o *OMDBAPI) loggedBody(ctx context.Context, body []byte) string {
+	s := o.redact(ctx, secretFieldPattern.ReplaceAllString(string(body), `$1"***"`))
+	if len(s) <= o.bodyLogLimit {
+		return s
+	}
+
+	cut := o.bodyLogLimit
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	return fmt.Sprintf("%s... (%d more bytes)", s[:cut], len(s)-cut)
+}
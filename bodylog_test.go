@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestLoggedBodyRedacted(t *testing.T) {
+	api := newTestAPI(t, newFakeOMDb(t, pagedResults(0)))
+	api.SetBodyLogging(defaultBodyLogLimit)
+	ctx := WithAPIKey(context.Background(), "client01")
+
+	for body, want := range map[string]string{
+		`{"title":"alien"}`: `{"title":"alien"}`,
+		`{"url":"https://omdb/?apikey=` + testKey + `"}`:   `{"url":"https://omdb/?apikey=***"}`,
+		`{"key":"client01"}`:                               `{"key":"***"}`,
+		`{"token": "hunter2", "title":"alien"}`:            `{"token": "***", "title":"alien"}`,
+		`{"API_KEY":"abc","Password":"p\"w","secret":"s"}`: `{"API_KEY":"***","Password":"***","secret":"***"}`,
+		`{"apiKey":"abc"}`:                                 `{"apiKey":"***"}`,
+	} {
+		if got := api.loggedBody(ctx, []byte(body)); got != want {
+			t.Errorf("got %s, want %s", got, want)
+		}
+	}
+}
+
+func TestLoggedBodyLimit(t *testing.T) {
+	api := newTestAPI(t, newFakeOMDb(t, pagedResults(0)))
+	api.SetBodyLogging(10)
+
+	if got, want := api.loggedBody(context.Background(), []byte("0123456789")), "0123456789"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got, want := api.loggedBody(context.Background(), []byte("0123456789abcdef")), "0123456789... (6 more bytes)"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	// A multi-byte character isn't cut in half.
+	got := api.loggedBody(context.Background(), []byte("012345678é and more"))
+	if !strings.HasPrefix(got, "012345678... (") || !utf8.ValidString(got) {
+		t.Errorf("got %q, want it cut before the é", got)
+	}
+
+	// A secret is redacted before the body is cut, so none of it is logged.
+	got = api.loggedBody(context.Background(), []byte(`{"token":"secretsecretsecret"}`))
+	if strings.Contains(got, "secr") {
+		t.Errorf("got %q, want the secret redacted", got)
+	}
+}
+
+func TestSearchLogBodies(t *testing.T) {
+	omdb := newFakeOMDb(t, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"Search":[],"Response":"False","Error":"Movie not found!","Echo":%q}`, r.URL.String())
+	})
+
+	t.Run("enabled", func(t *testing.T) {
+		log := captureLog(t)
+		s := newTestApp(t, omdb, Config{Debug: true, LogBodies: true, BodyLogLimit: 200})
+		serveRequest(s, newSearchRequest(`{"title":"alien","token":"hunter2"}`))
+
+		got := log.String()
+		if !strings.Contains(got, `request body: POST /search: {"title":"alien","token":"***"}`) {
+			t.Errorf("got log %q, want the redacted request body", got)
+		}
+		if !strings.Contains(got, "omdb response: 200") || !strings.Contains(got, "apikey=***") {
+			t.Errorf("got log %q, want the redacted response body", got)
+		}
+		if strings.Contains(got, testKey) || strings.Contains(got, "hunter2") {
+			t.Errorf("got log %q, want no secrets", got)
+		}
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		log := captureLog(t)
+		s := newTestApp(t, omdb, Config{Debug: true, BodyLogLimit: 200})
+		serveRequest(s, newSearchRequest(`{"title":"alien"}`))
+
+		if got := log.String(); strings.Contains(got, "request body:") || strings.Contains(got, "omdb response:") {
+			t.Errorf("got log %q, want no bodies logged by default", got)
+		}
+	})
+}
+
+func TestLogBodiesConfig(t *testing.T) {
+	for _, cfg := range []Config{
+		{LogBodies: true},
+		{Debug: true, LogBodies: true, BodyLogLimit: -1},
+	} {
+		cfg.Key = testKey
+		if _, err := NewSearchAppWithConfig(cfg); err == nil {
+			t.Errorf("%+v: got no error", cfg)
+		}
+	}
+}
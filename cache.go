@@ -0,0 +1,381 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// cacheEntry is a single set of cached search results.
+type cacheEntry struct {
+	results []*SearchResult
+	expires time.Time
+
+	// hits is the number of times the entry has been read, and refreshing
+	// is true while it's being refreshed ahead of expiring.
+	hits       int
+	refreshing bool
+}
+
+// CacheStats is a snapshot of the statistics tracked by a *SearchCache.
+type CacheStats struct {
+	Size        int    `json:"size"`
+	MaxSize     int    `json:"max_size"`
+	Hits        uint64 `json:"hits"`
+	Misses      uint64 `json:"misses"`
+	Evictions   uint64 `json:"evictions"`
+	Expirations uint64 `json:"expirations"`
+	Refreshes   uint64 `json:"refreshes"`
+}
+
+// SearchCache is an in-memory cache of search results. Entries expire after a
+// fixed TTL and the oldest entry is evicted once the cache is full. Searches
+// that matched nothing have their own TTL, so that a title that's added to
+// OMDb later can be found sooner. A nil *SearchCache is a valid cache that
+// never stores anything.
+type SearchCache struct {
+	ttl         time.Duration
+	negativeTTL time.Duration
+	maxSize     int
+	clock       Clock
+
+	// refreshWindow and refreshHits configure refresh-ahead, which is
+	// disabled if refreshWindow is zero.
+	refreshWindow time.Duration
+	refreshHits   int
+
+	mu      sync.Mutex
+	entries map[string]*cacheEntry
+
+	hits        uint64
+	misses      uint64
+	evictions   uint64
+	expirations uint64
+	refreshes   uint64
+}
+
+// NewSearchCache returns a new *SearchCache that holds up to maxSize entries
+// for ttl each.
+func NewSearchCache(ttl time.Duration, maxSize int) *SearchCache {
+	return &SearchCache{
+		ttl:     ttl,
+		maxSize: maxSize,
+		clock:   RealClock,
+		entries: make(map[string]*cacheEntry),
+	}
+}
+
+// SetNegativeTTL sets how long searches that matched nothing are cached for.
+// They aren't cached if it's zero, which is the default.
+func (c *SearchCache) SetNegativeTTL(ttl time.Duration) {
+	c.negativeTTL = ttl
+}
+
+// SetRefreshAhead refreshes the entries that have been read at least hits
+// times in the background once they're within window of expiring, so that
+// popular searches don't miss the cache when they expire. It's disabled if
+// window is zero, which is the default.
+func (c *SearchCache) SetRefreshAhead(window time.Duration, hits int) {
+	c.refreshWindow = window
+	c.refreshHits = hits
+}
+
+// canonicalTitle returns title trimmed, lowercased and with each run of
+// whitespace collapsed to a single space, as OMDb ignores the difference.
+func canonicalTitle(title string) string {
+	return strings.ToLower(strings.Join(strings.Fields(title), " "))
+}
+
+// requestKey is the canonical key derivation for searches. The keys that the
+// results of a search are cached under, and that identical searches in
+// flight share an upstream call under, are all derived from it, so that
+// searches OMDb treats as the same, like "The Matrix" and "the  matrix ",
+// share them too. It's the canonicalized parameters of r and page encoded as
+// a query string, which sorts them. Titles that are IMDb IDs are looked up
+// rather than searched for, so they're kept as they are, under a different
+// parameter than titles.
+func requestKey(r *SearchRequest, page string) string {
+	v := url.Values{}
+	if id := strings.TrimSpace(r.Title); imdbIDPattern.MatchString(id) {
+		v.Set("i", id)
+	} else {
+		v.Set("s", canonicalTitle(r.Title))
+	}
+	if typ := strings.ToLower(strings.TrimSpace(r.Type)); typ != "" {
+		v.Set("type", typ)
+	}
+	if year := strings.TrimSpace(r.ReleaseYear); year != "" {
+		v.Set("y", year)
+	}
+	v.Set("page", page)
+	return v.Encode()
+}
+
+// cacheKey returns the key that the results for r are cached under. Page zero
+// is the first page, so it has the same key as page 1.
+func cacheKey(r *SearchRequest) string {
+	return requestKey(r, strconv.Itoa(max(r.Page, 1)))
+}
+
+// The strategies for the keys that search results are cached under.
+//
+// CacheKeyPage caches each page of results separately, as it's returned by
+// OMDb. Only the pages that are asked for are fetched, and each expires on its
+// own.
+//
+// CacheKeyTerm caches all of the results for a search, up to
+// maxTermCacheResults, under a single key and serves each page from them. The
+// first request for any page fetches all of them, which costs more upstream
+// calls up front, but paging through the results is then served from a
+// single entry. The whole result set expires at once, so pages can't be
+// inconsistent with each other, but they can all be stale together. Pages
+// beyond maxTermCacheResults are cached separately, as with CacheKeyPage.
+const (
+	CacheKeyPage = "page"
+	CacheKeyTerm = "term"
+)
+
+// maxTermCacheResults is the number of results cached for a search with the
+// CacheKeyTerm strategy. Fetching them takes one upstream call per page.
+const maxTermCacheResults = 100
+
+// validCacheKeyStrategy returns an error if s isn't a cache key strategy.
+func validCacheKeyStrategy(s string) error {
+	switch s {
+	case CacheKeyPage, CacheKeyTerm:
+		return nil
+	}
+	return fmt.Errorf("unsupported cache key strategy %q, must be %q or %q", s, CacheKeyPage, CacheKeyTerm)
+}
+
+// termCacheKey returns the key that all of the results for r are cached under
+// with the CacheKeyTerm strategy.
+func termCacheKey(r *SearchRequest) string {
+	return requestKey(r, "*")
+}
+
+// resultsPage returns the page of results, counting from 1, with zero meaning
+// the first page.
+func resultsPage(results []*SearchResult, page int) []*SearchResult {
+	if page < 1 {
+		page = 1
+	}
+
+	start := (page - 1) * PageSize
+	if start >= len(results) {
+		return []*SearchResult{}
+	}
+
+	end := start + PageSize
+	if end > len(results) {
+		end = len(results)
+	}
+	return results[start:end]
+}
+
+// searchTerm returns the page of results for r from all of the results for its
+// search, which are fetched and cached if they aren't already.
+func (s *SearchApp) searchTerm(ctx context.Context, r *SearchRequest) ([]*SearchResult, error) {
+	fetch := func(ctx context.Context) ([]*SearchResult, error) {
+		var all []*SearchResult
+		err := s.omdb.SearchStream(ctx, r, maxTermCacheResults, func(page []*SearchResult) error {
+			all = append(all, page...)
+			return nil
+		})
+		return all, err
+	}
+
+	key := termCacheKey(r)
+	if results, ok, refresh := s.cache.lookup(key); ok {
+		if refresh {
+			s.refreshAhead(ctx, key, fetch)
+		}
+		return resultsPage(results, r.Page), nil
+	}
+
+	results, err, _ := s.flights.Do(ctx, flightKey(ctx, key), fetch)
+	if err != nil {
+		return nil, err
+	}
+
+	s.cache.Set(key, results)
+	return resultsPage(results, r.Page), nil
+}
+
+// refreshTimeout is how long a refresh-ahead of a cache entry can take.
+const refreshTimeout = 30 * time.Second
+
+// refreshAhead refreshes the results cached under key with fetch in the
+// background. It's skipped, leaving the entry to expire as usual, for
+// searches made with a client's API key, which the refresh couldn't use, and
+// while the quota is used up or load is being shed, as the refresh would only
+// add to the load on OMDb.
+func (s *SearchApp) refreshAhead(ctx context.Context, key string, fetch func(context.Context) ([]*SearchResult, error)) {
+	if _, ok := apiKeyFrom(ctx); ok || s.omdb.RemainingQuota() == 0 || s.omdb.shedder.Fraction() > 0 {
+		s.cache.cancelRefresh(key)
+		return
+	}
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), refreshTimeout)
+		defer cancel()
+
+		results, err, _ := s.flights.Do(ctx, flightKey(ctx, key), fetch)
+		if err != nil {
+			log.Printf("refreshing the cached results for %q failed: %s", key, errorMessage(err))
+			s.cache.cancelRefresh(key)
+			return
+		}
+		s.cache.Set(key, results)
+	}()
+}
+
+// Get returns the cached results for key, if there are any that haven't
+// expired.
+func (c *SearchCache) Get(key string) ([]*SearchResult, bool) {
+	results, ok, _ := c.lookup(key)
+	return results, ok
+}
+
+// lookup returns the cached results for key, like Get, and true if the caller
+// should refresh them ahead of expiring. Only one caller is told to refresh an
+// entry, until it's replaced or the refresh is canceled.
+func (c *SearchCache) lookup(key string) ([]*SearchResult, bool, bool) {
+	if c == nil {
+		return nil, false, false
+	}
+
+	now := c.clock.Now()
+
+	c.mu.Lock()
+	e, ok := c.entries[key]
+	if ok && now.After(e.expires) {
+		delete(c.entries, key)
+		atomic.AddUint64(&c.expirations, 1)
+		ok = false
+	}
+
+	var refresh bool
+	if ok {
+		e.hits++
+		if c.refreshWindow > 0 && !e.refreshing && e.hits >= c.refreshHits && e.expires.Sub(now) <= c.refreshWindow {
+			e.refreshing = true
+			refresh = true
+		}
+	}
+	c.mu.Unlock()
+
+	if !ok {
+		atomic.AddUint64(&c.misses, 1)
+		return nil, false, false
+	}
+	atomic.AddUint64(&c.hits, 1)
+	if refresh {
+		atomic.AddUint64(&c.refreshes, 1)
+	}
+	return e.results, true, refresh
+}
+
+// cancelRefresh lets the entry for key be refreshed again after a refresh of
+// it failed or was skipped.
+func (c *SearchCache) cancelRefresh(key string) {
+	c.mu.Lock()
+	if e, ok := c.entries[key]; ok {
+		e.refreshing = false
+	}
+	c.mu.Unlock()
+}
+
+// Set caches results under key, evicting the entry closest to expiring if the
+// cache is full. Empty results are cached for the negative TTL instead of the
+// TTL, and results aren't cached at all if their TTL is zero.
+func (c *SearchCache) Set(key string, results []*SearchResult) {
+	if c == nil {
+		return
+	}
+
+	ttl := c.ttl
+	if len(results) == 0 {
+		ttl = c.negativeTTL
+	}
+	if ttl <= 0 {
+		return
+	}
+
+	now := c.clock.Now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.entries[key]; !ok && len(c.entries) >= c.maxSize {
+		c.evict(now)
+	}
+
+	c.entries[key] = &cacheEntry{
+		results: results,
+		expires: now.Add(ttl),
+	}
+}
+
+// evict removes all of the expired entries, or the entry closest to expiring
+// if none have expired. The caller must hold c.mu.
+func (c *SearchCache) evict(now time.Time) {
+	var (
+		oldestKey string
+		oldest    *cacheEntry
+	)
+
+	for k, e := range c.entries {
+		if now.After(e.expires) {
+			delete(c.entries, k)
+			atomic.AddUint64(&c.expirations, 1)
+			continue
+		}
+		if oldest == nil || e.expires.Before(oldest.expires) {
+			oldestKey, oldest = k, e
+		}
+	}
+
+	if len(c.entries) >= c.maxSize && oldest != nil {
+		delete(c.entries, oldestKey)
+		atomic.AddUint64(&c.evictions, 1)
+	}
+}
+
+// Flush removes all of the entries from the cache.
+func (c *SearchCache) Flush() {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	c.entries = make(map[string]*cacheEntry)
+	c.mu.Unlock()
+}
+
+// Stats returns a snapshot of the cache statistics.
+func (c *SearchCache) Stats() *CacheStats {
+	if c == nil {
+		return &CacheStats{}
+	}
+
+	c.mu.Lock()
+	size := len(c.entries)
+	c.mu.Unlock()
+
+	return &CacheStats{
+		Size:        size,
+		MaxSize:     c.maxSize,
+		Hits:        atomic.LoadUint64(&c.hits),
+		Misses:      atomic.LoadUint64(&c.misses),
+		Evictions:   atomic.LoadUint64(&c.evictions),
+		Expirations: atomic.LoadUint64(&c.expirations),
+		Refreshes:   atomic.LoadUint64(&c.refreshes),
+	}
+}
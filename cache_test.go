@@ -0,0 +1,390 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// searchPage searches s for title's page and returns the IDs of the results.
+func searchPage(t *testing.T, s *SearchApp, title string, page int) []string {
+	t.Helper()
+	w := serveRequest(s, newSearchRequest(`{"title":"`+title+`","page":`+strconv.Itoa(page)+`}`))
+	return ids(decodeResults(t, w))
+}
+
+// pageIDs returns the IDs that pagedResults serves on page.
+func pageIDs(page, total int) []string {
+	var ids []string
+	for i := (page-1)*PageSize + 1; i <= min(page*PageSize, total); i++ {
+		ids = append(ids, "tt"+strconv.Itoa(i))
+	}
+	return ids
+}
+
+func TestCacheKeyPage(t *testing.T) {
+	omdb := newFakeOMDb(t, pagedResults(35))
+	s := newTestApp(t, omdb, Config{CacheTTL: time.Minute})
+
+	for _, page := range []int{2, 2, 1, 0, 4} {
+		if got, want := searchPage(t, s, "alien", page), pageIDs(max(page, 1), 35); !reflect.DeepEqual(got, want) {
+			t.Errorf("page %d: got %v, want %v", page, got, want)
+		}
+	}
+	// Each page is fetched once, and page zero is page 1.
+	if n := omdb.calls(); n != 3 {
+		t.Errorf("got %d calls to OMDb, want 3", n)
+	}
+}
+
+func TestCacheKeyTerm(t *testing.T) {
+	omdb := newFakeOMDb(t, pagedResults(35))
+	s := newTestApp(t, omdb, Config{CacheTTL: time.Minute, CacheKeys: CacheKeyTerm})
+
+	if got, want := searchPage(t, s, "alien", 2), pageIDs(2, 35); !reflect.DeepEqual(got, want) {
+		t.Errorf("page 2: got %v, want %v", got, want)
+	}
+	// The first request fetches every page.
+	if n := omdb.calls(); n != 4 {
+		t.Fatalf("got %d calls to OMDb, want 4", n)
+	}
+
+	for _, page := range []int{1, 3, 4} {
+		if got, want := searchPage(t, s, "alien", page), pageIDs(page, 35); !reflect.DeepEqual(got, want) {
+			t.Errorf("page %d: got %v, want %v", page, got, want)
+		}
+	}
+	if got := searchPage(t, s, "alien", 5); len(got) != 0 {
+		t.Errorf("page 5: got %v, want nothing", got)
+	}
+	if n := omdb.calls(); n != 4 {
+		t.Errorf("got %d calls to OMDb, want the pages to be served from the cache", n)
+	}
+}
+
+func TestCacheKeyTermBeyondLimit(t *testing.T) {
+	omdb := newFakeOMDb(t, pagedResults(200))
+	s := newTestApp(t, omdb, Config{CacheTTL: time.Minute, CacheKeys: CacheKeyTerm})
+
+	page := pagesFor(maxTermCacheResults) + 1
+	for range 2 {
+		if got, want := searchPage(t, s, "alien", page), pageIDs(page, 200); !reflect.DeepEqual(got, want) {
+			t.Errorf("page %d: got %v, want %v", page, got, want)
+		}
+	}
+	// It's cached on its own, without fetching the pages before it.
+	if n := omdb.calls(); n != 1 {
+		t.Errorf("got %d calls to OMDb, want 1", n)
+	}
+	if got := omdb.query(0).Get("page"); got != strconv.Itoa(page) {
+		t.Errorf("got page %q, want %d", got, page)
+	}
+}
+
+func TestCacheExpiry(t *testing.T) {
+	for _, strategy := range []string{CacheKeyPage, CacheKeyTerm} {
+		omdb := newFakeOMDb(t, pagedResults(5))
+		s := newTestApp(t, omdb, Config{CacheTTL: time.Minute, CacheKeys: strategy})
+		clock := NewFakeClock(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC))
+		s.SetClock(clock)
+
+		searchPage(t, s, "alien", 1)
+		clock.Advance(time.Minute)
+		searchPage(t, s, "alien", 1)
+		if n := omdb.calls(); n != 1 {
+			t.Errorf("%s: got %d calls to OMDb within the TTL, want 1", strategy, n)
+		}
+
+		clock.Advance(time.Second)
+		searchPage(t, s, "alien", 1)
+		if n := omdb.calls(); n != 2 {
+			t.Errorf("%s: got %d calls to OMDb after the TTL, want 2", strategy, n)
+		}
+	}
+}
+
+func TestResultsPage(t *testing.T) {
+	results := resultsFor(pageIDs(1, 25)...)
+	results = append(results, resultsFor(pageIDs(2, 25)...)...)
+	results = append(results, resultsFor(pageIDs(3, 25)...)...)
+
+	for _, test := range []struct {
+		page int
+		want []string
+	}{
+		{0, pageIDs(1, 25)},
+		{1, pageIDs(1, 25)},
+		{3, pageIDs(3, 25)},
+		{4, []string{}},
+	} {
+		if got := ids(resultsPage(results, test.page)); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("page %d: got %v, want %v", test.page, got, test.want)
+		}
+	}
+}
+
+func TestCacheKeyStrategy(t *testing.T) {
+	if _, err := NewSearchAppWithConfig(Config{Key: testKey, CacheTTL: time.Minute, CacheKeys: "title"}); err == nil {
+		t.Error("got no error for an unsupported strategy")
+	}
+}
+
+func TestNegativeCache(t *testing.T) {
+	var released atomic.Bool
+	omdb := newFakeOMDb(t, func(w http.ResponseWriter, r *http.Request) {
+		if !released.Load() {
+			writeResults(w, 0)
+			return
+		}
+		writeResults(w, 1, resultsFor("tt1")...)
+	})
+	s := newTestApp(t, omdb, Config{CacheTTL: time.Minute, NegativeCacheTTL: 10 * time.Second})
+	clock := NewFakeClock(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC))
+	s.SetClock(clock)
+
+	for range 2 {
+		if got := searchPage(t, s, "alein", 1); len(got) != 0 {
+			t.Errorf("got %v, want nothing", got)
+		}
+	}
+	if n := omdb.calls(); n != 1 {
+		t.Errorf("got %d calls to OMDb, want the not-found to be cached", n)
+	}
+
+	// A title that's added is found once the negative TTL has passed.
+	released.Store(true)
+	clock.Advance(10 * time.Second)
+	if got := searchPage(t, s, "alein", 1); len(got) != 0 {
+		t.Errorf("got %v within the negative TTL, want nothing", got)
+	}
+	clock.Advance(time.Second)
+	if got, want := searchPage(t, s, "alein", 1), []string{"tt1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v after the negative TTL, want %v", got, want)
+	}
+	if n := omdb.calls(); n != 2 {
+		t.Errorf("got %d calls to OMDb, want 2", n)
+	}
+
+	// The results are then cached for the positive TTL.
+	clock.Advance(30 * time.Second)
+	searchPage(t, s, "alein", 1)
+	if n := omdb.calls(); n != 2 {
+		t.Errorf("got %d calls to OMDb within the TTL, want 2", n)
+	}
+}
+
+func TestNegativeCacheTTLs(t *testing.T) {
+	for _, test := range []struct {
+		name                 string
+		ttl, negativeTTL     time.Duration
+		foundCalls, notCalls int
+	}{
+		{"no negative TTL", time.Minute, 0, 1, 2},
+		{"only a negative TTL", 0, time.Minute, 2, 1},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			omdb := newFakeOMDb(t, pagedResults(5))
+			s := newTestApp(t, omdb, Config{CacheTTL: test.ttl, NegativeCacheTTL: test.negativeTTL})
+
+			for range 2 {
+				searchPage(t, s, "alien", 1)
+			}
+			if n := omdb.calls(); n != test.foundCalls {
+				t.Errorf("got %d calls to OMDb for results, want %d", n, test.foundCalls)
+			}
+			for range 2 {
+				searchPage(t, s, "alien", 2)
+			}
+			if n := omdb.calls() - test.foundCalls; n != test.notCalls {
+				t.Errorf("got %d calls to OMDb for no results, want %d", n, test.notCalls)
+			}
+		})
+	}
+}
+
+func TestNegativeCacheTTLInvalid(t *testing.T) {
+	if _, err := NewSearchAppWithConfig(Config{Key: testKey, NegativeCacheTTL: -time.Second}); err == nil {
+		t.Error("got no error for a negative TTL")
+	}
+}
+
+// waitForRefresh waits for the refresh of the entry for key in c to finish.
+func waitForRefresh(t *testing.T, c *SearchCache, key string) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		c.mu.Lock()
+		e, ok := c.entries[key]
+		done := ok && !e.refreshing
+		c.mu.Unlock()
+		if done {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatalf("the entry for %q wasn't refreshed", key)
+}
+
+func TestRefreshAhead(t *testing.T) {
+	for _, strategy := range []string{CacheKeyPage, CacheKeyTerm} {
+		t.Run(strategy, func(t *testing.T) {
+			omdb := newFakeOMDb(t, pagedResults(5))
+			s := newTestApp(t, omdb, Config{
+				CacheTTL:         time.Minute,
+				CacheKeys:        strategy,
+				RefreshAhead:     10 * time.Second,
+				RefreshAheadHits: 2,
+			})
+			clock := NewFakeClock(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC))
+			s.SetClock(clock)
+			key := cacheKey(&SearchRequest{Title: "alien", Page: 1})
+			if strategy == CacheKeyTerm {
+				key = termCacheKey(&SearchRequest{Title: "alien"})
+			}
+
+			// Reads before the refresh window don't refresh it.
+			for range 3 {
+				searchPage(t, s, "alien", 1)
+			}
+			if n := omdb.calls(); n != 1 {
+				t.Fatalf("got %d calls to OMDb, want 1", n)
+			}
+
+			clock.Advance(55 * time.Second)
+			if got, want := searchPage(t, s, "alien", 1), pageIDs(1, 5); !reflect.DeepEqual(got, want) {
+				t.Errorf("got %v while refreshing, want the cached %v", got, want)
+			}
+			waitForRefresh(t, s.cache, key)
+			if n := omdb.calls(); n != 2 {
+				t.Errorf("got %d calls to OMDb, want the entry refreshed", n)
+			}
+			if got := s.cache.Stats().Refreshes; got != 1 {
+				t.Errorf("got %d refreshes, want 1", got)
+			}
+
+			// The refreshed entry outlives the one it replaced.
+			clock.Advance(30 * time.Second)
+			searchPage(t, s, "alien", 1)
+			if n := omdb.calls(); n != 2 {
+				t.Errorf("got %d calls to OMDb after the old entry expired, want 2", n)
+			}
+		})
+	}
+}
+
+func TestRefreshAheadCold(t *testing.T) {
+	omdb := newFakeOMDb(t, pagedResults(5))
+	s := newTestApp(t, omdb, Config{CacheTTL: time.Minute, RefreshAhead: 10 * time.Second, RefreshAheadHits: 2})
+	clock := NewFakeClock(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC))
+	s.SetClock(clock)
+
+	// An entry that hasn't been read enough isn't refreshed, and expires.
+	searchPage(t, s, "alien", 1)
+	clock.Advance(55 * time.Second)
+	searchPage(t, s, "alien", 1)
+	if got := s.cache.Stats().Refreshes; got != 0 {
+		t.Errorf("got %d refreshes, want none", got)
+	}
+	clock.Advance(10 * time.Second)
+	searchPage(t, s, "alien", 1)
+	if n := omdb.calls(); n != 2 {
+		t.Errorf("got %d calls to OMDb, want 2", n)
+	}
+}
+
+func TestRefreshAheadSkipped(t *testing.T) {
+	omdb := newFakeOMDb(t, pagedResults(5))
+	s := newTestApp(t, omdb, Config{CacheTTL: time.Minute, RefreshAhead: 10 * time.Second, RefreshAheadHits: 1, DailyQuota: 1})
+	clock := NewFakeClock(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC))
+	s.SetClock(clock)
+
+	// The quota is used up by the search that filled the cache.
+	searchPage(t, s, "alien", 1)
+	clock.Advance(55 * time.Second)
+	searchPage(t, s, "alien", 1)
+	time.Sleep(10 * time.Millisecond)
+	if n := omdb.calls(); n != 1 {
+		t.Errorf("got %d calls to OMDb, want the refresh skipped with no quota left", n)
+	}
+}
+
+func TestRefreshAheadConfig(t *testing.T) {
+	for _, cfg := range []Config{
+		{CacheTTL: time.Minute, RefreshAhead: time.Minute},
+		{CacheTTL: time.Minute, RefreshAhead: -time.Second},
+		{CacheTTL: time.Minute, RefreshAhead: time.Second, RefreshAheadHits: -1},
+	} {
+		cfg.Key = testKey
+		if _, err := NewSearchAppWithConfig(cfg); err == nil {
+			t.Errorf("%+v: got no error", cfg)
+		}
+	}
+}
+
+func TestRequestKey(t *testing.T) {
+	key := cacheKey(&SearchRequest{Title: "The Matrix", Type: "movie", ReleaseYear: "1999"})
+	for _, r := range []*SearchRequest{
+		{Title: "the matrix", Type: "movie", ReleaseYear: "1999"},
+		{Title: "  THE\tMatrix  ", Type: " Movie ", ReleaseYear: " 1999"},
+		{Title: "The\n\nMatrix", Type: "MOVIE", ReleaseYear: "1999", Page: 1},
+		{Title: "The Matrix", Type: "movie", ReleaseYear: "1999", Page: 0},
+	} {
+		if got := cacheKey(r); got != key {
+			t.Errorf("%+v: got key %q, want %q", r, got, key)
+		}
+	}
+
+	for _, r := range []*SearchRequest{
+		{Title: "The Matrix"},
+		{Title: "The Matrix", Type: "series", ReleaseYear: "1999"},
+		{Title: "The Matrix", Type: "movie", ReleaseYear: "2003"},
+		{Title: "The Matrix", Type: "movie", ReleaseYear: "1999", Page: 2},
+		{Title: "TheMatrix", Type: "movie", ReleaseYear: "1999"},
+	} {
+		if got := cacheKey(r); got == key {
+			t.Errorf("%+v: got the same key as a different search", r)
+		}
+	}
+}
+
+func TestRequestKeyIMDbID(t *testing.T) {
+	if got, want := cacheKey(&SearchRequest{Title: " tt0133093 "}), "i=tt0133093&page=1"; got != want {
+		t.Errorf("got key %q, want %q", got, want)
+	}
+	if cacheKey(&SearchRequest{Title: "tt0133093"}) == cacheKey(&SearchRequest{Title: "TT0133093"}) {
+		t.Error("a title that looks like an ID but isn't one got the ID's key")
+	}
+}
+
+func TestFlightKey(t *testing.T) {
+	key := cacheKey(&SearchRequest{Title: "alien"})
+	if got := flightKey(context.Background(), key); got != key {
+		t.Errorf("got %q, want the cache key", got)
+	}
+	withKey := flightKey(WithAPIKey(context.Background(), "client"), key)
+	if withKey == key || withKey != flightKey(WithAPIKey(context.Background(), "client"), key) {
+		t.Errorf("got %q, want a key of its own for the client's API key", withKey)
+	}
+}
+
+func TestSearchCanonicalKey(t *testing.T) {
+	omdb := newFakeOMDb(t, pagedResults(5))
+	s := newTestApp(t, omdb, Config{CacheTTL: time.Minute})
+
+	for _, title := range []string{"The Matrix", "the matrix ", "  THE   MATRIX"} {
+		if got, want := searchPage(t, s, title, 1), pageIDs(1, 5); !reflect.DeepEqual(got, want) {
+			t.Errorf("%q: got %v, want %v", title, got, want)
+		}
+	}
+	if n := omdb.calls(); n != 1 {
+		t.Errorf("got %d calls to OMDb, want the equivalent searches to share 1", n)
+	}
+	// OMDb is still sent the title as it was given.
+	if got := omdb.query(0).Get("s"); got != "The Matrix" {
+		t.Errorf("got s=%q, want the title unchanged", got)
+	}
+}
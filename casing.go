@@ -0,0 +1,67 @@
+package main
+
+import "fmt"
+
+// The JSON key casings supported for /search responses.
+const (
+	PascalCase = "pascal"
+	CamelCase  = "camel"
+)
+
+// validJSONCase returns an error if c is not a supported JSON key casing.
+func validJSONCase(c string) error {
+	switch c {
+	case PascalCase, CamelCase:
+		return nil
+	}
+	return fmt.Errorf("unsupported JSON case %q, must be %q or %q", c, PascalCase, CamelCase)
+}
+
+// camelSearchResult is a *SearchResult with camelCase JSON keys.
+type camelSearchResult struct {
+	Title  string `json:"title"`
+	Year   string `json:"year"`
+	IMDBID string `json:"imdbID"`
+	Type   string `json:"type"`
+	Poster string `json:"poster"`
+
+	Score      float64 `json:"score,omitempty"`
+	IMDBRating string  `json:"imdbRating,omitempty"`
+	RawTitle   string  `json:"rawTitle,omitempty"`
+	URL        string  `json:"url,omitempty"`
+}
+
+// resultWithJSONCase returns a value that marshals r with the JSON key casing
+// c. PascalCase, which is what OMDb returns, leaves r unchanged.
+func resultWithJSONCase(r *SearchResult, c string) interface{} {
+	if c != CamelCase {
+		return r
+	}
+
+	return &camelSearchResult{
+		Title:  r.Title,
+		Year:   r.Year,
+		IMDBID: r.IMDBID,
+		Type:   r.Type,
+		Poster: r.Poster,
+
+		Score:      r.Score,
+		IMDBRating: r.IMDBRating,
+		RawTitle:   r.RawTitle,
+		URL:        r.URL,
+	}
+}
+
+// withJSONCase returns a value that marshals results with the JSON key casing
+// c. PascalCase, which is what OMDb returns, leaves results unchanged.
+func withJSONCase(results []*SearchResult, c string) interface{} {
+	if c != CamelCase {
+		return results
+	}
+
+	camel := make([]interface{}, len(results))
+	for i, r := range results {
+		camel[i] = resultWithJSONCase(r, c)
+	}
+	return camel
+}
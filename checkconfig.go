@@ -0,0 +1,182 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// configCheck is a single check made by CheckConfig.
+type configCheck struct {
+	name  string
+	check func() error
+}
+
+// validBaseURL returns an error if u isn't an absolute http(s) URL.
+func validBaseURL(u string) error {
+	parsed, err := url.Parse(u)
+	if err != nil {
+		return err
+	}
+	if (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return fmt.Errorf("%q must be an absolute http or https URL", u)
+	}
+	return nil
+}
+
+// checkHistoryFile returns an error if the search history can't be kept in the
+// file at path. Unlike OpenFileHistory, it never creates the file.
+func checkHistoryFile(path string, size int) error {
+	dir := filepath.Dir(path)
+	info, err := os.Stat(dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", dir)
+	}
+	h := &FileHistory{path: path, size: size, recent: NewSearchHistory(size)}
+	return h.load()
+}
+
+// checkSettings returns an error if any of the settings in cfg that are
+// validated as they're applied is invalid. They're applied to a *SearchApp
+// that's never served, rather than one from NewSearchAppWithConfig, so that
+// checking them doesn't open any files or log anything.
+func checkSettings(cfg Config) error {
+	s := &SearchApp{mux: http.NewServeMux()}
+	if err := s.registerRoutes(cfg.DisabledFeatures); err != nil {
+		return err
+	}
+	if _, err := NewLocaleCollator(cfg.Collation); err != nil {
+		return err
+	}
+	if cfg.Retries > 0 {
+		if _, err := NewBackoff(cfg.Retries, cfg.RetryDelay, cfg.RetryMaxDelay, cfg.RetryJitter); err != nil {
+			return err
+		}
+	}
+	if err := s.SetAllowedTypes(cfg.AllowedTypes); err != nil {
+		return err
+	}
+	if err := s.SetLanding(cfg.Landing, cfg.SiteDir, cfg.LandingURL); err != nil {
+		return err
+	}
+	if err := s.SetStaticExtensions(cfg.StaticExtensions); err != nil {
+		return err
+	}
+	if err := s.SetNotFoundPage(cfg.NotFoundPage); err != nil {
+		return err
+	}
+	if err := s.SetPosterHosts(cfg.PosterHosts); err != nil {
+		return err
+	}
+	return s.SetAllowedOrigins(cfg.AllowedOrigins)
+}
+
+// CheckConfig validates cfg, and the server timeouts, without starting the
+// service, writing a line to w for each check made. Unlike
+// NewSearchAppWithConfig, a missing site directory is always an error, and
+// nothing is created, not even the history file. It returns an error if any
+// of the checks failed.
+func CheckConfig(w io.Writer, cfg Config, timeouts map[string]time.Duration) error {
+	cfg = cfg.withDefaults()
+
+	checks := []configCheck{
+		{"an OMDb API key is set", func() error {
+			if cfg.Key == "" {
+				return errors.New("--key is required")
+			}
+			return nil
+		}},
+		{"the OMDb base URL is valid", func() error { return validBaseURL(cfg.BaseURL) }},
+	}
+
+	if cfg.FallbackURL != "" {
+		checks = append(checks, configCheck{"the fallback URL is valid", func() error { return validBaseURL(cfg.FallbackURL) }})
+	}
+
+	names := make([]string, 0, len(timeouts))
+	for name := range timeouts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		d := timeouts[name]
+		checks = append(checks, configCheck{"the " + name + " is not negative", func() error {
+			if d < 0 {
+				return fmt.Errorf("got %s", d)
+			}
+			return nil
+		}})
+	}
+
+	switch {
+	case cfg.Landing == LandingIndex && cfg.Theme != "":
+		checks = append(checks, configCheck{"the theme " + cfg.Theme + " is bundled", func() error {
+			if _, ok := themeFS(cfg.Theme); !ok {
+				return fmt.Errorf("must be one of %s", strings.Join(Themes(), ", "))
+			}
+			return nil
+		}})
+	case cfg.Landing == LandingIndex:
+		dir := cfg.SiteDir
+		checks = append(checks, configCheck{"the site directory " + dir + " exists", func() error {
+			info, err := os.Stat(dir)
+			if err != nil {
+				return err
+			}
+			if !info.IsDir() {
+				return fmt.Errorf("%s is not a directory", dir)
+			}
+			return nil
+		}})
+	}
+
+	if cfg.HistoryFile != "" {
+		checks = append(checks, configCheck{"the history file " + cfg.HistoryFile + " can be used", func() error {
+			return checkHistoryFile(cfg.HistoryFile, cfg.HistorySize)
+		}})
+	}
+
+	checks = append(checks, configCheck{"the settings are valid", cfg.validate})
+
+	// The settings that depend on each other, like the landing mode, are only
+	// worth checking if the rest passed.
+	configure := configCheck{"the service can be configured with the settings", func() error {
+		return checkSettings(cfg)
+	}}
+
+	failed := 0
+	for _, c := range checks {
+		if err := c.check(); err != nil {
+			failed++
+			fmt.Fprintf(w, "FAIL %s: %s\n", c.name, err)
+			continue
+		}
+		fmt.Fprintf(w, "ok   %s\n", c.name)
+	}
+	if failed == 0 {
+		if err := configure.check(); err != nil {
+			failed++
+			fmt.Fprintf(w, "FAIL %s: %s\n", configure.name, err)
+		} else {
+			fmt.Fprintf(w, "ok   %s\n", configure.name)
+		}
+	} else {
+		fmt.Fprintf(w, "SKIP %s\n", configure.name)
+	}
+	checks = append(checks, configure)
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d configuration checks failed", failed, len(checks))
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCheckConfig(t *testing.T) {
+	var buf bytes.Buffer
+	err := CheckConfig(&buf, Config{Key: testKey}, map[string]time.Duration{"read timeout": time.Second})
+	if err != nil {
+		t.Fatalf("got error %v:\n%s", err, buf.String())
+	}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if !strings.HasPrefix(line, "ok   ") {
+			t.Errorf("got %q, want every check to pass", line)
+		}
+	}
+	if !strings.Contains(buf.String(), "ok   the read timeout is not negative\n") {
+		t.Errorf("got %q, want the timeout checked", buf.String())
+	}
+}
+
+func TestCheckConfigFailures(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		cfg  Config
+		want string
+	}{
+		{"no key", Config{}, "FAIL an OMDb API key is set: --key is required"},
+		{"base URL", Config{Key: testKey, BaseURL: "omdbapi.com"}, "FAIL the OMDb base URL is valid"},
+		{"fallback URL", Config{Key: testKey, FallbackURL: "ftp://omdb"}, "FAIL the fallback URL is valid"},
+		{"site directory", Config{Key: testKey, Landing: LandingIndex, SiteDir: "/nonexistent"}, "FAIL the site directory /nonexistent exists"},
+		{"theme", Config{Key: testKey, Landing: LandingIndex, Theme: "blue"}, "FAIL the theme blue is bundled"},
+		{"settings", Config{Key: testKey, CacheTTL: -time.Second}, "FAIL the settings are valid"},
+		{"feature", Config{Key: testKey, DisabledFeatures: []string{"suggest"}}, "FAIL the service can be configured"},
+		{"collation", Config{Key: testKey, Collation: "xx"}, "FAIL the service can be configured"},
+	} {
+		var buf bytes.Buffer
+		if err := CheckConfig(&buf, test.cfg, nil); err == nil {
+			t.Errorf("%s: got no error", test.name)
+		}
+		if !strings.Contains(buf.String(), test.want) {
+			t.Errorf("%s: got %q, want %q", test.name, buf.String(), test.want)
+		}
+	}
+}
+
+func TestCheckConfigSkipsConfiguring(t *testing.T) {
+	var buf bytes.Buffer
+	err := CheckConfig(&buf, Config{}, map[string]time.Duration{"write timeout": -time.Second})
+	if err == nil || err.Error() != "3 of 5 configuration checks failed" {
+		t.Errorf("got error %v", err)
+	}
+	if !strings.Contains(buf.String(), "SKIP the service can be configured with the settings\n") {
+		t.Errorf("got %q, want the configuration skipped", buf.String())
+	}
+}
+
+func TestCheckConfigHistoryFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "history.jsonl")
+	cfg := Config{Key: testKey, HistorySize: 10, HistoryFile: path, AdminToken: "admin"}
+
+	var buf bytes.Buffer
+	if err := CheckConfig(&buf, cfg, nil); err != nil {
+		t.Fatalf("got error %v:\n%s", err, buf.String())
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("got the history file created, or error %v", err)
+	}
+
+	if err := os.WriteFile(path, []byte("not json\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	buf.Reset()
+	if err := CheckConfig(&buf, cfg, nil); err == nil || !strings.Contains(buf.String(), "FAIL the history file "+path+" can be used") {
+		t.Errorf("got error %v and %q, want a corrupt file to fail", err, buf.String())
+	}
+
+	cfg.HistoryFile = filepath.Join(dir, "missing", "history.jsonl")
+	buf.Reset()
+	if err := CheckConfig(&buf, cfg, nil); err == nil {
+		t.Errorf("got no error for a missing directory: %s", buf.String())
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock tells the time. The caches, the quota tracker, the client rate
+// limiter and the retry budget read the time from a Clock, so that their
+// expiry and limiting can be tested with a *FakeClock instead of waiting.
+type Clock interface {
+	Now() time.Time
+}
+
+// realClock is a Clock that reads the system time.
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+// RealClock is the Clock that reads the system time. It's the default.
+var RealClock Clock = realClock{}
+
+// FakeClock is a Clock whose time only changes when it's advanced. It's safe
+// for concurrent use.
+type FakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+// NewFakeClock returns a new *FakeClock set to now.
+func NewFakeClock(now time.Time) *FakeClock {
+	return &FakeClock{now: now}
+}
+
+// Now returns the time of the clock.
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Advance moves the clock forward by d.
+func (c *FakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// SetClock makes the time-dependent parts of s that have been configured read
+// the time from c. It should be called before s starts serving.
+func (s *SearchApp) SetClock(c Clock) {
+	if c == nil {
+		c = RealClock
+	}
+
+	s.details.clock = c
+	s.omdb.quota.SetClock(c)
+	if s.cache != nil {
+		s.cache.clock = c
+	}
+	if s.limiter != nil {
+		s.limiter.clock = c
+	}
+	if s.omdb.shedder != nil {
+		s.omdb.shedder.clock = c
+	}
+	if s.omdb.backoff != nil && s.omdb.backoff.Budget != nil {
+		s.omdb.backoff.Budget.SetClock(c)
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFakeClock(t *testing.T) {
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	clock := NewFakeClock(start)
+	if got := clock.Now(); !got.Equal(start) {
+		t.Errorf("got %s, want %s", got, start)
+	}
+
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			clock.Advance(time.Second)
+			clock.Now()
+		}()
+	}
+	wg.Wait()
+	if got, want := clock.Now(), start.Add(10*time.Second); !got.Equal(want) {
+		t.Errorf("got %s after advancing, want %s", got, want)
+	}
+}
+
+func TestRealClock(t *testing.T) {
+	before := time.Now()
+	now := RealClock.Now()
+	if now.Before(before) || now.After(time.Now()) {
+		t.Errorf("got %s, want the system time", now)
+	}
+}
+
+func TestSetClock(t *testing.T) {
+	omdb := newFakeOMDb(t, pagedResults(5))
+	s := newTestApp(t, omdb, Config{
+		CacheTTL:         time.Minute,
+		DailyQuota:       100,
+		ClientRateLimit:  1,
+		ClientRateWindow: time.Minute,
+	})
+	clock := NewFakeClock(time.Date(2024, 5, 1, 23, 59, 0, 0, time.UTC))
+	s.SetClock(clock)
+
+	if w := serveRequest(s, newSearchRequest(`{"title":"alien"}`)); w.Code != http.StatusOK {
+		t.Fatalf("got status %d, want 200: %s", w.Code, w.Body)
+	}
+	if w := serveRequest(s, newSearchRequest(`{"title":"alien"}`)); w.Code != http.StatusTooManyRequests {
+		t.Errorf("got status %d before the window passed, want 429", w.Code)
+	}
+	if used := s.omdb.quota.Used(); used != 1 {
+		t.Errorf("got %d calls used, want 1", used)
+	}
+
+	// Advancing the clock refills the rate limit, expires the cache and
+	// resets the quota, without waiting.
+	clock.Advance(time.Minute + time.Second)
+	if used := s.omdb.quota.Used(); used != 0 {
+		t.Errorf("got %d calls used after the reset, want 0", used)
+	}
+	if w := serveRequest(s, newSearchRequest(`{"title":"alien"}`)); w.Code != http.StatusOK {
+		t.Errorf("got status %d after the window passed, want 200: %s", w.Code, w.Body)
+	}
+	if n := omdb.calls(); n != 2 {
+		t.Errorf("got %d calls to OMDb, want the cached search to have expired", n)
+	}
+}
+
+func TestSetClockDefault(t *testing.T) {
+	s := newTestApp(t, newFakeOMDb(t, pagedResults(5)), Config{CacheTTL: time.Minute, ClientRateLimit: 1})
+	s.SetClock(NewFakeClock(time.Time{}))
+	s.SetClock(nil)
+
+	if s.cache.clock != RealClock || s.limiter.clock != RealClock {
+		t.Error("SetClock(nil) didn't restore the real clock")
+	}
+}
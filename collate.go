@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"golang.org/x/text/collate"
+	"golang.org/x/text/language"
+)
+
+// Collator compares titles for sorting, returning -1, 0 or 1 like
+// strings.Compare. It must be safe for concurrent use, so a
+// *collate.Collator from golang.org/x/text/collate is wrapped in a
+// LocaleCollator rather than used directly.
+type Collator interface {
+	CompareString(a, b string) int
+}
+
+// LocaleCollator is a Collator that orders titles the way a locale does,
+// e.g. "ñ" after "n" in Spanish, ignoring case and accents that the locale
+// doesn't sort as letters of their own. It's safe for concurrent use, which
+// a *collate.Collator alone isn't.
+type LocaleCollator struct {
+	mu sync.Mutex
+	c  *collate.Collator
+}
+
+// DefaultCollator is the Collator used when none is set. It's the root
+// Unicode collation order, ignoring case and accents.
+var DefaultCollator Collator = newLocaleCollator(language.Und)
+
+// NewLocaleCollator returns the *LocaleCollator for locale, a language tag
+// such as "sv" or "es-MX". An empty locale is DefaultCollator's order.
+func NewLocaleCollator(locale string) (*LocaleCollator, error) {
+	if locale == "" {
+		return newLocaleCollator(language.Und), nil
+	}
+	tag, err := language.Parse(locale)
+	if err != nil {
+		return nil, fmt.Errorf("invalid collation locale %q: %w", locale, err)
+	}
+	return newLocaleCollator(tag), nil
+}
+
+func newLocaleCollator(tag language.Tag) *LocaleCollator {
+	return &LocaleCollator{c: collate.New(tag, collate.IgnoreCase, collate.IgnoreDiacritics)}
+}
+
+// CompareString compares a and b in the locale's order.
+func (c *LocaleCollator) CompareString(a, b string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.c.CompareString(a, b)
+}
+
+// SetCollator sets the order of the titles of the results merged from
+// several pages. A nil c restores DefaultCollator.
+func (o *OMDBAPI) SetCollator(c Collator) {
+	o.collator = c
+}
+
+// compareResults orders a and b by title with c, then by year, then by IMDb
+// ID, so that results with titles c considers equal still have a stable
+// order.
+func compareResults(c Collator, a, b *SearchResult) int {
+	if c == nil {
+		c = DefaultCollator
+	}
+	if n := c.CompareString(a.Title, b.Title); n != 0 {
+		return n
+	}
+	if n := strings.Compare(a.Year, b.Year); n != 0 {
+		return n
+	}
+	return strings.Compare(a.IMDBID, b.IMDBID)
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// sortTitles returns titles sorted with c.
+func sortTitles(c Collator, titles ...string) []string {
+	sorted := append([]string(nil), titles...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return c.CompareString(sorted[i], sorted[j]) < 0
+	})
+	return sorted
+}
+
+func TestNewLocaleCollator(t *testing.T) {
+	for _, locale := range []string{"", "en", "en-US", "de_DE", "es", "es-MX", "SV", "sv_SE", "da", "nb"} {
+		if _, err := NewLocaleCollator(locale); err != nil {
+			t.Errorf("%q: got error %v", locale, err)
+		}
+	}
+	for _, locale := range []string{"not a locale", "xx", "en-"} {
+		if _, err := NewLocaleCollator(locale); err == nil {
+			t.Errorf("%q: got no error for an invalid locale", locale)
+		}
+	}
+}
+
+func TestLocaleCollator(t *testing.T) {
+	for _, test := range []struct {
+		locale string
+		titles []string
+		want   []string
+	}{
+		{"", []string{"Zorro", "Ödipus", "Amélie", "anna"}, []string{"Amélie", "anna", "Ödipus", "Zorro"}},
+		{"en", []string{"Ñu", "Oso", "Nzinga", "Nube"}, []string{"Ñu", "Nube", "Nzinga", "Oso"}},
+		{"es", []string{"Ñu", "Oso", "Nzinga", "Nube"}, []string{"Nube", "Nzinga", "Ñu", "Oso"}},
+		{"es", []string{"Año", "Anzuelo", "Ao"}, []string{"Anzuelo", "Año", "Ao"}},
+		{"sv", []string{"Ödla", "Ärlig", "Åsa", "Zorro", "Alfons"}, []string{"Alfons", "Zorro", "Åsa", "Ärlig", "Ödla"}},
+		{"da", []string{"Åsa", "Ødla", "Ærlig", "Zorro"}, []string{"Zorro", "Ærlig", "Ødla", "Åsa"}},
+	} {
+		c, err := NewLocaleCollator(test.locale)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := sortTitles(c, test.titles...); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%q: got %v, want %v", test.locale, got, test.want)
+		}
+	}
+}
+
+func TestLocaleCollatorFolds(t *testing.T) {
+	sv, _ := NewLocaleCollator("sv")
+	for _, c := range []Collator{DefaultCollator, sv} {
+		if n := c.CompareString("AMÉLIE", "amelie"); n != 0 {
+			t.Errorf("%T: got %d comparing titles that only differ by case and accent, want 0", c, n)
+		}
+	}
+}
+
+func TestCompareResultsTies(t *testing.T) {
+	results := []*SearchResult{
+		{Title: "Amélie", Year: "2001", IMDBID: "tt3"},
+		{Title: "amelie", Year: "2001", IMDBID: "tt2"},
+		{Title: "Amelie", Year: "1999", IMDBID: "tt9"},
+	}
+	if got, want := ids(stableResults(results, nil)), []string{"tt9", "tt2", "tt3"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want the equal titles ordered by year, then ID", got)
+	}
+}
+
+// reverseCollator is a Collator that orders titles backwards.
+type reverseCollator struct{}
+
+func (reverseCollator) CompareString(a, b string) int {
+	return strings.Compare(b, a)
+}
+
+func TestSetCollator(t *testing.T) {
+	omdb := newFakeOMDb(t, pagedResults(3))
+	api := newTestAPI(t, omdb)
+
+	api.SetCollator(reverseCollator{})
+	results, err := api.SearchAll(context.Background(), &SearchRequest{Title: "alien"}, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := ids(results), []string{"tt3", "tt2", "tt1"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	api.SetCollator(nil)
+	results, _ = api.SearchAll(context.Background(), &SearchRequest{Title: "alien"}, 10)
+	if got, want := ids(results), []string{"tt1", "tt2", "tt3"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v with the default collator, want %v", got, want)
+	}
+}
+
+func TestCollationConfig(t *testing.T) {
+	s := newTestApp(t, newFakeOMDb(t, pagedResults(1)), Config{Collation: "es"})
+	if got := s.omdb.collator.CompareString("ñ", "o"); got >= 0 {
+		t.Errorf("got %d comparing ñ with o, want it sorted before", got)
+	}
+
+	if _, err := NewSearchAppWithConfig(Config{Key: testKey, Collation: "not a locale"}); err == nil {
+		t.Error("got no error for an invalid collation")
+	}
+}
+
+func TestLocaleCollatorConcurrent(t *testing.T) {
+	c, _ := NewLocaleCollator("sv")
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 100 {
+				if c.CompareString("Åsa", "Zorro") <= 0 {
+					t.Error("got Åsa before Zorro in Swedish")
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// CompareSide is one of the titles in a *Comparison. Only one of Detail and
+// Error is set.
+type CompareSide struct {
+	IMDBID string  `json:"imdbID"`
+	Detail *Detail `json:"detail,omitempty"`
+	Error  string  `json:"error,omitempty"`
+}
+
+// Comparison is the response of /compare. The differences are A's value
+// minus B's, and are only set when both titles have the value.
+type Comparison struct {
+	A CompareSide `json:"a"`
+	B CompareSide `json:"b"`
+
+	RatingDifference  *float64 `json:"rating_difference,omitempty"`
+	RuntimeDifference *int     `json:"runtime_difference_minutes,omitempty"`
+}
+
+// parseRuntime returns the minutes of the runtime r, e.g. 136 min, and false
+// if it's N/A.
+func parseRuntime(r string) (int, bool) {
+	minutes, err := strconv.Atoi(strings.TrimSuffix(r, " min"))
+	return minutes, err == nil
+}
+
+// compare returns the comparison of the details a and b, either of which may
+// be nil.
+func compare(a, b *Detail) *Comparison {
+	c := &Comparison{}
+	if a == nil || b == nil {
+		return c
+	}
+
+	ra, okA := parseRating(a.IMDBRating)
+	rb, okB := parseRating(b.IMDBRating)
+	if okA && okB {
+		diff := math.Round((ra-rb)*10) / 10
+		c.RatingDifference = &diff
+	}
+
+	ma, okA := parseRuntime(a.Runtime)
+	mb, okB := parseRuntime(b.Runtime)
+	if okA && okB {
+		diff := ma - mb
+		c.RuntimeDifference = &diff
+	}
+	return c
+}
+
+// compareSide returns the side of a comparison for id, whose lookup returned
+// detail and err.
+func (s *SearchApp) compareSide(id string, detail *Detail, err error) CompareSide {
+	side := CompareSide{IMDBID: id, Detail: detail}
+	switch {
+	case err == nil:
+	case errors.Is(err, ErrMovieNotFound):
+		side.Error = "title not found"
+	default:
+		s.notifier.Record(err)
+		s.recentErrors.Add(err)
+		side.Error = detailUnavailable
+	}
+	return side
+}
+
+// Compare handles requests to /compare, looking up the titles with the IMDb
+// IDs in the a and b query parameters concurrently and comparing them. A
+// title that's malformed, not found or can't be looked up has an error
+// instead of its detail, and the differences are left out.
+func (s *SearchApp) Compare(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.NotFound(w, r)
+		return
+	}
+
+	q, err := s.query(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ids := []string{strings.TrimSpace(q.Get("a")), strings.TrimSpace(q.Get("b"))}
+	if ids[0] == "" || ids[1] == "" {
+		http.Error(w, "the a and b query parameters are required, e.g. ?a=tt0133093&b=tt0234215", http.StatusBadRequest)
+		return
+	}
+
+	// Malformed IDs aren't looked up, to save the quota.
+	var lookup []string
+	for _, id := range ids {
+		if imdbIDPattern.MatchString(id) {
+			lookup = append(lookup, id)
+		}
+	}
+	found, errs := s.GetByIDs(r.Context(), lookup)
+
+	details := make([]*Detail, len(ids))
+	sides := make([]CompareSide, len(ids))
+	next := 0
+	for i, id := range ids {
+		if !imdbIDPattern.MatchString(id) {
+			sides[i] = CompareSide{IMDBID: id, Error: fmt.Sprintf("invalid IMDb ID %q", id)}
+			continue
+		}
+		details[i] = found[next]
+		sides[i] = s.compareSide(id, found[next], errs[next])
+		next++
+	}
+
+	comparison := compare(details[0], details[1])
+	comparison.A, comparison.B = sides[0], sides[1]
+
+	jsonstr, err := json.Marshal(comparison)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(jsonstr)
+}
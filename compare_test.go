@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+// compareOMDb returns a handler that looks up the details in details by IMDb
+// ID, and fails the lookups of the IDs in failing.
+func compareOMDb(details map[string]*Detail, failing map[string]bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.URL.Query().Get("i")
+		if failing[id] {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		d, ok := details[id]
+		if !ok {
+			writeError(w, "Incorrect IMDb ID.")
+			return
+		}
+		writeDetail(w, d)
+	}
+}
+
+var compareDetails = map[string]*Detail{
+	"tt111": {Title: "Alien", IMDBID: "tt111", IMDBRating: "8.5", Runtime: "117 min"},
+	"tt222": {Title: "Aliens", IMDBID: "tt222", IMDBRating: "8.4", Runtime: "137 min"},
+	"tt333": {Title: "Alien 3", IMDBID: "tt333", IMDBRating: "N/A", Runtime: "N/A"},
+}
+
+// getComparison requests /compare?a=a&b=b and decodes the comparison.
+func getComparison(t *testing.T, s *SearchApp, a, b string) *Comparison {
+	t.Helper()
+	w := send(s, "GET", "/compare?a="+a+"&b="+b, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("got status %d, want 200: %s", w.Code, w.Body)
+	}
+	var c Comparison
+	if err := json.Unmarshal(w.Body.Bytes(), &c); err != nil {
+		t.Fatal(err)
+	}
+	return &c
+}
+
+func TestCompare(t *testing.T) {
+	omdb := newFakeOMDb(t, compareOMDb(compareDetails, nil))
+	s := newTestApp(t, omdb, Config{})
+
+	c := getComparison(t, s, "tt111", "tt222")
+	if c.A.Detail == nil || c.A.Detail.Title != "Alien" || c.A.Error != "" {
+		t.Errorf("got side a %+v, want the detail of Alien", c.A)
+	}
+	if c.B.Detail == nil || c.B.Detail.Title != "Aliens" || c.B.Error != "" {
+		t.Errorf("got side b %+v, want the detail of Aliens", c.B)
+	}
+	if c.RatingDifference == nil || *c.RatingDifference != 0.1 {
+		t.Errorf("got rating difference %v, want 0.1", c.RatingDifference)
+	}
+	if c.RuntimeDifference == nil || *c.RuntimeDifference != -20 {
+		t.Errorf("got runtime difference %v, want -20", c.RuntimeDifference)
+	}
+	if n := omdb.calls(); n != 2 {
+		t.Errorf("got %d calls to OMDb, want 2", n)
+	}
+}
+
+func TestCompareMissingValues(t *testing.T) {
+	s := newTestApp(t, newFakeOMDb(t, compareOMDb(compareDetails, nil)), Config{})
+
+	c := getComparison(t, s, "tt111", "tt333")
+	if c.A.Detail == nil || c.B.Detail == nil {
+		t.Fatalf("got sides %+v and %+v, want both details", c.A, c.B)
+	}
+	if c.RatingDifference != nil || c.RuntimeDifference != nil {
+		t.Errorf("got differences %v and %v, want none for N/A values", c.RatingDifference, c.RuntimeDifference)
+	}
+}
+
+func TestCompareOneMissing(t *testing.T) {
+	captureLog(t)
+	omdb := newFakeOMDb(t, compareOMDb(compareDetails, map[string]bool{"tt444": true}))
+	s := newTestApp(t, omdb, Config{})
+
+	for _, test := range []struct {
+		name, a, b, wantErr string
+	}{
+		{"not found", "tt111", "tt999", "title not found"},
+		{"invalid", "alien", "tt111", `invalid IMDb ID "alien"`},
+		{"unavailable", "tt111", "tt444", detailUnavailable},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			c := getComparison(t, s, test.a, test.b)
+			found, missing := c.A, c.B
+			if test.a != "tt111" {
+				found, missing = c.B, c.A
+			}
+			if found.Detail == nil || found.Error != "" {
+				t.Errorf("got %+v for the found title, want its detail", found)
+			}
+			if missing.Detail != nil || missing.Error != test.wantErr {
+				t.Errorf("got %+v for the missing title, want error %q", missing, test.wantErr)
+			}
+			if c.RatingDifference != nil || c.RuntimeDifference != nil {
+				t.Errorf("got differences %v and %v, want none", c.RatingDifference, c.RuntimeDifference)
+			}
+		})
+	}
+}
+
+func TestCompareBothMissing(t *testing.T) {
+	omdb := newFakeOMDb(t, compareOMDb(compareDetails, nil))
+	s := newTestApp(t, omdb, Config{})
+
+	c := getComparison(t, s, "tt998", "bogus")
+	if c.A.Error != "title not found" || c.B.Error != `invalid IMDb ID "bogus"` {
+		t.Errorf("got errors %q and %q, want both sides reported", c.A.Error, c.B.Error)
+	}
+	if n := omdb.calls(); n != 1 {
+		t.Errorf("got %d calls to OMDb, want 1, as the invalid ID isn't looked up", n)
+	}
+}
+
+func TestCompareRequiresBoth(t *testing.T) {
+	s := newTestApp(t, newFakeOMDb(t, compareOMDb(compareDetails, nil)), Config{})
+
+	for _, target := range []string{"/compare", "/compare?a=tt111", "/compare?b=tt111&a=%20"} {
+		if w := send(s, "GET", target, ""); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: got status %d, want 400", target, w.Code)
+		}
+	}
+}
@@ -0,0 +1,540 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+// Config holds the settings for a *SearchApp. The zero value of each field,
+// other than Key, is replaced with its default by NewSearchAppWithConfig.
+type Config struct {
+	// Key is the OMDb API key. It's required.
+	Key string
+
+	// BaseURL is the base URL of the OMDb API. FallbackURL, if set, is the
+	// base URL of a secondary OMDb API to use when the primary fails.
+	BaseURL     string
+	FallbackURL string
+
+	// InsecureSkipVerify doesn't verify the TLS certificates of the OMDb and
+	// fallback APIs. It's only for development.
+	InsecureSkipVerify bool
+
+	// JSONCase is the casing of JSON keys in search responses, either
+	// PascalCase or CamelCase.
+	JSONCase string
+
+	// MaxTitleLength is the maximum number of characters in a search title.
+	MaxTitleLength int
+
+	// MinTitleLength is the minimum number of characters in a search title,
+	// as a single letter matches too many titles for OMDb to return them.
+	// Defaults to 2; 1 allows any title.
+	MinTitleLength int
+
+	// BodyTimeout is how long a client has to send a request body.
+	BodyTimeout time.Duration
+
+	// NoContentOnEmpty responds with a 204 instead of an empty array when a
+	// search matches nothing.
+	NoContentOnEmpty bool
+
+	// TitleCase normalizes result titles to title case.
+	TitleCase bool
+
+	// IMDBURLs includes the URL of each result's IMDb page in search
+	// responses.
+	IMDBURLs bool
+
+	// QuerySyntax parses key:value tokens, like type:movie, out of search
+	// titles. See ParseQuery.
+	QuerySyntax bool
+
+	// AllowedTypes are the types that may be searched for. All types are
+	// allowed if empty.
+	AllowedTypes []string
+
+	// CacheTTL is how long search results are cached for, and CacheSize is
+	// the maximum number of searches cached. NegativeCacheTTL is how long
+	// searches that match nothing are cached for. Caching is disabled if both
+	// TTLs are zero.
+	CacheTTL         time.Duration
+	NegativeCacheTTL time.Duration
+	CacheSize        int
+
+	// RefreshAhead, if set, refreshes the cached results that have been read
+	// at least RefreshAheadHits times in the background once they're within
+	// RefreshAhead of expiring. It must be less than CacheTTL.
+	RefreshAhead     time.Duration
+	RefreshAheadHits int
+
+	// CacheKeys is the strategy for the keys that search results are cached
+	// under, either CacheKeyPage or CacheKeyTerm.
+	CacheKeys string
+
+	// CoalesceWindow, if set, shares the results of a search with identical
+	// searches that arrive within it of the upstream call completing, as
+	// well as those that arrive while it's in flight.
+	CoalesceWindow time.Duration
+
+	// MaxPages caps the number of pages fetched for a single search by jobs,
+	// exports and the term cache. It's only limited by OMDb if zero.
+	MaxPages int
+
+	// Collation is the locale, such as "sv" or "es", whose alphabetical
+	// order is used for the results merged from several pages. It's the
+	// root Unicode collation order, ignoring case and accents, if empty.
+	Collation string
+
+	// PageFailures is what SearchAll and search jobs do when a page fails
+	// after earlier ones succeeded, either PageFailuresFailFast, the
+	// default, or PageFailuresBestEffort.
+	PageFailures string
+
+	// Retries is the number of times to retry OMDb requests that fail with a
+	// transient error, with the delays between them set by RetryDelay,
+	// RetryMaxDelay and RetryJitter. RetryBudget, if set, caps the retries
+	// across all requests per RetryBudgetWindow.
+	Retries           int
+	RetryDelay        time.Duration
+	RetryMaxDelay     time.Duration
+	RetryJitter       string
+	RetryBudget       int
+	RetryBudgetWindow time.Duration
+
+	// ClientRateLimit, if set, caps the requests from each client IP per
+	// ClientRateWindow to /search and the other endpoints that call OMDb.
+	// TrustProxy takes the client IP from the X-Forwarded-For header.
+	ClientRateLimit  int
+	ClientRateWindow time.Duration
+	TrustProxy       bool
+
+	// ShedErrorRate and ShedLatency, if either is set, shed a fraction of
+	// requests to /search and the other endpoints that call OMDb with a 503
+	// while the error rate or mean latency of OMDb calls within ShedWindow is
+	// over them, once there have been ShedMinCalls calls in the window.
+	ShedErrorRate float64
+	ShedLatency   time.Duration
+	ShedWindow    time.Duration
+	ShedMinCalls  int
+
+	// DailyQuota is the daily request limit of the API key, which resets at
+	// QuotaResetHour in UTC. QuotaHeader adds an X-Quota-Remaining header to
+	// search responses.
+	DailyQuota     int
+	QuotaResetHour int
+	QuotaHeader    bool
+
+	// Landing is what to serve for /. SiteDir and Theme are only used by
+	// LandingIndex, and LandingURL is only used by LandingRedirect. Theme, if
+	// set, is the bundled theme served instead of SiteDir. If RequireSite is
+	// set, a missing site directory is an error rather than a warning.
+	Landing     string
+	SiteDir     string
+	Theme       string
+	LandingURL  string
+	RequireSite bool
+
+	// StaticExtensions are the extensions of the files served from the site
+	// directory, e.g. css. Defaults to html, css, js, png, jpg and svg.
+	StaticExtensions []string
+
+	// NotFoundPage is the page in the site directory that's served for
+	// static files that don't exist. Defaults to 404.html.
+	NotFoundPage string
+
+	// PosterHosts are the hosts that the poster proxy fetches images from.
+	// Defaults to the hosts of the poster URLs returned by OMDb.
+	PosterHosts []string
+
+	// BasePath is the path prefix to serve all routes under.
+	BasePath string
+
+	// AllowedOrigins are the origins that browsers may POST from. Any origin
+	// is allowed if empty.
+	AllowedOrigins []string
+
+	// DisabledFeatures are the features whose endpoints aren't served, e.g.
+	// FeatureExport.
+	DisabledFeatures []string
+
+	// CursorSecret is the secret that pagination cursors are signed with. A
+	// random one is used if it's empty, so cursors can't be used across
+	// restarts, or across instances behind a load balancer.
+	CursorSecret string
+
+	// DuplicateParams is how the GET endpoints handle a query parameter
+	// given more than once, one of DuplicateReject, DuplicateFirst or
+	// DuplicateLast.
+	DuplicateParams string
+
+	// DocsURL is the documentation linked to from error responses.
+	DocsURL string
+
+	// AdminToken is the bearer token for the /admin endpoints, which are
+	// disabled if it's empty.
+	AdminToken string
+
+	// ClientKeys lets clients make searches with their own OMDb API key, in
+	// the X-OMDb-Key header, instead of Key.
+	ClientKeys bool
+
+	// Pprof serves profiles under /debug/pprof/. It requires AdminToken,
+	// which they're protected by.
+	Pprof bool
+
+	// Dashboard serves an HTML page of live stats at /debug. It requires
+	// AdminToken, which the page is protected by.
+	Dashboard bool
+
+	// Debug logs the URL of each OMDb request, and UpstreamErrors includes
+	// OMDb's error responses in error responses.
+	Debug          bool
+	UpstreamErrors bool
+
+	// LogBodies also logs the JSON request bodies of searches and OMDb's
+	// response bodies in debug mode, redacted and cut to BodyLogLimit
+	// bytes, which defaults to 4096. It's very verbose.
+	LogBodies    bool
+	BodyLogLimit int
+
+	// ReadyWindow is how long /readyz fails for after a failed OMDb call,
+	// unless a later call succeeds. It's disabled if zero.
+	ReadyWindow time.Duration
+
+	// HistorySize is the number of recent searches listed by
+	// /search/history. It's disabled if zero, as it reveals what other
+	// clients have searched for, and requires AdminToken, which it's
+	// protected by.
+	HistorySize int
+
+	// HistoryFile, if set, is a file that the search history is kept in,
+	// so that it survives restarts, rather than only in memory.
+	HistoryFile string
+
+	// LatencyQuantiles reports estimated upstream latency percentiles in
+	// /metrics, for when the histogram can't be queried.
+	LatencyQuantiles bool
+
+	// WebhookURL, if set, is POSTed to when WebhookThreshold upstream errors
+	// of one type happen within WebhookWindow.
+	WebhookURL       string
+	WebhookThreshold int
+	WebhookWindow    time.Duration
+	WebhookTimeout   time.Duration
+
+	// Clock, if set, is used for cache expiry, rate limiting, quota
+	// tracking and the retry budget instead of the system time. It's for
+	// tests.
+	Clock Clock
+}
+
+// withDefaults returns a copy of c with the defaults in place of zero values.
+func (c Config) withDefaults() Config {
+	if c.BaseURL == "" {
+		c.BaseURL = defaultBaseURL
+	}
+	if c.JSONCase == "" {
+		c.JSONCase = PascalCase
+	}
+	if c.MaxTitleLength == 0 {
+		c.MaxTitleLength = 256
+	}
+	if c.MinTitleLength == 0 {
+		c.MinTitleLength = defaultMinTitleLength
+	}
+	if c.BodyTimeout == 0 {
+		c.BodyTimeout = defaultBodyTimeout
+	}
+	if c.BodyLogLimit == 0 {
+		c.BodyLogLimit = defaultBodyLogLimit
+	}
+	if c.CacheSize == 0 {
+		c.CacheSize = 1000
+	}
+	if c.RefreshAheadHits == 0 {
+		c.RefreshAheadHits = 5
+	}
+	if c.CacheKeys == "" {
+		c.CacheKeys = CacheKeyPage
+	}
+	if c.PageFailures == "" {
+		c.PageFailures = PageFailuresFailFast
+	}
+	if c.RetryDelay == 0 {
+		c.RetryDelay = 100 * time.Millisecond
+	}
+	if c.RetryMaxDelay == 0 {
+		c.RetryMaxDelay = 2 * time.Second
+	}
+	if c.RetryBudgetWindow == 0 {
+		c.RetryBudgetWindow = time.Minute
+	}
+	if c.RetryJitter == "" {
+		c.RetryJitter = JitterFull
+	}
+	if c.DailyQuota == 0 {
+		c.DailyQuota = defaultDailyQuota
+	}
+	if c.DuplicateParams == "" {
+		c.DuplicateParams = DuplicateReject
+	}
+	if c.DocsURL == "" {
+		c.DocsURL = defaultDocsURL
+	}
+	if c.Landing == "" {
+		c.Landing = LandingGreeting
+	}
+	if c.SiteDir == "" {
+		c.SiteDir = "site"
+	}
+	if len(c.StaticExtensions) == 0 {
+		c.StaticExtensions = defaultStaticExtensions
+	}
+	if c.NotFoundPage == "" {
+		c.NotFoundPage = defaultNotFoundPage
+	}
+	if len(c.PosterHosts) == 0 {
+		c.PosterHosts = defaultPosterHosts
+	}
+	if c.ClientRateWindow == 0 {
+		c.ClientRateWindow = time.Minute
+	}
+	if c.ShedWindow == 0 {
+		c.ShedWindow = time.Minute
+	}
+	if c.ShedMinCalls == 0 {
+		c.ShedMinCalls = 20
+	}
+	if c.WebhookThreshold == 0 {
+		c.WebhookThreshold = 5
+	}
+	if c.WebhookWindow == 0 {
+		c.WebhookWindow = 5 * time.Minute
+	}
+	if c.WebhookTimeout == 0 {
+		c.WebhookTimeout = 5 * time.Second
+	}
+	return c
+}
+
+// validate returns an error for the first setting in c that isn't valid.
+// Settings that are checked when they're applied, like the landing mode,
+// aren't checked here.
+func (c Config) validate() error {
+	switch {
+	case c.Key == "":
+		return errors.New("an OMDb API key is required")
+	case c.MaxTitleLength < 0:
+		return fmt.Errorf("max title length must not be negative, got %d", c.MaxTitleLength)
+	case c.MinTitleLength < 0:
+		return fmt.Errorf("min title length must not be negative, got %d", c.MinTitleLength)
+	case c.MinTitleLength > c.MaxTitleLength:
+		return fmt.Errorf("min title length must not be more than the max title length, got %d", c.MinTitleLength)
+	case c.BodyTimeout < 0:
+		return fmt.Errorf("body timeout must not be negative, got %s", c.BodyTimeout)
+	case c.CacheTTL < 0:
+		return fmt.Errorf("cache TTL must not be negative, got %s", c.CacheTTL)
+	case c.NegativeCacheTTL < 0:
+		return fmt.Errorf("negative cache TTL must not be negative, got %s", c.NegativeCacheTTL)
+	case c.CacheSize < 0:
+		return fmt.Errorf("cache size must not be negative, got %d", c.CacheSize)
+	case c.CoalesceWindow < 0:
+		return fmt.Errorf("coalesce window must not be negative, got %s", c.CoalesceWindow)
+	case c.RefreshAhead < 0 || c.RefreshAheadHits < 0:
+		return errors.New("refresh-ahead settings must not be negative")
+	case c.RefreshAhead > 0 && c.RefreshAhead >= c.CacheTTL:
+		return fmt.Errorf("refresh-ahead window must be less than the cache TTL, got %s", c.RefreshAhead)
+	case c.LogBodies && !c.Debug:
+		return errors.New("logging bodies requires debug mode")
+	case c.BodyLogLimit < 0:
+		return fmt.Errorf("body log limit must not be negative, got %d", c.BodyLogLimit)
+	case c.MaxPages < 0:
+		return fmt.Errorf("max pages must not be negative, got %d", c.MaxPages)
+	case c.Retries < 0:
+		return fmt.Errorf("retries must not be negative, got %d", c.Retries)
+	case c.RetryDelay < 0 || c.RetryMaxDelay < 0:
+		return errors.New("retry delays must not be negative")
+	case c.RetryBudget < 0 || c.RetryBudgetWindow < 0:
+		return errors.New("retry budget must not be negative")
+	case c.ClientRateLimit < 0 || c.ClientRateWindow < 0:
+		return errors.New("client rate limit must not be negative")
+	case c.ShedErrorRate < 0 || c.ShedErrorRate >= 1:
+		return fmt.Errorf("shed error rate must be at least 0 and less than 1, got %g", c.ShedErrorRate)
+	case c.ShedLatency < 0 || c.ShedWindow < 0 || c.ShedMinCalls < 0:
+		return errors.New("load shedding settings must not be negative")
+	case c.DailyQuota < 0:
+		return fmt.Errorf("daily quota must not be negative, got %d", c.DailyQuota)
+	case c.QuotaResetHour < 0 || c.QuotaResetHour > 23:
+		return fmt.Errorf("quota reset hour must be between 0 and 23, got %d", c.QuotaResetHour)
+	case c.ReadyWindow < 0:
+		return fmt.Errorf("ready window must not be negative, got %s", c.ReadyWindow)
+	case c.HistorySize < 0:
+		return fmt.Errorf("history size must not be negative, got %d", c.HistorySize)
+	case c.HistoryFile != "" && c.HistorySize == 0:
+		return errors.New("a history file requires a history size")
+	case c.HistorySize > 0 && c.AdminToken == "":
+		return errors.New("the search history requires an admin token")
+	case c.Dashboard && c.AdminToken == "":
+		return errors.New("the dashboard requires an admin token")
+	case c.Pprof && c.AdminToken == "":
+		return errors.New("pprof requires an admin token")
+	case c.WebhookThreshold < 0:
+		return fmt.Errorf("webhook threshold must not be negative, got %d", c.WebhookThreshold)
+	}
+	if err := validCacheKeyStrategy(c.CacheKeys); err != nil {
+		return err
+	}
+	if err := validDuplicateParams(c.DuplicateParams); err != nil {
+		return err
+	}
+	if err := validPageFailures(c.PageFailures); err != nil {
+		return err
+	}
+	return validJSONCase(c.JSONCase)
+}
+
+// apply configures s with the settings in c, which already has its defaults.
+func (s *SearchApp) apply(c Config) error {
+	s.jsonCase = c.JSONCase
+	s.maxTitleLength = c.MaxTitleLength
+	s.minTitleLength = c.MinTitleLength
+	s.bodyTimeout = c.BodyTimeout
+	s.noContentOnEmpty = c.NoContentOnEmpty
+	s.titleCase = c.TitleCase
+	s.imdbURLs = c.IMDBURLs
+	s.clientKeys = c.ClientKeys
+	s.docsURL = c.DocsURL
+	s.duplicateParams = c.DuplicateParams
+
+	if c.QuerySyntax {
+		s.Use(applyQuery)
+	}
+	s.readyWindow = c.ReadyWindow
+	s.flights.window = c.CoalesceWindow
+	if c.HistoryFile != "" {
+		store, err := OpenFileHistory(c.HistoryFile, c.HistorySize)
+		if err != nil {
+			return err
+		}
+		s.SetHistoryStore(store, c.AdminToken)
+	} else if c.HistorySize > 0 {
+		s.SetHistoryStore(NewSearchHistory(c.HistorySize), c.AdminToken)
+	}
+	s.latencyQuantiles = c.LatencyQuantiles
+	s.upstreamErrors = c.UpstreamErrors
+	s.quotaHeader = c.QuotaHeader
+	s.omdb.debug = c.Debug
+	if c.LogBodies {
+		s.omdb.SetBodyLogging(c.BodyLogLimit)
+	}
+	s.omdb.quota = NewQuotaTracker(c.DailyQuota, c.QuotaResetHour)
+	s.omdb.SetMaxPages(c.MaxPages)
+	s.omdb.SetInsecureSkipVerify(c.InsecureSkipVerify)
+
+	collator, err := NewLocaleCollator(c.Collation)
+	if err != nil {
+		return err
+	}
+	s.omdb.SetCollator(collator)
+	if err = s.omdb.SetPageFailures(c.PageFailures); err != nil {
+		return err
+	}
+	if c.Retries > 0 {
+		if s.omdb.backoff, err = NewBackoff(c.Retries, c.RetryDelay, c.RetryMaxDelay, c.RetryJitter); err != nil {
+			return err
+		}
+		if c.RetryBudget > 0 {
+			s.omdb.backoff.Budget = NewRetryBudget(c.RetryBudget, c.RetryBudgetWindow)
+		}
+	}
+
+	if c.ClientRateLimit > 0 {
+		s.limiter = NewClientLimiter(c.ClientRateLimit, c.ClientRateWindow, c.TrustProxy)
+	}
+
+	if c.ShedErrorRate > 0 || c.ShedLatency > 0 {
+		s.omdb.shedder = NewLoadShedder(c.ShedErrorRate, c.ShedLatency, c.ShedWindow, c.ShedMinCalls)
+	}
+
+	if len(c.AllowedTypes) > 0 {
+		if err = s.SetAllowedTypes(c.AllowedTypes); err != nil {
+			return err
+		}
+	}
+
+	if err = s.SetLanding(c.Landing, c.SiteDir, c.LandingURL); err != nil {
+		return err
+	}
+	if c.Landing == LandingIndex {
+		s.SetTheme(c.Theme)
+	}
+	if err = s.CheckSiteDir(c.RequireSite); err != nil {
+		return err
+	}
+	if err = s.SetStaticExtensions(c.StaticExtensions); err != nil {
+		return err
+	}
+	if err = s.SetNotFoundPage(c.NotFoundPage); err != nil {
+		return err
+	}
+	if err = s.SetPosterHosts(c.PosterHosts); err != nil {
+		return err
+	}
+	s.SetBasePath(c.BasePath)
+
+	if err = s.SetAllowedOrigins(c.AllowedOrigins); err != nil {
+		return err
+	}
+
+	if c.FallbackURL != "" {
+		secondary, err := InitWithURL(c.FallbackURL, c.Key)
+		if err != nil {
+			return err
+		}
+		secondary.debug = c.Debug
+		secondary.bodyLogLimit = s.omdb.bodyLogLimit
+		secondary.SetInsecureSkipVerify(c.InsecureSkipVerify)
+		secondary.SetCollator(collator)
+
+		// The secondary is called with the same key, on behalf of the same
+		// searches, so it shares the primary's retry budget, quota and load
+		// shedding rather than having its own, and SetClock reaches them
+		// through the primary.
+		secondary.backoff = s.omdb.backoff
+		secondary.quota = s.omdb.quota
+		secondary.shedder = s.omdb.shedder
+		s.searchAPI = NewFallbackAPI(s.omdb, secondary)
+	}
+
+	if c.CacheTTL > 0 || c.NegativeCacheTTL > 0 {
+		s.cache = NewSearchCache(c.CacheTTL, c.CacheSize)
+		s.cache.SetNegativeTTL(c.NegativeCacheTTL)
+		s.cache.SetRefreshAhead(c.RefreshAhead, c.RefreshAheadHits)
+		s.cacheKeys = c.CacheKeys
+	}
+
+	if c.AdminToken != "" {
+		s.EnableAdmin(c.AdminToken)
+	}
+
+	if c.Dashboard {
+		s.EnableDashboard(c.AdminToken)
+	}
+
+	if c.Pprof {
+		log.Println("pprof is enabled, only expose this instance in trusted environments")
+		s.EnablePprof(c.AdminToken)
+	}
+
+	if c.WebhookURL != "" {
+		s.notifier = NewErrorNotifier(c.WebhookURL, c.WebhookThreshold, c.WebhookWindow, c.WebhookTimeout)
+	}
+
+	if c.Clock != nil {
+		s.SetClock(c.Clock)
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// cursorTTL is how long after the first page of a search its cursors can be
+// used for.
+const cursorTTL = time.Hour
+
+// cursorPayload is the signed content of a pagination cursor. Snapshot is the
+// time, in Unix seconds, that the first page of the search was returned. It's
+// carried over to the cursor for each following page, so that all of a
+// search's cursors expire cursorTTL after it started. It only sets when the
+// cursors expire: each page is searched for, or read from the cache, when
+// it's asked for, so the results can still shift between pages if OMDb's do.
+type cursorPayload struct {
+	Title       string `json:"t"`
+	Type        string `json:"y,omitempty"`
+	ReleaseYear string `json:"r,omitempty"`
+	Page        int    `json:"p"`
+	Snapshot    int64  `json:"s"`
+}
+
+// newCursorSecret returns a random secret for signing cursors. Cursors signed
+// with it can't be used once the service restarts.
+func newCursorSecret() []byte {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		panic(err)
+	}
+	return secret
+}
+
+// signCursor returns the HMAC of payload.
+func (s *SearchApp) signCursor(payload []byte) []byte {
+	mac := hmac.New(sha256.New, s.cursorSecret)
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
+
+// encodeCursor returns the opaque cursor for p.
+func (s *SearchApp) encodeCursor(p *cursorPayload) string {
+	payload, _ := json.Marshal(p)
+	enc := base64.RawURLEncoding
+	return enc.EncodeToString(payload) + "." + enc.EncodeToString(s.signCursor(payload))
+}
+
+// decodeCursor returns the payload of cursor, or an error wrapping
+// ErrInvalidCursor if it's malformed, has been tampered with or has expired.
+func (s *SearchApp) decodeCursor(cursor string) (*cursorPayload, error) {
+	enc := base64.RawURLEncoding
+
+	parts := strings.Split(cursor, ".")
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("%w: malformed", ErrInvalidCursor)
+	}
+	payload, err := enc.DecodeString(parts[0])
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed", ErrInvalidCursor)
+	}
+	sig, err := enc.DecodeString(parts[1])
+	if err != nil || !hmac.Equal(sig, s.signCursor(payload)) {
+		return nil, fmt.Errorf("%w: bad signature", ErrInvalidCursor)
+	}
+
+	var p cursorPayload
+	if err = json.Unmarshal(payload, &p); err != nil {
+		return nil, fmt.Errorf("%w: malformed", ErrInvalidCursor)
+	}
+	if time.Since(time.Unix(p.Snapshot, 0)) > cursorTTL {
+		return nil, fmt.Errorf("%w: expired", ErrInvalidCursor)
+	}
+	return &p, nil
+}
+
+// applyCursor replaces the search terms and page of sr with those of its
+// cursor, if it has one.
+func (s *SearchApp) applyCursor(sr *SearchRequest) (*cursorPayload, error) {
+	if sr.Cursor == "" {
+		return nil, nil
+	}
+
+	p, err := s.decodeCursor(sr.Cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	sr.Title = p.Title
+	sr.Type = p.Type
+	sr.ReleaseYear = p.ReleaseYear
+	sr.Page = p.Page
+	return p, nil
+}
+
+// setNextCursor sets the X-Next-Cursor header to the cursor for the page after
+// the one in results, unless it was the last one. current is the cursor that
+// was used for sr, if any, so that its snapshot is carried over.
+func (s *SearchApp) setNextCursor(w http.ResponseWriter, sr *SearchRequest, current *cursorPayload, results []*SearchResult) {
+	page := sr.Page
+	if page < 1 {
+		page = 1
+	}
+	if len(results) < PageSize || page >= maxPage {
+		return
+	}
+
+	snapshot := time.Now().Unix()
+	if current != nil {
+		snapshot = current.Snapshot
+	}
+
+	w.Header().Set("X-Next-Cursor", s.encodeCursor(&cursorPayload{
+		Title:       sr.Title,
+		Type:        sr.Type,
+		ReleaseYear: sr.ReleaseYear,
+		Page:        page + 1,
+		Snapshot:    snapshot,
+	}))
+}
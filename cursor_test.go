@@ -0,0 +1,96 @@
+package main
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSearchCursors(t *testing.T) {
+	omdb := newFakeOMDb(t, pagedResults(25))
+	s := newTestApp(t, omdb, Config{})
+
+	var pages [][]string
+	body := `{"title":"alien","type":"movie","release_year":"1999"}`
+	for {
+		w := serveRequest(s, newSearchRequest(body))
+		if w.Code != http.StatusOK {
+			t.Fatalf("got status %d, want 200: %s", w.Code, w.Body)
+		}
+		pages = append(pages, ids(decodeResults(t, w)))
+
+		cursor := w.Header().Get("X-Next-Cursor")
+		if cursor == "" {
+			break
+		}
+		if len(pages) > 3 {
+			t.Fatal("got a cursor after the last page")
+		}
+		// The cursor replaces the search terms of the request.
+		body = `{"title":"other","cursor":"` + cursor + `"}`
+	}
+
+	if want := [][]string{pageIDs(1, 25), pageIDs(2, 25), pageIDs(3, 25)}; !reflect.DeepEqual(pages, want) {
+		t.Errorf("got pages %v, want %v", pages, want)
+	}
+	for i := range omdb.calls() {
+		q := omdb.query(i)
+		if q.Get("s") != "alien" || q.Get("type") != "movie" || q.Get("y") != "1999" {
+			t.Errorf("call %d: got query %v, want the first search's terms", i, q)
+		}
+	}
+}
+
+func TestSearchInvalidCursor(t *testing.T) {
+	omdb := newFakeOMDb(t, pagedResults(25))
+	s := newTestApp(t, omdb, Config{CursorSecret: "secret"})
+	other := newTestApp(t, omdb, Config{CursorSecret: "other secret"})
+
+	valid := s.encodeCursor(&cursorPayload{Title: "alien", Page: 2, Snapshot: time.Now().Unix()})
+	payload, sig, _ := strings.Cut(valid, ".")
+
+	for name, cursor := range map[string]string{
+		"malformed":      "not a cursor",
+		"bad encoding":   "!!!." + sig,
+		"tampered":       s.encodeCursor(&cursorPayload{Title: "alien", Page: 3, Snapshot: time.Now().Unix()})[:len(payload)] + "." + sig,
+		"other secret":   other.encodeCursor(&cursorPayload{Title: "alien", Page: 2, Snapshot: time.Now().Unix()}),
+		"expired":        s.encodeCursor(&cursorPayload{Title: "alien", Page: 2, Snapshot: time.Now().Add(-cursorTTL - time.Minute).Unix()}),
+		"missing a part": payload,
+		"other payload":  "e30." + sig,
+	} {
+		w := serveRequest(s, newSearchRequest(`{"title":"alien","cursor":"`+cursor+`"}`))
+		if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "invalid or expired cursor") {
+			t.Errorf("%s: got %d %q, want a 400", name, w.Code, w.Body)
+		}
+	}
+	if n := omdb.calls(); n != 0 {
+		t.Errorf("got %d calls to OMDb, want none", n)
+	}
+
+	if w := serveRequest(s, newSearchRequest(`{"cursor":"`+valid+`"}`)); w.Code != http.StatusOK {
+		t.Errorf("got status %d for a valid cursor, want 200", w.Code)
+	}
+	if got := omdb.query(0).Get("page"); got != "2" {
+		t.Errorf("got page %q, want 2", got)
+	}
+}
+
+func TestCursorSnapshot(t *testing.T) {
+	s := newTestApp(t, newFakeOMDb(t, pagedResults(25)), Config{})
+
+	// The snapshot of a search's first cursor is kept by the ones after it, so
+	// that they expire together.
+	snapshot := time.Now().Add(-cursorTTL + time.Minute).Unix()
+	cursor := s.encodeCursor(&cursorPayload{Title: "alien", Page: 2, Snapshot: snapshot})
+	w := serveRequest(s, newSearchRequest(`{"cursor":"`+cursor+`"}`))
+
+	next, err := s.decodeCursor(w.Header().Get("X-Next-Cursor"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next.Page != 3 || next.Snapshot != snapshot {
+		t.Errorf("got %+v, want page 3 with the snapshot %d", next, snapshot)
+	}
+}
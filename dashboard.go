@@ -0,0 +1,181 @@
+package main
+
+import (
+	"html/template"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// maxRecentErrors is the number of recent errors shown on the dashboard.
+const maxRecentErrors = 20
+
+// RecentError is an upstream error shown on the dashboard.
+type RecentError struct {
+	Time    time.Time
+	Type    string
+	Message string
+}
+
+// ErrorLog keeps the most recent upstream errors. It's safe for concurrent
+// use, and a nil *ErrorLog ignores errors.
+type ErrorLog struct {
+	mu     sync.Mutex
+	errors []RecentError
+}
+
+// Add records err, dropping the oldest error if the log is full.
+func (l *ErrorLog) Add(err error) {
+	if l == nil || err == nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.errors = append(l.errors, RecentError{
+		Time:    time.Now(),
+		Type:    errorType(err),
+		Message: errorMessage(err),
+	})
+	if len(l.errors) > maxRecentErrors {
+		l.errors = l.errors[len(l.errors)-maxRecentErrors:]
+	}
+}
+
+// Recent returns the errors in the log, most recent first.
+func (l *ErrorLog) Recent() []RecentError {
+	if l == nil {
+		return nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	recent := make([]RecentError, len(l.errors))
+	for i, e := range l.errors {
+		recent[len(recent)-1-i] = e
+	}
+	return recent
+}
+
+// DashboardStats are the stats shown on the dashboard.
+type DashboardStats struct {
+	Cache           *CacheStats
+	CacheEnabled    bool
+	HitRatio        float64
+	QuotaUsed       int
+	QuotaRemaining  int
+	LatencyP50      float64
+	LatencyP95      float64
+	LatencyP99      float64
+	LastSuccessAge  time.Duration
+	RecentErrors    []RecentError
+	GeneratedAt     time.Time
+	RefreshInterval int
+}
+
+// dashboardTemplate renders DashboardStats as the /debug page.
+var dashboardTemplate = template.Must(template.New("dashboard").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<title>omdb-example stats</title>
+<meta http-equiv="refresh" content="{{.RefreshInterval}}">
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { border: 1px solid #ccc; padding: 0.3em 0.8em; text-align: left; }
+</style>
+</head>
+<body>
+<h1>omdb-example stats</h1>
+<p>Generated at {{.GeneratedAt.Format "2006-01-02 15:04:05 MST"}}.</p>
+
+<h2>Cache</h2>
+{{if .CacheEnabled}}
+<table>
+<tr><th>Entries</th><td>{{.Cache.Size}} of {{.Cache.MaxSize}}</td></tr>
+<tr><th>Hits</th><td>{{.Cache.Hits}}</td></tr>
+<tr><th>Misses</th><td>{{.Cache.Misses}}</td></tr>
+<tr><th>Hit ratio</th><td>{{printf "%.2f" .HitRatio}}</td></tr>
+<tr><th>Evictions</th><td>{{.Cache.Evictions}}</td></tr>
+<tr><th>Expirations</th><td>{{.Cache.Expirations}}</td></tr>
+</table>
+{{else}}
+<p>The cache is disabled.</p>
+{{end}}
+
+<h2>Quota</h2>
+<table>
+<tr><th>Used today</th><td>{{.QuotaUsed}}</td></tr>
+<tr><th>Estimated remaining</th><td>{{.QuotaRemaining}}</td></tr>
+</table>
+
+<h2>Upstream</h2>
+<table>
+<tr><th>p50 latency</th><td>{{printf "%.3f" .LatencyP50}}s</td></tr>
+<tr><th>p95 latency</th><td>{{printf "%.3f" .LatencyP95}}s</td></tr>
+<tr><th>p99 latency</th><td>{{printf "%.3f" .LatencyP99}}s</td></tr>
+<tr><th>Since last success</th><td>{{.LastSuccessAge}}</td></tr>
+</table>
+
+<h2>Recent errors</h2>
+{{if .RecentErrors}}
+<table>
+<tr><th>Time</th><th>Type</th><th>Message</th></tr>
+{{range .RecentErrors}}<tr><td>{{.Time.Format "15:04:05"}}</td><td>{{.Type}}</td><td>{{.Message}}</td></tr>
+{{end}}</table>
+{{else}}
+<p>No errors.</p>
+{{end}}
+</body>
+</html>
+`))
+
+// dashboardRefreshInterval is how often, in seconds, the dashboard reloads.
+const dashboardRefreshInterval = 10
+
+// EnableDashboard registers the /debug dashboard on the mux. Like the /admin
+// endpoints, requests to it must include token as a bearer token.
+func (s *SearchApp) EnableDashboard(token string) {
+	s.recentErrors = &ErrorLog{}
+	s.mux.HandleFunc("/debug", s.requireToken(token, s.Dashboard))
+}
+
+// dashboardStats returns a snapshot of the stats shown on the dashboard.
+func (s *SearchApp) dashboardStats() *DashboardStats {
+	return &DashboardStats{
+		Cache:           s.cache.Stats(),
+		CacheEnabled:    s.cache != nil,
+		HitRatio:        s.cache.HitRatio(),
+		QuotaUsed:       s.omdb.quota.Used(),
+		QuotaRemaining:  s.omdb.RemainingQuota(),
+		LatencyP50:      s.omdb.latency.Quantile(0.5),
+		LatencyP95:      s.omdb.latency.Quantile(0.95),
+		LatencyP99:      s.omdb.latency.Quantile(0.99),
+		LastSuccessAge:  s.omdb.sinceLastSuccess().Round(time.Second),
+		RecentErrors:    s.recentErrors.Recent(),
+		GeneratedAt:     time.Now(),
+		RefreshInterval: dashboardRefreshInterval,
+	}
+}
+
+// renderDashboard writes the dashboard page for stats to w.
+func renderDashboard(w io.Writer, stats *DashboardStats) error {
+	return dashboardTemplate.Execute(w, stats)
+}
+
+// Dashboard handles requests to /debug, rendering the service's live stats as
+// an HTML page for operators without a metrics stack.
+func (s *SearchApp) Dashboard(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := renderDashboard(w, s.dashboardStats()); err != nil {
+		log.Printf("rendering the dashboard: %s", err)
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// sendWithToken sends a request for method and target to s with token as a
+// bearer token, if it isn't empty.
+func sendWithToken(s *SearchApp, method, target, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return serveRequest(s, req)
+}
+
+func TestDashboard(t *testing.T) {
+	omdb := newFakeOMDb(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("s") == "broken" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		writeResults(w, 1, resultsFor("tt1")...)
+	})
+	s := newTestApp(t, omdb, Config{Dashboard: true, AdminToken: "admin", CacheTTL: time.Minute})
+
+	serveRequest(s, newSearchRequest(`{"title":"alien"}`))
+	serveRequest(s, newSearchRequest(`{"title":"alien"}`))
+	serveRequest(s, newSearchRequest(`{"title":"broken"}`))
+
+	w := sendWithToken(s, "GET", "/debug", "admin")
+	if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "text/html; charset=utf-8" {
+		t.Fatalf("got %d with Content-Type %q, want the HTML page", w.Code, w.Header().Get("Content-Type"))
+	}
+	body := w.Body.String()
+	for _, want := range []string{
+		"<tr><th>Hits</th><td>1</td></tr>",
+		"<tr><th>Misses</th><td>2</td></tr>",
+		"<tr><th>Used today</th><td>2</td></tr>",
+		fmt.Sprintf(`content="%d"`, dashboardRefreshInterval),
+		"<td>status_500</td>",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("got page without %q:\n%s", want, body)
+		}
+	}
+	if strings.Contains(body, testKey) {
+		t.Error("got the API key on the page")
+	}
+}
+
+func TestDashboardAuth(t *testing.T) {
+	captureLog(t)
+	s := newTestApp(t, newFakeOMDb(t, pagedResults(1)), Config{Dashboard: true, Pprof: true, AdminToken: "admin"})
+
+	for _, target := range []string{"/debug", "/debug/pprof/", "/debug/pprof/cmdline"} {
+		for token, want := range map[string]int{
+			"":      http.StatusUnauthorized,
+			"wrong": http.StatusUnauthorized,
+			"admin": http.StatusOK,
+		} {
+			if w := sendWithToken(s, "GET", target, token); w.Code != want {
+				t.Errorf("%s with token %q: got status %d, want %d", target, token, w.Code, want)
+			}
+		}
+	}
+
+	if w := sendWithToken(s, "POST", "/debug", "admin"); w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("got status %d for a POST, want 405", w.Code)
+	}
+}
+
+func TestDashboardDisabled(t *testing.T) {
+	s := newTestApp(t, newFakeOMDb(t, pagedResults(1)), Config{AdminToken: "admin"})
+
+	if w := sendWithToken(s, "GET", "/debug", "admin"); strings.Contains(w.Body.String(), "omdb-example stats") {
+		t.Error("got the dashboard when it's disabled")
+	}
+	if _, err := NewSearchAppWithConfig(Config{Key: testKey, Dashboard: true}); err == nil {
+		t.Error("got no error for the dashboard without an admin token")
+	}
+}
+
+func TestErrorLog(t *testing.T) {
+	var l ErrorLog
+	for i := range maxRecentErrors + 5 {
+		l.Add(fmt.Errorf("error %d", i))
+	}
+	l.Add(nil)
+
+	recent := l.Recent()
+	if len(recent) != maxRecentErrors {
+		t.Fatalf("got %d errors, want %d", len(recent), maxRecentErrors)
+	}
+	if first, last := recent[0].Message, recent[len(recent)-1].Message; first != "error 24" || last != "error 5" {
+		t.Errorf("got errors from %q to %q, want the most recent first", first, last)
+	}
+
+	var nilLog *ErrorLog
+	nilLog.Add(ErrMovieNotFound)
+	if got := nilLog.Recent(); got != nil {
+		t.Errorf("got %v from a nil log", got)
+	}
+}
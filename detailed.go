@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// The limits on /search/detailed. Each hydrated result costs an upstream
+// call, unless its detail is already cached.
+const (
+	defaultDetailedResults = 5
+	maxDetailedResults     = 10
+	maxDetailConcurrency   = 4
+)
+
+// detailUnavailable is the Error of a *Detail whose lookup failed.
+const detailUnavailable = "detail unavailable"
+
+// DetailedRequest represents the variables accepted by /search/detailed.
+type DetailedRequest struct {
+	SearchRequest
+	Limit int `json:"limit,omitempty"`
+}
+
+// GetByIDs returns the *Detail for each of ids, looking up at most
+// maxDetailConcurrency of them at a time. The details and errors are in the
+// same order as ids, and only one of them is set for each ID. Once the quota
+// is exhausted, the remaining lookups aren't attempted.
+func (s *SearchApp) GetByIDs(ctx context.Context, ids []string) ([]*Detail, []error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	details := make([]*Detail, len(ids))
+	errs := make([]error, len(ids))
+	sem := make(chan struct{}, maxDetailConcurrency)
+
+	var wg sync.WaitGroup
+	for i, id := range ids {
+		wg.Add(1)
+		go func(i int, id string) {
+			defer wg.Done()
+
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				errs[i] = ctx.Err()
+				return
+			}
+
+			details[i], errs[i] = s.getDetail(ctx, id)
+			if errors.Is(errs[i], ErrQuotaExceeded) {
+				cancel()
+			}
+		}(i, id)
+	}
+	wg.Wait()
+
+	return details, errs
+}
+
+// partialDetail returns a *Detail with only the fields of r, for a result
+// whose detail couldn't be looked up.
+func partialDetail(r *SearchResult) *Detail {
+	return &Detail{
+		Title:    r.Title,
+		Year:     r.Year,
+		IMDBID:   r.IMDBID,
+		Type:     r.Type,
+		Poster:   r.Poster,
+		Response: "False",
+		Error:    detailUnavailable,
+	}
+}
+
+// SearchDetailed handles requests to /search/detailed. It searches, then
+// looks up the full detail of the top results, so that a results page only
+// needs a single request. The results are filtered as they are by /search. Results whose lookup fails have only the fields of
+// the search result, a Response of "False" and an Error.
+func (s *SearchApp) SearchDetailed(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	if r.Method != "POST" {
+		http.NotFound(w, r)
+		return
+	}
+
+	b, ok := s.readJSONBody(w, r)
+	if !ok {
+		return
+	}
+
+	var detailedRequest *DetailedRequest
+	if err := json.Unmarshal(b, &detailedRequest); err != nil || detailedRequest == nil {
+		s.invalidJSON(w, err, detailedExample)
+		return
+	}
+
+	searchRequest := &detailedRequest.SearchRequest
+	s.applyMiddleware(r, searchRequest)
+
+	if err := s.validate(searchRequest); err != nil {
+		s.searchError(w, r, searchRequest, err)
+		return
+	}
+
+	if !s.typeAllowed(searchRequest.Type) {
+		s.searchError(w, r, searchRequest, fmt.Errorf("%w: %q", ErrTypeNotAllowed, searchRequest.Type))
+		return
+	}
+
+	limit := detailedRequest.Limit
+	if limit == 0 {
+		limit = defaultDetailedResults
+	}
+	if limit < 0 || limit > maxDetailedResults {
+		msg := fmt.Sprintf("limit must be between 1 and %d", maxDetailedResults)
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	results, err := s.search(r.Context(), searchRequest)
+	if err != nil {
+		s.searchError(w, r, searchRequest, err)
+		return
+	}
+
+	results = s.filterSearch(searchRequest, results)
+	if len(results) > limit {
+		results = results[:limit]
+	}
+
+	ids := make([]string, len(results))
+	for i, result := range results {
+		ids[i] = result.IMDBID
+	}
+
+	details, errs := s.GetByIDs(r.Context(), ids)
+	for i, err := range errs {
+		if err != nil {
+			details[i] = partialDetail(results[i])
+		}
+	}
+
+	if searchRequest.PosterWidth > 0 {
+		details = resizeDetailPosters(details, searchRequest.PosterWidth)
+	}
+
+	jsonstr, err := json.Marshal(details)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Write(jsonstr)
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// detailedOMDb returns a handler that answers searches with results tt1 to
+// tt12, and looks up their details, failing the lookups of failing. It
+// records the most lookups that were in flight at once in maxInFlight.
+func detailedOMDb(failing map[string]bool, maxInFlight *int) http.HandlerFunc {
+	var (
+		mu       sync.Mutex
+		inFlight int
+	)
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.URL.Query().Get("i")
+		if id == "" {
+			var ids []string
+			for i := 1; i <= 12; i++ {
+				ids = append(ids, "tt"+strconv.Itoa(i))
+			}
+			writeResults(w, len(ids), resultsFor(ids...)...)
+			return
+		}
+
+		mu.Lock()
+		inFlight++
+		*maxInFlight = max(*maxInFlight, inFlight)
+		mu.Unlock()
+		defer func() {
+			mu.Lock()
+			inFlight--
+			mu.Unlock()
+		}()
+		time.Sleep(10 * time.Millisecond)
+
+		if failing[id] {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		writeDetail(w, &Detail{Title: "Title " + id, IMDBID: id, Plot: "The plot of " + id})
+	}
+}
+
+func TestSearchDetailed(t *testing.T) {
+	var maxInFlight int
+	omdb := newFakeOMDb(t, detailedOMDb(map[string]bool{"tt2": true, "tt4": true}, &maxInFlight))
+	s := newTestApp(t, omdb, Config{})
+
+	w := send(s, "POST", "/search/detailed", `{"title":"alien","limit":6}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("got status %d, want 200: %s", w.Code, w.Body)
+	}
+	var details []*Detail
+	if err := json.Unmarshal(w.Body.Bytes(), &details); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(details) != 6 {
+		t.Fatalf("got %d details, want 6", len(details))
+	}
+	for i, d := range details {
+		id := "tt" + strconv.Itoa(i+1)
+		switch {
+		case d.IMDBID != id:
+			t.Errorf("detail %d is %s, want %s", i, d.IMDBID, id)
+		case id == "tt2" || id == "tt4":
+			if d.Response != "False" || d.Error != detailUnavailable || d.Title != "Title "+id || d.Plot != "" {
+				t.Errorf("got %+v for a failed lookup, want the search result's fields", d)
+			}
+		case d.Response != "True" || d.Plot != "The plot of "+id:
+			t.Errorf("got %+v, want the full detail", d)
+		}
+	}
+	if maxInFlight > maxDetailConcurrency {
+		t.Errorf("got %d lookups at once, want at most %d", maxInFlight, maxDetailConcurrency)
+	}
+}
+
+func TestSearchDetailedLimit(t *testing.T) {
+	var maxInFlight int
+	omdb := newFakeOMDb(t, detailedOMDb(nil, &maxInFlight))
+	s := newTestApp(t, omdb, Config{})
+
+	for _, test := range []struct {
+		body    string
+		status  int
+		details int
+	}{
+		{`{"title":"alien"}`, http.StatusOK, defaultDetailedResults},
+		{`{"title":"alien","limit":10}`, http.StatusOK, 10},
+		{`{"title":"alien","limit":11}`, http.StatusBadRequest, 0},
+		{`{"title":"alien","limit":-1}`, http.StatusBadRequest, 0},
+	} {
+		w := send(s, "POST", "/search/detailed", test.body)
+		if w.Code != test.status {
+			t.Errorf("%s: got status %d, want %d", test.body, w.Code, test.status)
+			continue
+		}
+		var details []*Detail
+		if test.status == http.StatusOK {
+			json.Unmarshal(w.Body.Bytes(), &details)
+		}
+		if len(details) != test.details {
+			t.Errorf("%s: got %d details, want %d", test.body, len(details), test.details)
+		}
+	}
+}
+
+func TestSearchDetailedQuotaExceeded(t *testing.T) {
+	var calls int
+	var mu sync.Mutex
+	omdb := newFakeOMDb(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("i") == "" {
+			writeResults(w, 10, resultsFor("tt1", "tt2", "tt3", "tt4", "tt5", "tt6", "tt7", "tt8", "tt9", "tt10")...)
+			return
+		}
+		mu.Lock()
+		calls++
+		mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		w.WriteHeader(http.StatusUnauthorized)
+		writeError(w, quotaExceededMessage)
+	})
+	s := newTestApp(t, omdb, Config{})
+
+	w := send(s, "POST", "/search/detailed", `{"title":"alien","limit":10}`)
+	var details []*Detail
+	if err := json.Unmarshal(w.Body.Bytes(), &details); err != nil {
+		t.Fatalf("decoding %q: %s", w.Body, err)
+	}
+	if len(details) != 10 {
+		t.Errorf("got %d details, want the 10 partial ones", len(details))
+	}
+
+	// The lookups that started before the quota ran out are the only ones.
+	mu.Lock()
+	defer mu.Unlock()
+	if calls > maxDetailConcurrency {
+		t.Errorf("got %d lookups, want at most %d once the quota ran out", calls, maxDetailConcurrency)
+	}
+}
+
+func TestSearchDetailedFilters(t *testing.T) {
+	var maxInFlight int
+	omdb := newFakeOMDb(t, detailedOMDb(nil, &maxInFlight))
+	s := newTestApp(t, omdb, Config{})
+
+	w := send(s, "POST", "/search/detailed", `{"title":"alien","id_pattern":"^tt1[0-9]$"}`)
+	var details []*Detail
+	if err := json.Unmarshal(w.Body.Bytes(), &details); err != nil {
+		t.Fatalf("got %s: %s", w.Body, err)
+	}
+	var got []string
+	for _, d := range details {
+		got = append(got, d.IMDBID)
+	}
+	if want := []string{"tt10", "tt11", "tt12"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want the results matching the ID pattern", got)
+	}
+
+	w = send(s, "POST", "/search/detailed", `{"title":"alien","min_year":2000}`)
+	details = nil
+	if err := json.Unmarshal(w.Body.Bytes(), &details); err != nil || len(details) != 0 {
+		t.Errorf("got %s, want the results from 1999 filtered out", w.Body)
+	}
+}
+
+func TestSearchDetailedPosterWidth(t *testing.T) {
+	const poster = "https://m.media-amazon.com/images/M/MV5B._V1_SX300.jpg"
+	omdb := newFakeOMDb(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("i") == "" {
+			writeResults(w, 1, &SearchResult{Title: "Alien", IMDBID: "tt1", Poster: poster})
+			return
+		}
+		writeDetail(w, &Detail{Title: "Alien", IMDBID: "tt1", Poster: poster})
+	})
+	s := newTestApp(t, omdb, Config{})
+
+	for _, want := range []string{ResizePoster(poster, 100), poster} {
+		body := `{"title":"alien"}`
+		if want != poster {
+			body = `{"title":"alien","poster_width":100}`
+		}
+		var details []*Detail
+		if err := json.Unmarshal(send(s, "POST", "/search/detailed", body).Body.Bytes(), &details); err != nil {
+			t.Fatal(err)
+		}
+		// The second search is served from the detail cache, which mustn't
+		// have been changed by the first.
+		if len(details) != 1 || details[0].Poster != want {
+			t.Errorf("%s: got %+v, want poster %s", body, details, want)
+		}
+	}
+}
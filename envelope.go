@@ -0,0 +1,27 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+)
+
+// ResponseVersion is the version of the /search response envelope. It's
+// incremented when the envelope or the results in it change in a way that
+// isn't backwards compatible, e.g. a field is removed or renamed. Adding a
+// field doesn't change the version.
+const ResponseVersion = "1"
+
+// Envelope wraps the results in /search responses so that clients can tell
+// which version of the response they're handling.
+type Envelope struct {
+	APIVersion string      `json:"apiVersion"`
+	Results    interface{} `json:"results"`
+}
+
+// wantsRaw returns true if the request asked for the results as a bare JSON
+// array with the raw query parameter, which is how they were returned before
+// the envelope was added.
+func wantsRaw(r *http.Request) bool {
+	raw, _ := strconv.ParseBool(r.URL.Query().Get("raw"))
+	return raw
+}
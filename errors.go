@@ -0,0 +1,137 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"syscall"
+)
+
+// The error messages OMDb returns with a Response of "False" that are mapped
+// to sentinel errors.
+const (
+	quotaExceededMessage  = "Request limit reached!"
+	invalidAPIKeyMessage  = "Invalid API key!"
+	noAPIKeyMessage       = "No API key provided."
+	tooManyResultsMessage = "Too many results."
+	movieNotFoundMessage  = "Movie not found!"
+)
+
+// The sentinel errors returned by the OMDBAPI and the search handlers. They're
+// usually wrapped with more detail, so check for them with errors.Is.
+var (
+	// ErrMovieNotFound is returned when there is no title with the requested
+	// IMDb ID, or no season with the requested number.
+	ErrMovieNotFound = errors.New("movie not found")
+
+	// ErrInvalidAPIKey is returned when OMDb rejects the API key.
+	ErrInvalidAPIKey = errors.New("invalid omdb api key")
+
+	// ErrQuotaExceeded is returned when the API key has reached its daily
+	// request limit.
+	ErrQuotaExceeded = errors.New("omdb daily request limit reached")
+
+	// ErrTooManyResults is returned when a search matches too many titles for
+	// OMDb to return them.
+	ErrTooManyResults = errors.New("too many results")
+
+	// ErrEmptyTitle is returned for a search without a title.
+	ErrEmptyTitle = errors.New("empty title")
+
+	// ErrTitleTooLong is returned for a search title longer than the
+	// configured maximum.
+	ErrTitleTooLong = errors.New("title too long")
+
+	// ErrTitleTooShort is returned for a search title shorter than the
+	// configured minimum.
+	ErrTitleTooShort = errors.New("title too short")
+
+	// ErrInvalidPage is returned for a page outside of the range OMDb returns.
+	ErrInvalidPage = errors.New("invalid page")
+
+	// ErrInvalidPosterWidth is returned for a poster width that's negative or
+	// too large.
+	ErrInvalidPosterWidth = errors.New("invalid poster width")
+
+	// ErrInvalidMinYear is returned for a minimum year that's negative or
+	// later than the release year, which nothing could match.
+	ErrInvalidMinYear = errors.New("invalid minimum year")
+
+	// ErrInvalidType is returned for a search type that OMDb doesn't support.
+	ErrInvalidType = errors.New("invalid type")
+
+	// ErrTypeNotAllowed is returned for a search type that this deployment
+	// doesn't allow.
+	ErrTypeNotAllowed = errors.New("type not allowed")
+
+	// ErrInvalidYear is returned for a release year that isn't four digits.
+	ErrInvalidYear = errors.New("invalid year")
+
+	// ErrInvalidIDPattern is returned for an IMDb ID filter that isn't a valid
+	// regular expression, or is too long.
+	ErrInvalidIDPattern = errors.New("invalid id pattern")
+
+	// ErrInvalidCursor is returned for a pagination cursor that's malformed,
+	// has been tampered with or has expired.
+	ErrInvalidCursor = errors.New("invalid cursor")
+
+	// ErrInvalidSeason is returned for a season number less than 1.
+	ErrInvalidSeason = errors.New("invalid season")
+
+	// ErrConnectionDropped is returned when the connection to OMDb is closed
+	// or reset part way through reading its response.
+	ErrConnectionDropped = errors.New("connection to omdb dropped while reading its response")
+)
+
+// StatusError is returned by the OMDBAPI when the upstream responds with a
+// non-200 status code that isn't explained by the response body. Use
+// errors.As to get at the status code.
+type StatusError struct {
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("omdb returned status %d", e.StatusCode)
+}
+
+// APIError is returned by the OMDBAPI when OMDb responds with an error. It
+// has the HTTP status code, OMDb's own Response and Error fields, and the URL
+// that was requested, so that callers can handle it with errors.As. The URL
+// and Body, the response body kept for troubleshooting, have the API key
+// redacted. Err is the sentinel or *StatusError that the response maps to, if
+// any, and is what errors.Is and errors.As check.
+type APIError struct {
+	StatusCode   int
+	OMDbResponse string
+	OMDbError    string
+	URL          string
+	Body         string
+	Err          error
+}
+
+func (e *APIError) Error() string {
+	if e.Err != nil {
+		return e.Err.Error()
+	}
+	return "omdb error: " + e.OMDbError
+}
+
+func (e *APIError) Unwrap() error {
+	return e.Err
+}
+
+// errorResponse is the body OMDb returns along with a failed request.
+type errorResponse struct {
+	Response string
+	Error    string
+}
+
+// connectionDropped returns true if err is from reading a body whose
+// connection was closed or reset before all of it arrived.
+func connectionDropped(err error) bool {
+	return errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.EPIPE) ||
+		errors.Is(err, net.ErrClosed)
+}
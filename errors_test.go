@@ -0,0 +1,251 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// omdbError returns a handler that responds to every request with status and
+// an OMDb error of msg.
+func omdbError(status int, msg string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		writeError(w, msg)
+	}
+}
+
+func TestSentinelErrors(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		handler http.HandlerFunc
+		want    error
+	}{
+		{"quota exceeded", omdbError(http.StatusUnauthorized, quotaExceededMessage), ErrQuotaExceeded},
+		{"invalid key", omdbError(http.StatusUnauthorized, invalidAPIKeyMessage), ErrInvalidAPIKey},
+		{"no key", omdbError(http.StatusUnauthorized, noAPIKeyMessage), ErrInvalidAPIKey},
+		{"too many results", omdbError(http.StatusOK, tooManyResultsMessage), ErrTooManyResults},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			api := newTestAPI(t, newFakeOMDb(t, test.handler))
+			_, err := api.Search(context.Background(), &SearchRequest{Title: "alien"})
+			if !errors.Is(err, test.want) {
+				t.Errorf("got error %v, want %v", err, test.want)
+			}
+		})
+	}
+}
+
+func TestStatusError(t *testing.T) {
+	api := newTestAPI(t, newFakeOMDb(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+
+	_, err := api.Search(context.Background(), &SearchRequest{Title: "alien"})
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("got error %v, want a *StatusError for 503", err)
+	}
+}
+
+func TestMovieNotFoundError(t *testing.T) {
+	api := newTestAPI(t, newFakeOMDb(t, omdbError(http.StatusOK, "Incorrect IMDb ID.")))
+
+	_, err := api.GetByID(context.Background(), "tt0000000")
+	if !errors.Is(err, ErrMovieNotFound) {
+		t.Errorf("got error %v, want ErrMovieNotFound", err)
+	}
+}
+
+func TestSearchErrorStatus(t *testing.T) {
+	for _, test := range []struct {
+		name    string
+		handler http.HandlerFunc
+		body    string
+		want    int
+	}{
+		{"quota exceeded", omdbError(http.StatusUnauthorized, quotaExceededMessage), `{"title":"alien"}`, http.StatusTooManyRequests},
+		{"invalid key", omdbError(http.StatusUnauthorized, invalidAPIKeyMessage), `{"title":"alien"}`, http.StatusBadGateway},
+		{"upstream failure", omdbError(http.StatusInternalServerError, ""), `{"title":"alien"}`, http.StatusBadGateway},
+		{"not found", omdbError(http.StatusOK, "Incorrect IMDb ID."), `{"title":"tt0000000"}`, http.StatusNotFound},
+		{"invalid type", pagedResults(1), `{"title":"alien","type":"cartoon"}`, http.StatusUnprocessableEntity},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			s := newTestApp(t, newFakeOMDb(t, test.handler), Config{})
+			if w := serveRequest(s, newSearchRequest(test.body)); w.Code != test.want {
+				t.Errorf("got status %d, want %d: %s", w.Code, test.want, w.Body)
+			}
+		})
+	}
+}
+
+func TestErrorsWrapped(t *testing.T) {
+	err := fmt.Errorf("searching: %w", &APIError{Err: ErrQuotaExceeded})
+	if !errors.Is(err, ErrQuotaExceeded) {
+		t.Error("a wrapped *APIError isn't ErrQuotaExceeded")
+	}
+	err = &ValidationError{Errors: []error{ErrEmptyTitle, fmt.Errorf("%w: %q", ErrInvalidType, "cartoon")}}
+	if !errors.Is(err, ErrEmptyTitle) || !errors.Is(err, ErrInvalidType) {
+		t.Error("a *ValidationError isn't each of its errors")
+	}
+}
+
+func TestAPIError(t *testing.T) {
+	for _, test := range []struct {
+		name         string
+		handler      http.HandlerFunc
+		status       int
+		omdbResponse string
+		omdbError    string
+		err          error
+	}{
+		{"quota exceeded", omdbError(http.StatusUnauthorized, quotaExceededMessage), http.StatusUnauthorized, "False", quotaExceededMessage, ErrQuotaExceeded},
+		{"unknown omdb error", omdbError(http.StatusOK, "Something went wrong."), http.StatusOK, "False", "Something went wrong.", nil},
+		{"status without a body", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprint(w, "<html>Service Unavailable</html>")
+		}, http.StatusServiceUnavailable, "", "", &StatusError{StatusCode: http.StatusServiceUnavailable}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			api := newTestAPI(t, newFakeOMDb(t, test.handler))
+			_, err := api.Search(context.Background(), &SearchRequest{Title: "alien"})
+
+			var apiErr *APIError
+			if !errors.As(err, &apiErr) {
+				t.Fatalf("got error %v, want an *APIError", err)
+			}
+			if apiErr.StatusCode != test.status {
+				t.Errorf("got status code %d, want %d", apiErr.StatusCode, test.status)
+			}
+			if apiErr.OMDbResponse != test.omdbResponse || apiErr.OMDbError != test.omdbError {
+				t.Errorf("got OMDb response %q and error %q, want %q and %q", apiErr.OMDbResponse, apiErr.OMDbError, test.omdbResponse, test.omdbError)
+			}
+			if !strings.Contains(apiErr.URL, "s=alien") || strings.Contains(apiErr.URL, testKey) {
+				t.Errorf("got URL %q, want the search URL with the key redacted", apiErr.URL)
+			}
+			if fmt.Sprint(apiErr.Err) != fmt.Sprint(test.err) {
+				t.Errorf("got Err %v, want %v", apiErr.Err, test.err)
+			}
+			if test.err == nil && apiErr.Error() != "omdb error: "+test.omdbError {
+				t.Errorf("got message %q, want OMDb's error", apiErr.Error())
+			}
+		})
+	}
+}
+
+func TestAPIErrorBodyRedacted(t *testing.T) {
+	omdb := newFakeOMDb(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+		fmt.Fprintf(w, "bad request %s", r.URL)
+	})
+	s := newTestApp(t, omdb, Config{UpstreamErrors: true})
+	captureLog(t)
+
+	w := serveRequest(s, newSearchRequest(`{"title":"alien"}`))
+	if w.Code != http.StatusBadGateway {
+		t.Fatalf("got status %d, want 502: %s", w.Code, w.Body)
+	}
+	if body := w.Body.String(); !strings.Contains(body, "omdb response: bad request") || strings.Contains(body, testKey) {
+		t.Errorf("got body %q, want OMDb's response without the key", body)
+	}
+}
+
+// droppedOMDb is a handler that starts a response and closes the connection
+// part way through its body.
+func droppedOMDb(w http.ResponseWriter, r *http.Request) {
+	conn, buf, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		panic(err)
+	}
+	defer conn.Close()
+	fmt.Fprint(buf, "HTTP/1.1 200 OK\r\nContent-Type: application/json\r\nContent-Length: 1000\r\n\r\n")
+	fmt.Fprint(buf, `{"Search":[{"Title":"Alien",`)
+	buf.Flush()
+}
+
+func TestConnectionDropped(t *testing.T) {
+	for _, test := range []struct {
+		err  error
+		want bool
+	}{
+		{io.ErrUnexpectedEOF, true},
+		{fmt.Errorf("read: %w", syscall.ECONNRESET), true},
+		{syscall.EPIPE, true},
+		{io.EOF, false},
+		{context.DeadlineExceeded, false},
+	} {
+		if got := connectionDropped(test.err); got != test.want {
+			t.Errorf("connectionDropped(%v) = %t, want %t", test.err, got, test.want)
+		}
+	}
+}
+
+func TestUpstreamConnectionDropped(t *testing.T) {
+	api := newTestAPI(t, newFakeOMDb(t, droppedOMDb))
+
+	_, err := api.Search(context.Background(), &SearchRequest{Title: "alien"})
+	if !errors.Is(err, ErrConnectionDropped) {
+		t.Fatalf("got error %v, want ErrConnectionDropped", err)
+	}
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusOK || strings.Contains(apiErr.URL, testKey) {
+		t.Errorf("got %+v, want an *APIError with the key redacted", apiErr)
+	}
+}
+
+func TestSearchUpstreamConnectionDropped(t *testing.T) {
+	captureLog(t)
+	omdb := newFakeOMDb(t, droppedOMDb)
+	s := newTestApp(t, omdb, Config{Retries: 2, RetryDelay: time.Millisecond})
+
+	w := serveRequest(s, newSearchRequest(`{"title":"alien"}`))
+	if w.Code != http.StatusBadGateway {
+		t.Errorf("got status %d, want 502", w.Code)
+	}
+	if body := w.Body.String(); !strings.Contains(body, ErrConnectionDropped.Error()) || strings.Contains(body, testKey) {
+		t.Errorf("got body %q, want the dropped connection reported without the key", body)
+	}
+	// A dropped connection is retried.
+	if n := omdb.calls(); n != 3 {
+		t.Errorf("got %d calls to OMDb, want 3", n)
+	}
+}
+
+// droppedBody is a request body whose connection drops part way through.
+type droppedBody struct {
+	sent bool
+}
+
+func (b *droppedBody) Read(p []byte) (int, error) {
+	if b.sent {
+		return 0, io.ErrUnexpectedEOF
+	}
+	b.sent = true
+	return copy(p, `{"title":"al`), nil
+}
+
+func TestSearchClientConnectionDropped(t *testing.T) {
+	log := captureLog(t)
+	omdb := newFakeOMDb(t, pagedResults(1))
+	s := newTestApp(t, omdb, Config{})
+
+	req := httptest.NewRequest("POST", "/search", &droppedBody{})
+	req.Header.Set("Content-Type", "application/json")
+	w := serveRequest(s, req)
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "the request body was cut short") {
+		t.Errorf("got %d %q, want a 400 for the cut short body", w.Code, w.Body)
+	}
+	if !strings.Contains(log.String(), "dropped the connection while sending the request body") {
+		t.Errorf("got log %q, want the dropped connection logged", log)
+	}
+	if n := omdb.calls(); n != 0 {
+		t.Errorf("got %d calls to OMDb, want none", n)
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// The formats supported by /export.
+const (
+	ExportCSV    = "csv"
+	ExportNDJSON = "ndjson"
+)
+
+// exportChunkTimeout is how long each page or poster of an export has to be
+// fetched and written in. The write deadline is pushed back by this much for
+// every one, so that a long export isn't cut short by the server's
+// WriteTimeout.
+const exportChunkTimeout = 30 * time.Second
+
+// exportColumns are the header row of a CSV export.
+var exportColumns = []string{"imdbID", "title", "year", "type", "poster"}
+
+// Export handles requests to /export. It searches for all of the pages of
+// results for the title query parameter and streams them as CSV or NDJSON,
+// writing each page as it arrives rather than buffering the whole result set.
+// The number of rows is capped by the max_rows query parameter, which can't
+// be more than OMDb will return for a single search.
+func (s *SearchApp) Export(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.NotFound(w, r)
+		return
+	}
+
+	q, err := s.query(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	searchRequest := NewSearchRequest(q.Get("title"))
+	searchRequest.Type = q.Get("type")
+	searchRequest.ReleaseYear = q.Get("year")
+	s.applyMiddleware(r, searchRequest)
+
+	if err := s.validate(searchRequest); err != nil {
+		s.searchError(w, r, searchRequest, err)
+		return
+	}
+
+	if !s.typeAllowed(searchRequest.Type) {
+		s.searchError(w, r, searchRequest, fmt.Errorf("%w: %q", ErrTypeNotAllowed, searchRequest.Type))
+		return
+	}
+
+	format := q.Get("format")
+	if format == "" {
+		format = ExportCSV
+	}
+	if format != ExportCSV && format != ExportNDJSON {
+		msg := fmt.Sprintf("format must be %s or %s", ExportCSV, ExportNDJSON)
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	maxRows := maxJobResults
+	if v := q.Get("max_rows"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxJobResults {
+			msg := fmt.Sprintf("max_rows must be between 1 and %d", maxJobResults)
+			http.Error(w, msg, http.StatusBadRequest)
+			return
+		}
+		maxRows = n
+	}
+
+	var (
+		started bool
+		csvw    = csv.NewWriter(w)
+		enc     = json.NewEncoder(w)
+	)
+
+	extendWriteDeadline(w)
+	err = s.omdb.SearchStream(r.Context(), searchRequest, maxRows, func(results []*SearchResult) error {
+		extendWriteDeadline(w)
+		if !started {
+			started = true
+			if format == ExportCSV {
+				w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+				w.Header().Set("Content-Disposition", `attachment; filename="export.csv"`)
+				if err := csvw.Write(exportColumns); err != nil {
+					return err
+				}
+			} else {
+				w.Header().Set("Content-Type", "application/x-ndjson")
+			}
+		}
+
+		for _, result := range results {
+			if !s.typeAllowed(result.Type) {
+				continue
+			}
+
+			var err error
+			if format == ExportCSV {
+				err = csvw.Write([]string{result.IMDBID, result.Title, result.Year, result.Type, result.Poster})
+			} else {
+				err = enc.Encode(resultWithJSONCase(result, s.jsonCase))
+			}
+			if err != nil {
+				return err
+			}
+		}
+
+		csvw.Flush()
+		if f, ok := w.(http.Flusher); ok {
+			f.Flush()
+		}
+		return csvw.Error()
+	})
+
+	if err != nil {
+		// Once rows have been written the status can't be changed, so the
+		// export is just cut short.
+		if started {
+			log.Printf("export of %q stopped early: %s", searchRequest.Title, errorMessage(err))
+			return
+		}
+		s.searchError(w, r, searchRequest, err)
+		return
+	}
+
+	if !started && format == ExportCSV {
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		csvw.Write(exportColumns)
+		csvw.Flush()
+	}
+}
+
+// extendWriteDeadline gives w another exportChunkTimeout to be written in.
+// Writers that don't support deadlines, e.g. in tests, are left alone.
+func extendWriteDeadline(w http.ResponseWriter) {
+	http.NewResponseController(w).SetWriteDeadline(time.Now().Add(exportChunkTimeout))
+}
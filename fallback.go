@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"errors"
+)
+
+// FallbackAPI is an implementation of the API interface that tries each of its
+// APIs in order, moving on to the next one when a call fails with an error
+// that ShouldFallback returns true for.
+type FallbackAPI struct {
+	APIs           []API
+	ShouldFallback func(error) bool
+}
+
+// NewFallbackAPI returns a *FallbackAPI that tries apis in the order they're
+// given, using DefaultShouldFallback.
+func NewFallbackAPI(apis ...API) *FallbackAPI {
+	return &FallbackAPI{
+		APIs:           apis,
+		ShouldFallback: DefaultShouldFallback,
+	}
+}
+
+// DefaultShouldFallback returns true for errors that another API might not
+// have. A title that doesn't exist won't exist in the next API either, and a
+// cancelled request shouldn't be retried at all.
+func DefaultShouldFallback(err error) bool {
+	return !errors.Is(err, ErrMovieNotFound) &&
+		!errors.Is(err, context.Canceled) &&
+		!errors.Is(err, context.DeadlineExceeded)
+}
+
+// call calls fn with each API until one succeeds or returns an error that
+// shouldn't fall back. The error from the last API called is returned.
+func (f *FallbackAPI) call(fn func(API) error) error {
+	err := errors.New("no APIs configured")
+	for _, api := range f.APIs {
+		if err = fn(api); err == nil || !f.ShouldFallback(err) {
+			return err
+		}
+	}
+	return err
+}
+
+// Search returns the results from the first API that succeeds.
+func (f *FallbackAPI) Search(ctx context.Context, r *SearchRequest) ([]*SearchResult, error) {
+	var results []*SearchResult
+	err := f.call(func(api API) error {
+		var err error
+		results, err = api.Search(ctx, r)
+		return err
+	})
+	return results, err
+}
+
+// GetByID returns the detail from the first API that succeeds.
+func (f *FallbackAPI) GetByID(ctx context.Context, id string) (*Detail, error) {
+	var detail *Detail
+	err := f.call(func(api API) error {
+		var err error
+		detail, err = api.GetByID(ctx, id)
+		return err
+	})
+	return detail, err
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// mockAPI is an API that returns results and err, and counts its calls.
+type mockAPI struct {
+	results []*SearchResult
+	detail  *Detail
+	err     error
+	calls   int
+}
+
+func (m *mockAPI) Search(ctx context.Context, r *SearchRequest) ([]*SearchResult, error) {
+	m.calls++
+	return m.results, m.err
+}
+
+func (m *mockAPI) GetByID(ctx context.Context, id string) (*Detail, error) {
+	m.calls++
+	return m.detail, m.err
+}
+
+func TestFallbackAPISearch(t *testing.T) {
+	for _, test := range []struct {
+		name                         string
+		primaryErr                   error
+		want                         string
+		wantErr                      error
+		primaryCalls, secondaryCalls int
+	}{
+		{"primary succeeds", nil, "tt1", nil, 1, 0},
+		{"primary fails", &StatusError{StatusCode: 503}, "tt2", nil, 1, 1},
+		{"not found", fmt.Errorf("%w: tt0", ErrMovieNotFound), "", ErrMovieNotFound, 1, 0},
+		{"canceled", context.Canceled, "", context.Canceled, 1, 0},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			primary := &mockAPI{results: resultsFor("tt1"), err: test.primaryErr}
+			secondary := &mockAPI{results: resultsFor("tt2")}
+
+			results, err := NewFallbackAPI(primary, secondary).Search(context.Background(), &SearchRequest{Title: "alien"})
+			if !errors.Is(err, test.wantErr) {
+				t.Errorf("got error %v, want %v", err, test.wantErr)
+			}
+			if test.want != "" && (len(results) != 1 || results[0].IMDBID != test.want) {
+				t.Errorf("got %v, want %s", ids(results), test.want)
+			}
+			if primary.calls != test.primaryCalls || secondary.calls != test.secondaryCalls {
+				t.Errorf("got %d and %d calls, want %d and %d", primary.calls, secondary.calls, test.primaryCalls, test.secondaryCalls)
+			}
+		})
+	}
+}
+
+func TestFallbackAPIAllFail(t *testing.T) {
+	last := &StatusError{StatusCode: 502}
+	f := NewFallbackAPI(&mockAPI{err: &StatusError{StatusCode: 503}}, &mockAPI{err: last})
+
+	if _, err := f.GetByID(context.Background(), "tt1"); err != last {
+		t.Errorf("got error %v, want the last API's", err)
+	}
+	if _, err := NewFallbackAPI().Search(context.Background(), &SearchRequest{}); err == nil {
+		t.Error("got no error without any APIs")
+	}
+}
+
+func TestFallbackAPIShouldFallback(t *testing.T) {
+	primary := &mockAPI{err: ErrQuotaExceeded}
+	secondary := &mockAPI{detail: &Detail{IMDBID: "tt2"}}
+	f := NewFallbackAPI(primary, secondary)
+	f.ShouldFallback = func(err error) bool { return !errors.Is(err, ErrQuotaExceeded) }
+
+	if _, err := f.GetByID(context.Background(), "tt2"); !errors.Is(err, ErrQuotaExceeded) {
+		t.Errorf("got error %v, want ErrQuotaExceeded", err)
+	}
+	if secondary.calls != 0 {
+		t.Errorf("got %d calls to the secondary, want none", secondary.calls)
+	}
+}
+
+func TestSearchFallbackURL(t *testing.T) {
+	primary := newFakeOMDb(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	secondary := newFakeOMDb(t, func(w http.ResponseWriter, r *http.Request) {
+		writeResults(w, 1, resultsFor("tt2")...)
+	})
+	s := newTestApp(t, primary, Config{FallbackURL: secondary.baseURL()})
+
+	w := serveRequest(s, newSearchRequest(`{"title":"alien"}`))
+	if got := ids(decodeResults(t, w)); len(got) != 1 || got[0] != "tt2" {
+		t.Errorf("got %v, want the secondary's tt2", got)
+	}
+	if primary.calls() != 1 || secondary.calls() != 1 {
+		t.Errorf("got %d and %d calls, want 1 to each", primary.calls(), secondary.calls())
+	}
+	if key := secondary.query(0).Get("apikey"); key != testKey {
+		t.Errorf("got key %q for the secondary, want %q", key, testKey)
+	}
+}
+
+func TestSearchFallbackURLShares(t *testing.T) {
+	primary := newFakeOMDb(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	var failed bool
+	secondary := newFakeOMDb(t, func(w http.ResponseWriter, r *http.Request) {
+		if !failed {
+			failed = true
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		writeResults(w, 1, resultsFor("tt2")...)
+	})
+	s := newTestApp(t, primary, Config{
+		FallbackURL:   secondary.baseURL(),
+		Retries:       1,
+		RetryDelay:    time.Millisecond,
+		ShedErrorRate: 0.5,
+	})
+
+	api := s.searchAPI.(*FallbackAPI).APIs[1].(*OMDBAPI)
+	if api.backoff != s.omdb.backoff || api.quota != s.omdb.quota || api.shedder != s.omdb.shedder {
+		t.Fatal("got a secondary with its own retries, quota or shedder, want the primary's")
+	}
+
+	w := serveRequest(s, newSearchRequest(`{"title":"alien"}`))
+	if got := ids(decodeResults(t, w)); len(got) != 1 || got[0] != "tt2" {
+		t.Errorf("got %v, want the secondary's tt2", got)
+	}
+	if secondary.calls() != 2 {
+		t.Errorf("got %d calls to the secondary, want its failure retried", secondary.calls())
+	}
+	if used, want := s.omdb.quota.Used(), primary.calls()+secondary.calls(); used != want {
+		t.Errorf("got %d calls counted against the quota, want all %d", used, want)
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// The features that can be disabled per deployment, each of which is one or
+// more optional endpoints. /search and / are always served, and the admin and
+// pprof endpoints have their own settings.
+const (
+	FeatureMerge    = "merge"
+	FeatureDetailed = "detailed"
+	FeatureMetrics  = "metrics"
+	FeatureReady    = "readyz"
+	FeaturePoster   = "poster"
+	FeatureJobs     = "jobs"
+	FeatureConfig   = "config"
+	FeatureExport   = "export"
+	FeatureCompare  = "compare"
+	FeatureRSS      = "rss"
+	FeatureHistory  = "history"
+)
+
+// featureRoutes returns the handlers for each feature, keyed by their pattern.
+func (s *SearchApp) featureRoutes() map[string]map[string]http.HandlerFunc {
+	return map[string]map[string]http.HandlerFunc{
+		FeatureMerge:    {"/search/merge": s.Merge},
+		FeatureDetailed: {"/search/detailed": s.SearchDetailed},
+		FeatureMetrics:  {"/metrics": s.Metrics},
+		FeatureReady:    {"/readyz": s.Ready},
+		FeaturePoster:   {"/poster": s.Poster},
+		FeatureJobs:     {"/jobs/search": s.StartJob, "/jobs/": s.Job},
+		FeatureConfig:   {"/config.json": s.FrontendConfig},
+		FeatureExport:   {"/export": s.Export, "/export/posters": s.ExportPosters},
+		FeatureCompare:  {"/compare": s.Compare},
+		FeatureRSS:      {"/search.rss": s.SearchRSS},
+		FeatureHistory:  {"/search/history": s.History},
+	}
+}
+
+// limitedRoutes are the patterns of the feature endpoints that call OMDb, or
+// fetch posters, and so are rate limited per client like /search.
+var limitedRoutes = map[string]bool{
+	"/search/merge":    true,
+	"/search/detailed": true,
+	"/poster":          true,
+	"/jobs/search":     true,
+	"/export":          true,
+	"/export/posters":  true,
+	"/compare":         true,
+	"/search.rss":      true,
+}
+
+// shedRoutes are the patterns of the feature endpoints that call OMDb, and so
+// are shed like /search while it's degraded. /poster is rate limited but isn't
+// shed, as posters aren't served by OMDb.
+var shedRoutes = map[string]bool{
+	"/search/merge":    true,
+	"/search/detailed": true,
+	"/jobs/search":     true,
+	"/export":          true,
+	"/export/posters":  true,
+	"/compare":         true,
+	"/search.rss":      true,
+}
+
+// Features returns the names of the features that can be disabled, sorted.
+func Features() []string {
+	var names []string
+	for name := range (&SearchApp{}).featureRoutes() {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// registerRoutes registers the handlers for every feature on the mux, other
+// than the disabled ones. The endpoints of a disabled feature respond with a
+// 404, rather than falling through to /, which serves any path in some
+// landing modes.
+func (s *SearchApp) registerRoutes(disabled []string) error {
+	routes := s.featureRoutes()
+
+	s.disabled = make(map[string]bool)
+	for _, name := range disabled {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if _, ok := routes[name]; !ok {
+			return fmt.Errorf("unknown feature %q, must be one of %s", name, strings.Join(Features(), ", "))
+		}
+		s.disabled[name] = true
+	}
+
+	s.mux.HandleFunc("/", s.Home)
+	s.mux.HandleFunc("/search", s.trackInFlight(s.limitClients(s.shedLoad(s.Search))))
+	for name, handlers := range routes {
+		for pattern, handler := range handlers {
+			if s.disabled[name] {
+				s.mux.HandleFunc(pattern, http.NotFound)
+				continue
+			}
+			if shedRoutes[pattern] {
+				handler = s.shedLoad(handler)
+			}
+			if limitedRoutes[pattern] {
+				handler = s.limitClients(handler)
+			}
+			s.mux.HandleFunc(pattern, handler)
+		}
+	}
+	return nil
+}
+
+// enabled returns true if the feature name hasn't been disabled.
+func (s *SearchApp) enabled(name string) bool {
+	return !s.disabled[name]
+}
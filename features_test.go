@@ -0,0 +1,63 @@
+package main
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestDisabledFeatures(t *testing.T) {
+	omdb := newFakeOMDb(t, pagedResults(1))
+	s := newTestApp(t, omdb, Config{DisabledFeatures: []string{" Merge ", FeatureMetrics, FeatureJobs}})
+
+	for _, test := range []struct {
+		method, target, body string
+		want                 int
+	}{
+		{"POST", "/search/merge", `{"titles":["alien"]}`, http.StatusNotFound},
+		{"GET", "/metrics", "", http.StatusNotFound},
+		{"POST", "/jobs/search", `{"title":"alien"}`, http.StatusNotFound},
+		{"GET", "/jobs/1", "", http.StatusNotFound},
+		{"POST", "/search", `{"title":"alien"}`, http.StatusOK},
+		{"GET", "/config.json", "", http.StatusOK},
+	} {
+		if w := send(s, test.method, test.target, test.body); w.Code != test.want {
+			t.Errorf("%s %s: got status %d, want %d", test.method, test.target, w.Code, test.want)
+		}
+	}
+	if n := omdb.calls(); n != 1 {
+		t.Errorf("got %d calls to OMDb, want 1", n)
+	}
+}
+
+func TestDisabledFeatureNotLanding(t *testing.T) {
+	// The greeting is served for any path, but not for a disabled endpoint.
+	s := newTestApp(t, newFakeOMDb(t, pagedResults(0)), Config{DisabledFeatures: []string{FeatureMetrics}})
+
+	w := send(s, "GET", "/metrics", "")
+	if w.Code != http.StatusNotFound || strings.Contains(w.Body.String(), "Hello") {
+		t.Errorf("got %d %q, want a 404", w.Code, w.Body)
+	}
+}
+
+func TestUnknownFeature(t *testing.T) {
+	_, err := NewSearchAppWithConfig(Config{Key: testKey, DisabledFeatures: []string{"suggest"}})
+	if err == nil || !strings.Contains(err.Error(), `unknown feature "suggest"`) {
+		t.Errorf("got error %v, want the unknown feature", err)
+	}
+}
+
+func TestFeatures(t *testing.T) {
+	features := Features()
+	for i := 1; i < len(features); i++ {
+		if features[i-1] >= features[i] {
+			t.Errorf("got %v, want them sorted", features)
+		}
+	}
+	for _, name := range []string{FeatureMerge, FeatureMetrics, FeatureHistory} {
+		if !slices.Contains(features, name) {
+			t.Errorf("got %v, want %s", features, name)
+		}
+	}
+}
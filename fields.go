@@ -0,0 +1,55 @@
+package main
+
+// DetailField is a labelled field of a *Detail, for displaying it as a table.
+type DetailField struct {
+	Label string `json:"label"`
+	Value string `json:"value"`
+}
+
+// Fields returns the fields of the detail that have a value, in the order
+// they'd be shown in: what the title is, then its ratings, plot and people,
+// then everything else. Fields that are empty or "N/A" are left out, as are
+// the poster, which is an image, and the fields describing the response
+// rather than the title. Each rating is its own field, labelled with its
+// source, other than IMDb's, which is already the IMDb Rating field.
+func (d *Detail) Fields() []DetailField {
+	fields := []DetailField{
+		{"Title", d.Title},
+		{"Year", d.Year},
+		{"Type", d.Type},
+		{"Rated", d.Rated},
+		{"Released", d.Released},
+		{"Runtime", d.Runtime},
+		{"Genre", d.Genre},
+		{"IMDb Rating", d.IMDBRating},
+		{"IMDb Votes", d.IMDBVotes},
+		{"Metascore", d.Metascore},
+	}
+	for _, r := range d.Ratings {
+		if r != nil && r.Source != "Internet Movie Database" {
+			fields = append(fields, DetailField{r.Source, r.Value})
+		}
+	}
+	fields = append(fields, []DetailField{
+		{"Plot", d.Plot},
+		{"Director", d.Director},
+		{"Writer", d.Writer},
+		{"Actors", d.Actors},
+		{"Language", d.Language},
+		{"Country", d.Country},
+		{"Awards", d.Awards},
+		{"DVD", d.DVD},
+		{"Box Office", d.BoxOffice},
+		{"Production", d.Production},
+		{"Website", d.Website},
+		{"IMDb ID", d.IMDBID},
+	}...)
+
+	present := fields[:0]
+	for _, f := range fields {
+		if f.Value != "" && f.Value != "N/A" {
+			present = append(present, f)
+		}
+	}
+	return present
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDetailFields(t *testing.T) {
+	d := &Detail{
+		Title:      "The Matrix",
+		Year:       "1999",
+		Rated:      "R",
+		Released:   "31 Mar 1999",
+		Runtime:    "136 min",
+		Genre:      "Action, Sci-Fi",
+		Director:   "Lana Wachowski, Lilly Wachowski",
+		Writer:     "N/A",
+		Actors:     "Keanu Reeves, Laurence Fishburne",
+		Plot:       "A computer hacker learns about the true nature of reality.",
+		Language:   "English",
+		Country:    "United States",
+		Awards:     "Won 4 Oscars",
+		Poster:     "https://m.media-amazon.com/images/M/tt0133093.jpg",
+		Metascore:  "73",
+		IMDBRating: "8.7",
+		IMDBVotes:  "2,000,000",
+		IMDBID:     "tt0133093",
+		Type:       "movie",
+		DVD:        "N/A",
+		BoxOffice:  "$172,076,928",
+		Response:   "True",
+		Ratings: []*Rating{
+			{Source: "Internet Movie Database", Value: "8.7/10"},
+			nil,
+			{Source: "Rotten Tomatoes", Value: "83%"},
+			{Source: "Metacritic", Value: "73/100"},
+		},
+	}
+
+	want := []DetailField{
+		{"Title", "The Matrix"},
+		{"Year", "1999"},
+		{"Type", "movie"},
+		{"Rated", "R"},
+		{"Released", "31 Mar 1999"},
+		{"Runtime", "136 min"},
+		{"Genre", "Action, Sci-Fi"},
+		{"IMDb Rating", "8.7"},
+		{"IMDb Votes", "2,000,000"},
+		{"Metascore", "73"},
+		{"Rotten Tomatoes", "83%"},
+		{"Metacritic", "73/100"},
+		{"Plot", "A computer hacker learns about the true nature of reality."},
+		{"Director", "Lana Wachowski, Lilly Wachowski"},
+		{"Actors", "Keanu Reeves, Laurence Fishburne"},
+		{"Language", "English"},
+		{"Country", "United States"},
+		{"Awards", "Won 4 Oscars"},
+		{"Box Office", "$172,076,928"},
+		{"IMDb ID", "tt0133093"},
+	}
+	if got := d.Fields(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestDetailFieldsEmpty(t *testing.T) {
+	d := &Detail{Title: "Unknown", Year: "N/A", Response: "True"}
+	if got, want := d.Fields(), []DetailField{{"Title", "Unknown"}}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
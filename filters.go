@@ -0,0 +1,135 @@
+package main
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// hasPoster returns true if r has a poster image.
+func hasPoster(r *SearchResult) bool {
+	return r.Poster != "" && r.Poster != "N/A"
+}
+
+// filterResults returns the results that keep returns true for.
+func filterResults(results []*SearchResult, keep func(*SearchResult) bool) []*SearchResult {
+	var filtered []*SearchResult
+	for _, r := range results {
+		if keep(r) {
+			filtered = append(filtered, r)
+		}
+	}
+	return filtered
+}
+
+// filterSearch returns the results of searching for sr that pass the filters
+// sr asks for, and are of an allowed type. It's shared by every endpoint that
+// takes a SearchRequest, so that the filters mean the same thing for all of
+// them.
+func (s *SearchApp) filterSearch(sr *SearchRequest, results []*SearchResult) []*SearchResult {
+	if s.allowedTypes != nil {
+		results = filterResults(results, func(r *SearchResult) bool {
+			return s.typeAllowed(r.Type)
+		})
+	}
+
+	if sr.RequirePoster {
+		results = filterResults(results, hasPoster)
+	}
+
+	if sr.IDPattern != "" {
+		pattern := regexp.MustCompile(sr.IDPattern)
+		results = filterResults(results, func(r *SearchResult) bool {
+			return pattern.MatchString(r.IMDBID)
+		})
+	}
+
+	switch {
+	case sr.Type != "" && sr.ReleaseYear != "":
+		results = filterResults(results, typeYearFilter(sr.Type, sr.ReleaseYear))
+	case sr.ExactYear && sr.ReleaseYear != "":
+		results = filterResults(results, yearFilter(sr.ReleaseYear, sr.YearRanges))
+	}
+
+	if sr.MinYear > 0 {
+		results = filterResults(results, minYearFilter(sr.MinYear))
+	}
+
+	return results
+}
+
+// parseYears parses an OMDb Year, which is either a single year like "1999"
+// or, for series, a range like "2011–2019" or an open-ended range like
+// "2020–". end is the same as start for a single year, and 0 for an
+// open-ended range.
+func parseYears(year string) (start, end int, ok bool) {
+	parts := strings.SplitN(year, "–", 2)
+	if len(parts) == 1 {
+		// OMDb uses an en dash, but a hyphen is accepted too.
+		parts = strings.SplitN(year, "-", 2)
+	}
+
+	start, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+	if err != nil {
+		return 0, 0, false
+	}
+
+	if len(parts) == 1 {
+		return start, start, true
+	}
+
+	if strings.TrimSpace(parts[1]) == "" {
+		return start, 0, true
+	}
+
+	end, err = strconv.Atoi(strings.TrimSpace(parts[1]))
+	if err != nil {
+		return 0, 0, false
+	}
+	return start, end, true
+}
+
+// minYearFilter returns a filter that keeps results released in year or
+// later. Series are kept if they were still running in year, including those
+// that are still running, with an open-ended range. Results whose Year can't
+// be parsed are dropped, as they can't be shown to be recent.
+func minYearFilter(year int) func(*SearchResult) bool {
+	return func(r *SearchResult) bool {
+		_, end, ok := parseYears(r.Year)
+		return ok && (end == 0 || end >= year)
+	}
+}
+
+// typeYearFilter returns the filter applied when a search has both a type
+// and a year, since OMDb sometimes ignores one of them when they conflict.
+// Type takes precedence: results of another type are always dropped. The
+// year is then matched against what's left, exactly for movies and episodes,
+// and by the range of years they ran for series, since a series matches any
+// year it was on air.
+func typeYearFilter(typ, year string) func(*SearchResult) bool {
+	inYear := yearFilter(year, strings.EqualFold(typ, "series"))
+	return func(r *SearchResult) bool {
+		return strings.EqualFold(r.Type, typ) && inYear(r)
+	}
+}
+
+// yearFilter returns a filter that keeps results released in year. If
+// ranges is true, results whose Year is a range, i.e. series, are kept if the
+// range includes year. Otherwise only an exact match of Year is kept.
+func yearFilter(year string, ranges bool) func(*SearchResult) bool {
+	return func(r *SearchResult) bool {
+		if r.Year == year {
+			return true
+		}
+		if !ranges {
+			return false
+		}
+
+		y, err := strconv.Atoi(year)
+		if err != nil {
+			return false
+		}
+		start, end, ok := parseYears(r.Year)
+		return ok && start <= y && (end == 0 || y <= end)
+	}
+}
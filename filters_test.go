@@ -0,0 +1,248 @@
+package main
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// posterResults are results with and without posters.
+var posterResults = []*SearchResult{
+	{Title: "With", IMDBID: "tt1", Poster: "https://m.media-amazon.com/images/M/tt1.jpg"},
+	{Title: "Not available", IMDBID: "tt2", Poster: "N/A"},
+	{Title: "Empty", IMDBID: "tt3"},
+	{Title: "Also with", IMDBID: "tt4", Poster: "https://m.media-amazon.com/images/M/tt4.jpg"},
+}
+
+func TestHasPoster(t *testing.T) {
+	if got, want := ids(filterResults(posterResults, hasPoster)), []string{"tt1", "tt4"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestSearchRequirePoster(t *testing.T) {
+	omdb := newFakeOMDb(t, func(w http.ResponseWriter, r *http.Request) {
+		writeResults(w, len(posterResults), posterResults...)
+	})
+	s := newTestApp(t, omdb, Config{})
+
+	results := decodeResults(t, serveRequest(s, newSearchRequest(`{"title":"posters"}`)))
+	if len(results) != len(posterResults) {
+		t.Errorf("got %v, want every result by default", ids(results))
+	}
+
+	results = decodeResults(t, serveRequest(s, newSearchRequest(`{"title":"posters","require_poster":true}`)))
+	if got, want := ids(results), []string{"tt1", "tt4"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+// yearResults are a movie and series with single years, ranges and
+// open-ended ranges.
+var yearResults = []*SearchResult{
+	{Title: "Movie", Year: "2011", IMDBID: "tt1", Type: "movie"},
+	{Title: "Later movie", Year: "2012", IMDBID: "tt2", Type: "movie"},
+	{Title: "Ended series", Year: "2008–2013", IMDBID: "tt3", Type: "series"},
+	{Title: "Running series", Year: "2010–", IMDBID: "tt4", Type: "series"},
+	{Title: "Series in 2011", Year: "2011–2015", IMDBID: "tt5", Type: "series"},
+	{Title: "Unknown", Year: "N/A", IMDBID: "tt6", Type: "movie"},
+}
+
+func TestParseYears(t *testing.T) {
+	for _, test := range []struct {
+		year       string
+		start, end int
+		ok         bool
+	}{
+		{"1999", 1999, 1999, true},
+		{"2011–2019", 2011, 2019, true},
+		{"2011-2019", 2011, 2019, true},
+		{"2020–", 2020, 0, true},
+		{"N/A", 0, 0, false},
+		{"2011–N/A", 0, 0, false},
+		{"", 0, 0, false},
+	} {
+		start, end, ok := parseYears(test.year)
+		if start != test.start || end != test.end || ok != test.ok {
+			t.Errorf("parseYears(%q) = %d, %d, %t, want %d, %d, %t", test.year, start, end, ok, test.start, test.end, test.ok)
+		}
+	}
+}
+
+func TestYearFilter(t *testing.T) {
+	for _, test := range []struct {
+		year   string
+		ranges bool
+		want   []string
+	}{
+		{"2011", false, []string{"tt1"}},
+		{"2011", true, []string{"tt1", "tt3", "tt4", "tt5"}},
+		{"2012", true, []string{"tt2", "tt3", "tt4", "tt5"}},
+		{"2014", true, []string{"tt4", "tt5"}},
+		{"2008–2013", false, []string{"tt3"}},
+	} {
+		if got := ids(filterResults(yearResults, yearFilter(test.year, test.ranges))); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s with ranges %t: got %v, want %v", test.year, test.ranges, got, test.want)
+		}
+	}
+}
+
+func TestSearchExactYear(t *testing.T) {
+	omdb := newFakeOMDb(t, func(w http.ResponseWriter, r *http.Request) {
+		writeResults(w, len(yearResults), yearResults...)
+	})
+	s := newTestApp(t, omdb, Config{})
+
+	for _, test := range []struct {
+		body string
+		want []string
+	}{
+		{`{"title":"years","release_year":"2011"}`, ids(yearResults)},
+		{`{"title":"years","release_year":"2011","exact_year":true}`, []string{"tt1"}},
+		{`{"title":"years","release_year":"2011","exact_year":true,"year_ranges":true}`, []string{"tt1", "tt3", "tt4", "tt5"}},
+		{`{"title":"years","exact_year":true}`, ids(yearResults)},
+	} {
+		if got := ids(decodeResults(t, serveRequest(s, newSearchRequest(test.body)))); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %v, want %v", test.body, got, test.want)
+		}
+	}
+	if got := omdb.query(1).Get("y"); got != "2011" {
+		t.Errorf("got y=%q, want the year sent to OMDb", got)
+	}
+}
+
+func TestSearchIDPattern(t *testing.T) {
+	omdb := newFakeOMDb(t, func(w http.ResponseWriter, r *http.Request) {
+		writeResults(w, 3, resultsFor("tt0133093", "tt0234215", "tt10838180")...)
+	})
+	s := newTestApp(t, omdb, Config{})
+
+	for _, test := range []struct {
+		pattern string
+		want    []string
+	}{
+		{"", []string{"tt0133093", "tt0234215", "tt10838180"}},
+		{`^tt0`, []string{"tt0133093", "tt0234215"}},
+		{`^tt\d{8}$`, []string{"tt10838180"}},
+		{`^nm`, []string{}},
+	} {
+		w := serveRequest(s, newSearchRequest(`{"title":"matrix","id_pattern":"`+strings.ReplaceAll(test.pattern, `\`, `\\`)+`"}`))
+		if got := ids(decodeResults(t, w)); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%q: got %v, want %v", test.pattern, got, test.want)
+		}
+	}
+}
+
+func TestSearchInvalidIDPattern(t *testing.T) {
+	omdb := newFakeOMDb(t, pagedResults(1))
+	s := newTestApp(t, omdb, Config{})
+
+	for _, pattern := range []string{"tt(", strings.Repeat("a", maxIDPatternLength+1)} {
+		w := serveRequest(s, newSearchRequest(`{"title":"matrix","id_pattern":"`+pattern+`"}`))
+		if w.Code != http.StatusUnprocessableEntity || !strings.Contains(w.Body.String(), "invalid id_pattern") {
+			t.Errorf("%q: got %d %q, want a 422", pattern, w.Code, w.Body)
+		}
+	}
+	if n := omdb.calls(); n != 0 {
+		t.Errorf("got %d calls to OMDb, want none", n)
+	}
+}
+
+func TestTypeYearFilter(t *testing.T) {
+	for _, test := range []struct {
+		typ, year string
+		want      []string
+	}{
+		{"movie", "2011", []string{"tt1"}},
+		{"MOVIE", "2012", []string{"tt2"}},
+		{"series", "2011", []string{"tt3", "tt4", "tt5"}},
+		{"series", "2014", []string{"tt4", "tt5"}},
+		{"series", "2009", []string{"tt3"}},
+		{"movie", "2013", []string{}},
+		{"episode", "2011", []string{}},
+	} {
+		if got := ids(filterResults(yearResults, typeYearFilter(test.typ, test.year))); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s in %s: got %v, want %v", test.typ, test.year, got, test.want)
+		}
+	}
+}
+
+func TestSearchTypeYear(t *testing.T) {
+	omdb := newFakeOMDb(t, func(w http.ResponseWriter, r *http.Request) {
+		writeResults(w, len(yearResults), yearResults...)
+	})
+	s := newTestApp(t, omdb, Config{})
+
+	for i, test := range []struct {
+		body string
+		want []string
+	}{
+		// OMDb ignoring either filter is made up for by filtering for both,
+		// whatever exact_year and year_ranges say.
+		{`{"title":"years","type":"movie","release_year":"2011"}`, []string{"tt1"}},
+		{`{"title":"years","type":"series","release_year":"2011"}`, []string{"tt3", "tt4", "tt5"}},
+		{`{"title":"years","type":"series","release_year":"2011","exact_year":true}`, []string{"tt3", "tt4", "tt5"}},
+		{`{"title":"years","type":"movie"}`, ids(yearResults)},
+	} {
+		if got := ids(decodeResults(t, serveRequest(s, newSearchRequest(test.body)))); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %v, want %v", test.body, got, test.want)
+		}
+		if q := omdb.query(i); i < 3 && (q.Get("type") == "" || q.Get("y") != "2011") {
+			t.Errorf("%s: got query %v, want both filters sent to OMDb", test.body, q)
+		}
+	}
+}
+
+func TestMinYearFilter(t *testing.T) {
+	for _, test := range []struct {
+		year int
+		want []string
+	}{
+		{2000, []string{"tt1", "tt2", "tt3", "tt4", "tt5"}},
+		{2012, []string{"tt2", "tt3", "tt4", "tt5"}},
+		{2014, []string{"tt4", "tt5"}},
+		{2030, []string{"tt4"}},
+	} {
+		if got := ids(filterResults(yearResults, minYearFilter(test.year))); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%d: got %v, want %v", test.year, got, test.want)
+		}
+	}
+}
+
+func TestSearchMinYear(t *testing.T) {
+	omdb := newFakeOMDb(t, func(w http.ResponseWriter, r *http.Request) {
+		writeResults(w, len(yearResults), yearResults...)
+	})
+	s := newTestApp(t, omdb, Config{})
+
+	for _, test := range []struct {
+		body string
+		want []string
+	}{
+		{`{"title":"years","min_year":2014}`, []string{"tt4", "tt5"}},
+		{`{"title":"years","min_year":2011,"release_year":"2011","exact_year":true}`, []string{"tt1"}},
+		{`{"title":"years","min_year":2011,"release_year":"2012","exact_year":true,"year_ranges":true}`, []string{"tt2", "tt3", "tt4", "tt5"}},
+		{`{"title":"years","min_year":2014,"type":"series","release_year":"2014"}`, []string{"tt4", "tt5"}},
+	} {
+		if got := ids(decodeResults(t, serveRequest(s, newSearchRequest(test.body)))); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got %v, want %v", test.body, got, test.want)
+		}
+	}
+	if got := omdb.query(0).Get("y"); got != "" {
+		t.Errorf("got y=%q, want the minimum year kept from OMDb", got)
+	}
+}
+
+func TestSearchInvalidMinYear(t *testing.T) {
+	s := newTestApp(t, newFakeOMDb(t, pagedResults(1)), Config{})
+
+	for _, body := range []string{
+		`{"title":"years","min_year":-1}`,
+		`{"title":"years","min_year":2015,"release_year":"2011"}`,
+	} {
+		if w := serveRequest(s, newSearchRequest(body)); w.Code != http.StatusUnprocessableEntity || !strings.Contains(w.Body.String(), "min_year") {
+			t.Errorf("%s: got %d %q, want a 422 for the min_year", body, w.Code, w.Body)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// flightTimeout is how long a search shared by coalesced callers can take.
+const flightTimeout = 30 * time.Second
+
+// keptResults are the results of a completed search that are shared with
+// callers arriving within the coalesce window.
+type keptResults struct {
+	results []*SearchResult
+}
+
+// flightGroup coalesces concurrent searches with the same key so that only
+// one upstream call is made for all of them. Results, including errors, are
+// shared with callers that arrive while the call is in flight. If window is
+// set, successful results are also shared with callers that arrive within
+// window of the call completing, for bursts of searches that aren't quite
+// simultaneous. Errors aren't, so that a failure isn't repeated to callers
+// whose own call might succeed.
+type flightGroup struct {
+	window time.Duration
+	group  singleflight.Group
+
+	mu   sync.Mutex
+	kept map[string]*keptResults
+}
+
+// Do calls fn and returns its results, unless there's already a call in
+// flight for key, in which case it returns that call's results. Either way,
+// ctx's error is returned if ctx is done first. fn is called with a context
+// that has the values of ctx, such as the client's API key, but not its
+// cancellation, so that the caller that started the search going away
+// doesn't fail the others. shared is true if the results were given to more
+// than one caller.
+func (g *flightGroup) Do(ctx context.Context, key string, fn func(context.Context) ([]*SearchResult, error)) (results []*SearchResult, err error, shared bool) {
+	if results, ok := g.lookup(key); ok {
+		return results, nil, true
+	}
+
+	ch := g.group.DoChan(key, func() (any, error) {
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), flightTimeout)
+		defer cancel()
+
+		// The results are kept before the call completes, so that there's
+		// no gap between the two for another call to slip into.
+		results, err := fn(ctx)
+		if err == nil && g.window > 0 {
+			g.keep(key, results)
+		}
+		return results, err
+	})
+
+	select {
+	case res := <-ch:
+		results, _ := res.Val.([]*SearchResult)
+		return results, res.Err, res.Shared
+	case <-ctx.Done():
+		return nil, ctx.Err(), false
+	}
+}
+
+// lookup returns the results kept for key, if there are any.
+func (g *flightGroup) lookup(key string) ([]*SearchResult, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	k, ok := g.kept[key]
+	if !ok {
+		return nil, false
+	}
+	return k.results, true
+}
+
+// keep keeps results for key until window has passed.
+func (g *flightGroup) keep(key string, results []*SearchResult) {
+	k := &keptResults{results: results}
+
+	g.mu.Lock()
+	if g.kept == nil {
+		g.kept = make(map[string]*keptResults)
+	}
+	g.kept[key] = k
+	g.mu.Unlock()
+
+	time.AfterFunc(g.window, func() {
+		g.mu.Lock()
+		if g.kept[key] == k {
+			delete(g.kept, key)
+		}
+		g.mu.Unlock()
+	})
+}
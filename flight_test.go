@@ -0,0 +1,244 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// doConcurrently calls g.Do for key from n goroutines at once with fn, which
+// doesn't return until every goroutine has at least been started, and returns
+// the number of times fn was called and the results of each Do.
+func doConcurrently(g *flightGroup, key string, n int, fn func() ([]*SearchResult, error)) (int64, [][]*SearchResult) {
+	var (
+		calls   int64
+		started sync.WaitGroup
+		done    sync.WaitGroup
+		results = make([][]*SearchResult, n)
+	)
+	started.Add(n)
+	done.Add(n)
+	for i := range n {
+		go func() {
+			defer done.Done()
+			started.Done()
+			results[i], _, _ = g.Do(context.Background(), key, func(context.Context) ([]*SearchResult, error) {
+				atomic.AddInt64(&calls, 1)
+				started.Wait()
+				// Give the other goroutines time to join the call.
+				time.Sleep(50 * time.Millisecond)
+				return fn()
+			})
+		}()
+	}
+	done.Wait()
+	return atomic.LoadInt64(&calls), results
+}
+
+func TestFlightGroupCoalesces(t *testing.T) {
+	var g flightGroup
+	calls, results := doConcurrently(&g, "alien", 10, func() ([]*SearchResult, error) {
+		return resultsFor("tt1"), nil
+	})
+
+	if calls != 1 {
+		t.Errorf("got %d calls, want 1", calls)
+	}
+	for i, r := range results {
+		if len(r) != 1 || r[0].IMDBID != "tt1" {
+			t.Errorf("caller %d got %v, want tt1", i, ids(r))
+		}
+	}
+}
+
+func TestFlightGroupKeys(t *testing.T) {
+	var g flightGroup
+	for _, key := range []string{"alien", "aliens"} {
+		if _, _, shared := g.Do(context.Background(), key, func(context.Context) ([]*SearchResult, error) { return nil, nil }); shared {
+			t.Errorf("%s: got shared results, want a call of its own", key)
+		}
+	}
+}
+
+func TestFlightGroupErrorsNotKept(t *testing.T) {
+	g := flightGroup{window: time.Hour}
+	errUpstream := errors.New("upstream failed")
+
+	_, err, _ := g.Do(context.Background(), "alien", func(context.Context) ([]*SearchResult, error) { return nil, errUpstream })
+	if err != errUpstream {
+		t.Fatalf("got error %v, want %v", err, errUpstream)
+	}
+
+	// The failed call has completed, so the next caller makes its own, even
+	// within the window.
+	results, err, shared := g.Do(context.Background(), "alien", func(context.Context) ([]*SearchResult, error) { return resultsFor("tt1"), nil })
+	if err != nil || shared || len(results) != 1 {
+		t.Errorf("got %v, %v, shared %t, want a call of its own", ids(results), err, shared)
+	}
+}
+
+func TestFlightGroupCallerContext(t *testing.T) {
+	var g flightGroup
+	release := make(chan struct{})
+	detached := make(chan error, 1)
+
+	// The caller that starts the search gives up on it, which doesn't cancel
+	// the search itself.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err, _ := g.Do(ctx, "alien", func(ctx context.Context) ([]*SearchResult, error) {
+		<-release
+		detached <- ctx.Err()
+		return resultsFor("tt1"), nil
+	})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got error %v, want the caller's own context error", err)
+	}
+
+	waiter := make(chan []*SearchResult)
+	go func() {
+		results, _, _ := g.Do(context.Background(), "alien", nil)
+		waiter <- results
+	}()
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+
+	if err := <-detached; err != nil {
+		t.Errorf("got context error %v in the search, want it detached from the caller", err)
+	}
+	if results := <-waiter; len(results) != 1 || results[0].IMDBID != "tt1" {
+		t.Errorf("got %v, want the waiter to share tt1", ids(results))
+	}
+}
+
+func TestFlightGroupWaiterContext(t *testing.T) {
+	var g flightGroup
+	release := make(chan struct{})
+	defer close(release)
+
+	inFlight := make(chan struct{})
+	go g.Do(context.Background(), "alien", func(context.Context) ([]*SearchResult, error) {
+		close(inFlight)
+		<-release
+		return nil, nil
+	})
+	<-inFlight
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err, _ := g.Do(ctx, "alien", nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got error %v, want the waiter's own context error", err)
+	}
+}
+
+func TestSearchCoalesced(t *testing.T) {
+	release := make(chan struct{})
+	omdb := newFakeOMDb(t, func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		writeResults(w, 1, resultsFor("tt1")...)
+	})
+	s := newTestApp(t, omdb, Config{})
+
+	// The first client gives up while the search is in flight, which
+	// mustn't fail it for the others, as the search it started carries on.
+	first, cancel := context.WithCancel(context.Background())
+	firstErr := make(chan error, 1)
+	errs := make(chan error, 3)
+	search := func(ctx context.Context, errs chan<- error) {
+		results, err := s.search(ctx, &SearchRequest{Title: "alien"})
+		if err == nil && len(results) != 1 {
+			err = errors.New("got no results")
+		}
+		errs <- err
+	}
+	go search(first, firstErr)
+	for omdb.calls() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	for range 3 {
+		go search(context.Background(), errs)
+	}
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	if err := <-firstErr; !errors.Is(err, context.Canceled) {
+		t.Errorf("got error %v for the first client, want its own context error", err)
+	}
+	close(release)
+
+	for range 3 {
+		if err := <-errs; err != nil {
+			t.Error(err)
+		}
+	}
+	if n := omdb.calls(); n != 1 {
+		t.Errorf("got %d calls to OMDb, want 1", n)
+	}
+}
+
+func TestFlightGroupWindow(t *testing.T) {
+	g := flightGroup{window: 100 * time.Millisecond}
+	var calls int
+	fn := func(context.Context) ([]*SearchResult, error) {
+		calls++
+		return resultsFor("tt" + strconv.Itoa(calls)), nil
+	}
+
+	g.Do(context.Background(), "alien", fn)
+	// Staggered callers within the window share the completed call.
+	for range 3 {
+		time.Sleep(10 * time.Millisecond)
+		results, err, shared := g.Do(context.Background(), "alien", fn)
+		if err != nil || !shared || ids(results)[0] != "tt1" {
+			t.Errorf("got %v, %v, shared %t, want the first call's results", ids(results), err, shared)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("got %d calls within the window, want 1", calls)
+	}
+
+	// Once the window has passed, the next caller makes its own.
+	time.Sleep(200 * time.Millisecond)
+	results, _, shared := g.Do(context.Background(), "alien", fn)
+	if shared || ids(results)[0] != "tt2" || calls != 2 {
+		t.Errorf("got %v, shared %t after %d calls, want a call of its own", ids(results), shared, calls)
+	}
+}
+
+func TestFlightGroupNoWindow(t *testing.T) {
+	var g flightGroup
+	var calls int
+	for range 2 {
+		g.Do(context.Background(), "alien", func(context.Context) ([]*SearchResult, error) {
+			calls++
+			return nil, nil
+		})
+	}
+	if calls != 2 {
+		t.Errorf("got %d calls, want sequential searches not to be shared without a window", calls)
+	}
+}
+
+func TestSearchCoalesceWindow(t *testing.T) {
+	omdb := newFakeOMDb(t, pagedResults(5))
+	s := newTestApp(t, omdb, Config{CoalesceWindow: time.Minute})
+
+	for _, title := range []string{"alien", "Alien ", "ALIEN"} {
+		searchPage(t, s, title, 1)
+		time.Sleep(5 * time.Millisecond)
+	}
+	searchPage(t, s, "aliens", 1)
+	if n := omdb.calls(); n != 2 {
+		t.Errorf("got %d calls to OMDb, want the staggered searches to share 1", n)
+	}
+}
+
+func TestCoalesceWindowConfig(t *testing.T) {
+	if _, err := NewSearchAppWithConfig(Config{Key: testKey, CoalesceWindow: -time.Millisecond}); err == nil {
+		t.Error("got no error for a negative window")
+	}
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"unicode"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+// foldTitle returns title case folded and without diacritics, e.g. "amelie"
+// for "Amélie", for comparing titles the way a person would expect to. Both
+// precomposed letters and ones followed by combining marks are folded, by
+// decomposing them and dropping the marks. Letters that don't decompose,
+// such as "ø" or "æ", are kept as they are. It's only used to compare
+// titles; the titles in responses are never folded.
+func foldTitle(title string) string {
+	t := transform.Chain(norm.NFD, runes.Remove(runes.In(unicode.Mn)), cases.Fold(), norm.NFC)
+	folded, _, err := transform.String(t, title)
+	if err != nil {
+		return title
+	}
+	return folded
+}
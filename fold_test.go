@@ -0,0 +1,47 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestFoldTitle(t *testing.T) {
+	for title, want := range map[string]string{
+		"Amélie":                              "amelie",
+		"AMÉLIE":                              "amelie",
+		"Ame\u0301lie":                        "amelie",
+		"Crouching Tiger":                     "crouching tiger",
+		"Das Boot ß":                          "das boot ss",
+		"Æon Flux":                            "æon flux",
+		"Łódź":                                "łodz",
+		"Smörgåsbord":                         "smorgasbord",
+		"Le Fabuleux Destin d'Amélie Poulain": "le fabuleux destin d'amelie poulain",
+		"千と千尋の神隠し":                            "千と千尋の神隠し",
+		"":                                    "",
+	} {
+		if got := foldTitle(title); got != want {
+			t.Errorf("foldTitle(%q) = %q, want %q", title, got, want)
+		}
+	}
+}
+
+func TestSearchScoreAccents(t *testing.T) {
+	omdb := newFakeOMDb(t, func(w http.ResponseWriter, r *http.Request) {
+		writeResults(w, 2,
+			&SearchResult{Title: "Amélie", IMDBID: "tt1"},
+			&SearchResult{Title: "Amadeus", IMDBID: "tt2"},
+		)
+	})
+	s := newTestApp(t, omdb, Config{})
+
+	results := decodeResults(t, serveRequest(s, newSearchRequest(`{"title":"AMELIE","sort_by_score":true}`)))
+	if len(results) != 2 || results[0].IMDBID != "tt1" {
+		t.Fatalf("got %v, want Amélie first", ids(results))
+	}
+	if results[0].Score != 1 {
+		t.Errorf("got a score of %v for Amélie, want 1", results[0].Score)
+	}
+	if results[0].Title != "Amélie" {
+		t.Errorf("got title %q, want the title unchanged", results[0].Title)
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// ResultFormatter writes search results to a response, including its
+// Content-Type header.
+type ResultFormatter interface {
+	Format(w http.ResponseWriter, results []*SearchResult) error
+}
+
+// JSONFormatter is the default ResultFormatter. It writes the results as JSON
+// with the given key casing, wrapped in an *Envelope unless Raw is true.
+type JSONFormatter struct {
+	JSONCase string
+	Raw      bool
+}
+
+// Format writes results as JSON.
+func (f *JSONFormatter) Format(w http.ResponseWriter, results []*SearchResult) error {
+	if results == nil {
+		results = []*SearchResult{}
+	}
+
+	var v interface{} = withJSONCase(results, f.JSONCase)
+	if !f.Raw {
+		v = &Envelope{
+			APIVersion: ResponseVersion,
+			Results:    v,
+		}
+	}
+
+	jsonstr, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_, err = w.Write(jsonstr)
+	return err
+}
+
+// CSVFormatter is a ResultFormatter that writes the results as CSV, with the
+// same columns as /export.
+type CSVFormatter struct{}
+
+// Format writes results as CSV.
+func (CSVFormatter) Format(w http.ResponseWriter, results []*SearchResult) error {
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+
+	csvw := csv.NewWriter(w)
+	csvw.Write(exportColumns)
+	for _, r := range results {
+		csvw.Write([]string{r.IMDBID, r.Title, r.Year, r.Type, r.Poster})
+	}
+	csvw.Flush()
+	return csvw.Error()
+}
+
+// NDJSONFormatter is a ResultFormatter that writes each result as JSON on its
+// own line, with the given key casing, flushing after each one so that
+// clients can process them as they arrive. /export streams every page of a
+// search in the same format.
+type NDJSONFormatter struct {
+	JSONCase string
+}
+
+// Format writes results as NDJSON.
+func (f *NDJSONFormatter) Format(w http.ResponseWriter, results []*SearchResult) error {
+	w.Header().Set("Content-Type", "application/x-ndjson")
+
+	rc := http.NewResponseController(w)
+	enc := json.NewEncoder(w)
+	for _, r := range results {
+		if err := enc.Encode(resultWithJSONCase(r, f.JSONCase)); err != nil {
+			return err
+		}
+		if err := rc.Flush(); err != nil && err != http.ErrNotSupported {
+			return err
+		}
+	}
+	return nil
+}
+
+// acceptsNDJSON returns true if the Accept header of r asks for NDJSON.
+func acceptsNDJSON(r *http.Request) bool {
+	for _, accept := range strings.Split(r.Header.Get("Accept"), ",") {
+		if mediaType, _, err := mime.ParseMediaType(accept); err == nil && mediaType == "application/x-ndjson" {
+			return true
+		}
+	}
+	return false
+}
+
+// SetFormatter replaces the JSON formatter as the default for search
+// responses.
+func (s *SearchApp) SetFormatter(f ResultFormatter) {
+	s.formatter = f
+}
+
+// RegisterFormatter makes f selectable for a single search with the format
+// query parameter, e.g. /search?format=csv.
+func (s *SearchApp) RegisterFormatter(name string, f ResultFormatter) {
+	s.formatters[name] = f
+}
+
+// formatterFor returns the ResultFormatter for the response to r: the one
+// named by the format query parameter if it's registered, otherwise NDJSON if
+// it's asked for, otherwise the default.
+func (s *SearchApp) formatterFor(r *http.Request) ResultFormatter {
+	format := r.URL.Query().Get("format")
+	if f, ok := s.formatters[format]; ok {
+		return f
+	}
+	if format == ExportNDJSON || acceptsNDJSON(r) {
+		return &NDJSONFormatter{JSONCase: s.jsonCase}
+	}
+	if s.formatter != nil {
+		return s.formatter
+	}
+	return &JSONFormatter{
+		JSONCase: s.jsonCase,
+		Raw:      wantsRaw(r),
+	}
+}
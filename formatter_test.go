@@ -0,0 +1,189 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeFormatter is a ResultFormatter that records the results it's given.
+type fakeFormatter struct {
+	results [][]*SearchResult
+}
+
+func (f *fakeFormatter) Format(w http.ResponseWriter, results []*SearchResult) error {
+	f.results = append(f.results, results)
+	w.Header().Set("Content-Type", "text/plain")
+	fmt.Fprintf(w, "%d results", len(results))
+	return nil
+}
+
+func TestSetFormatter(t *testing.T) {
+	omdb := newFakeOMDb(t, func(w http.ResponseWriter, r *http.Request) {
+		writeResults(w, 2, resultsFor("tt1", "tt2")...)
+	})
+	s := newTestApp(t, omdb, Config{})
+	f := &fakeFormatter{}
+	s.SetFormatter(f)
+
+	w := serveRequest(s, newSearchRequest(`{"title":"alien"}`))
+	if w.Body.String() != "2 results" || w.Header().Get("Content-Type") != "text/plain" {
+		t.Errorf("got %q, want the formatter's output", w.Body)
+	}
+	if len(f.results) != 1 || !reflect.DeepEqual(ids(f.results[0]), []string{"tt1", "tt2"}) {
+		t.Errorf("got %d calls to the formatter, want 1 with the results", len(f.results))
+	}
+}
+
+func TestRegisterFormatter(t *testing.T) {
+	omdb := newFakeOMDb(t, func(w http.ResponseWriter, r *http.Request) {
+		writeResults(w, 2, resultsFor("tt1", "tt2")...)
+	})
+	s := newTestApp(t, omdb, Config{})
+	f := &fakeFormatter{}
+	s.RegisterFormatter("fake", f)
+	s.RegisterFormatter("csv", CSVFormatter{})
+
+	req := newSearchRequest(`{"title":"alien"}`)
+	req.URL.RawQuery = "format=fake"
+	if w := serveRequest(s, req); w.Body.String() != "2 results" {
+		t.Errorf("got %q, want the fake formatter's output", w.Body)
+	}
+
+	req = newSearchRequest(`{"title":"alien"}`)
+	req.URL.RawQuery = "format=csv"
+	w := serveRequest(s, req)
+	records, err := csv.NewReader(w.Body).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 3 || !reflect.DeepEqual(records[0], exportColumns) || records[1][0] != "tt1" {
+		t.Errorf("got %q, want the CSV of the results", records)
+	}
+
+	// Formats that aren't registered use the default.
+	req = newSearchRequest(`{"title":"alien"}`)
+	req.URL.RawQuery = "format=xml"
+	if got := ids(decodeResults(t, serveRequest(s, req))); len(got) != 2 {
+		t.Errorf("got %v, want the JSON results", got)
+	}
+	if len(f.results) != 1 {
+		t.Errorf("got %d calls to the fake formatter, want 1", len(f.results))
+	}
+}
+
+func TestNDJSONFormatter(t *testing.T) {
+	omdb := newFakeOMDb(t, func(w http.ResponseWriter, r *http.Request) {
+		writeResults(w, 2, resultsFor("tt1", "tt2")...)
+	})
+	s := newTestApp(t, omdb, Config{})
+
+	req := newSearchRequest(`{"title":"alien"}`)
+	req.Header.Set("Accept", "application/x-ndjson")
+	w := serveRequest(s, req)
+	if got := w.Header().Get("Content-Type"); got != "application/x-ndjson" {
+		t.Errorf("got Content-Type %q, want NDJSON", got)
+	}
+
+	lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %q, want a line per result", w.Body)
+	}
+	for i, line := range lines {
+		var r SearchResult
+		if err := json.Unmarshal([]byte(line), &r); err != nil || r.IMDBID != "tt"+fmt.Sprint(i+1) {
+			t.Errorf("got line %q", line)
+		}
+	}
+}
+
+func TestSearchFormatNDJSON(t *testing.T) {
+	omdb := newFakeOMDb(t, func(w http.ResponseWriter, r *http.Request) {
+		writeResults(w, 1, resultsFor("tt1")...)
+	})
+	s := newTestApp(t, omdb, Config{})
+
+	req := newSearchRequest(`{"title":"alien"}`)
+	req.URL.RawQuery = "format=ndjson"
+	w := serveRequest(s, req)
+	if got := w.Header().Get("Content-Type"); got != "application/x-ndjson" {
+		t.Errorf("got Content-Type %q, want NDJSON", got)
+	}
+	if got := strings.Count(w.Body.String(), "\n"); got != 1 {
+		t.Errorf("got %q, want a single line", w.Body)
+	}
+}
+
+func TestExportNDJSON(t *testing.T) {
+	omdb := newFakeOMDb(t, pagedResults(25))
+	s := newTestApp(t, omdb, Config{})
+
+	w := send(s, "GET", "/export?title=alien&format=ndjson", "")
+	if got := w.Header().Get("Content-Type"); got != "application/x-ndjson" {
+		t.Errorf("got Content-Type %q, want NDJSON", got)
+	}
+
+	var got []string
+	for _, line := range strings.Split(strings.TrimSpace(w.Body.String()), "\n") {
+		var r SearchResult
+		if err := json.Unmarshal([]byte(line), &r); err != nil {
+			t.Fatalf("got line %q: %s", line, err)
+		}
+		got = append(got, r.IMDBID)
+	}
+	if len(got) != 25 || got[0] != "tt1" || got[24] != "tt25" {
+		t.Errorf("got %v, want every page in order", got)
+	}
+}
+
+func TestAcceptsNDJSON(t *testing.T) {
+	for accept, want := range map[string]bool{
+		"":                     false,
+		"application/json":     false,
+		"application/x-ndjson": true,
+		"application/json, application/x-ndjson; q=0.9": true,
+		"application/x-ndjsonx":                         false,
+	} {
+		req := newSearchRequest("")
+		req.Header.Set("Accept", accept)
+		if got := acceptsNDJSON(req); got != want {
+			t.Errorf("%q: got %t, want %t", accept, got, want)
+		}
+	}
+}
+
+func TestExportWriteTimeout(t *testing.T) {
+	// Each page takes longer than the whole WriteTimeout to fetch, so the
+	// export is only complete if the deadline is extended for every page.
+	omdb := newFakeOMDb(t, func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(60 * time.Millisecond)
+		pagedResults(35)(w, r)
+	})
+	s := newTestApp(t, omdb, Config{})
+
+	srv := httptest.NewUnstartedServer(s.Handler())
+	srv.Config.WriteTimeout = 50 * time.Millisecond
+	srv.Start()
+	t.Cleanup(srv.Close)
+
+	resp, err := http.Get(srv.URL + "/export?title=alien&format=ndjson")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("export was cut short: %s", err)
+	}
+	if got := strings.Count(string(body), "\n"); got != 35 {
+		t.Errorf("got %d rows, want 35", got)
+	}
+}
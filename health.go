@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// Readiness is the JSON document returned by /readyz.
+type Readiness struct {
+	Ready       bool       `json:"ready"`
+	LastSuccess *time.Time `json:"last_success"`
+	LastFailure *time.Time `json:"last_failure"`
+	AgeSeconds  float64    `json:"age_seconds"`
+}
+
+// LastSuccess returns the time of the last successful call to OMDb, or the
+// zero time if there hasn't been one.
+func (o *OMDBAPI) LastSuccess() time.Time {
+	return loadTime(&o.lastSuccess)
+}
+
+// LastFailure returns the time of the last failed call to OMDb, or the zero
+// time if there hasn't been one. Calls that the client canceled aren't
+// failures.
+func (o *OMDBAPI) LastFailure() time.Time {
+	return loadTime(&o.lastFailure)
+}
+
+// loadTime atomically loads the time in Unix nanoseconds at addr, which is
+// zero for the zero time.
+func loadTime(addr *int64) time.Time {
+	nanos := atomic.LoadInt64(addr)
+	if nanos == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, nanos)
+}
+
+// sinceLastSuccess returns how long it has been since the last successful call
+// to OMDb. If there hasn't been one, it's how long since the OMDBAPI was
+// created, so that a freshly started instance isn't immediately unready.
+func (o *OMDBAPI) sinceLastSuccess() time.Duration {
+	last := o.LastSuccess()
+	if last.IsZero() {
+		last = o.started
+	}
+	return time.Since(last)
+}
+
+// upstreamFailing returns whether the last call to OMDb failed within window.
+// An instance with no recent calls isn't failing, however long it has been
+// since the last successful one.
+func (o *OMDBAPI) upstreamFailing(window time.Duration) bool {
+	failure := o.LastFailure()
+	if failure.IsZero() || failure.Before(o.LastSuccess()) {
+		return false
+	}
+	return time.Since(failure) <= window
+}
+
+// Ready handles requests to /readyz. It responds with a 503 if the last call
+// to OMDb failed within the ready window, if one is set.
+func (s *SearchApp) Ready(w http.ResponseWriter, r *http.Request) {
+	age := s.omdb.sinceLastSuccess()
+	readiness := &Readiness{
+		Ready:      s.readyWindow <= 0 || !s.omdb.upstreamFailing(s.readyWindow),
+		AgeSeconds: age.Seconds(),
+	}
+	if last := s.omdb.LastSuccess(); !last.IsZero() {
+		readiness.LastSuccess = &last
+	}
+	if last := s.omdb.LastFailure(); !last.IsZero() {
+		readiness.LastFailure = &last
+	}
+
+	jsonstr, err := json.Marshal(readiness)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if !readiness.Ready {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	w.Write(jsonstr)
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// upstreamLatencyBuckets are the upper bounds, in seconds, of the buckets of
+// the upstream latency histogram. OMDb usually responds in between 50ms and
+// 2s, so the buckets are concentrated in that range.
+var upstreamLatencyBuckets = []float64{0.05, 0.1, 0.25, 0.5, 0.75, 1, 1.5, 2, 5}
+
+// Histogram counts durations into buckets, for reporting as a Prometheus
+// histogram. It's safe for concurrent use, and a nil *Histogram ignores
+// observations.
+type Histogram struct {
+	bounds []float64
+
+	// counts has a count for each bound, and a last one for the +Inf bucket.
+	// They aren't cumulative, and are accessed atomically, as is sumBits, the
+	// bits of the float64 sum of the observations in seconds.
+	counts  []uint64
+	sumBits uint64
+}
+
+// NewHistogram returns a new *Histogram with buckets with the upper bounds,
+// in seconds, of bounds, which must be sorted.
+func NewHistogram(bounds []float64) *Histogram {
+	return &Histogram{
+		bounds: bounds,
+		counts: make([]uint64, len(bounds)+1),
+	}
+}
+
+// Observe adds d to the histogram.
+func (h *Histogram) Observe(d time.Duration) {
+	if h == nil {
+		return
+	}
+
+	v := d.Seconds()
+	i := sort.SearchFloat64s(h.bounds, v)
+	atomic.AddUint64(&h.counts[i], 1)
+
+	for {
+		old := atomic.LoadUint64(&h.sumBits)
+		sum := math.Float64bits(math.Float64frombits(old) + v)
+		if atomic.CompareAndSwapUint64(&h.sumBits, old, sum) {
+			return
+		}
+	}
+}
+
+// cumulative returns the cumulative count of each bucket, with the +Inf
+// bucket last.
+func (h *Histogram) cumulative() []uint64 {
+	counts := make([]uint64, len(h.counts))
+	var total uint64
+	for i := range h.counts {
+		total += atomic.LoadUint64(&h.counts[i])
+		counts[i] = total
+	}
+	return counts
+}
+
+// Quantile returns an estimate of the q quantile, e.g. 0.95, in seconds,
+// interpolating linearly within the bucket it falls in, as Prometheus's
+// histogram_quantile does. It returns 0 if nothing has been observed, and the
+// highest bound if the quantile is in the +Inf bucket.
+func (h *Histogram) Quantile(q float64) float64 {
+	counts := h.cumulative()
+	total := counts[len(counts)-1]
+	if total == 0 {
+		return 0
+	}
+
+	rank := q * float64(total)
+	i := sort.Search(len(counts), func(i int) bool { return float64(counts[i]) >= rank })
+	if i >= len(h.bounds) {
+		return h.bounds[len(h.bounds)-1]
+	}
+
+	var lower float64
+	var below uint64
+	if i > 0 {
+		lower = h.bounds[i-1]
+		below = counts[i-1]
+	}
+	inBucket := counts[i] - below
+	if inBucket == 0 {
+		return h.bounds[i]
+	}
+	return lower + (h.bounds[i]-lower)*(rank-float64(below))/float64(inBucket)
+}
+
+// write writes the histogram to w in the Prometheus text exposition format.
+// The percentiles are best queried from the buckets, e.g.
+//
+//	histogram_quantile(0.95, sum(rate(omdb_upstream_latency_seconds_bucket[5m])) by (le))
+func (h *Histogram) write(w io.Writer, name, help string) {
+	counts := h.cumulative()
+
+	fmt.Fprintf(w, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(w, "# TYPE %s histogram\n", name)
+	for i, bound := range h.bounds {
+		fmt.Fprintf(w, "%s_bucket{le=%q} %d\n", name, strconv.FormatFloat(bound, 'g', -1, 64), counts[i])
+	}
+	fmt.Fprintf(w, "%s_bucket{le=\"+Inf\"} %d\n", name, counts[len(counts)-1])
+	fmt.Fprintf(w, "%s_sum %s\n", name, strconv.FormatFloat(math.Float64frombits(atomic.LoadUint64(&h.sumBits)), 'g', -1, 64))
+	fmt.Fprintf(w, "%s_count %d\n", name, counts[len(counts)-1])
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"math"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHistogramQuantile(t *testing.T) {
+	h := NewHistogram([]float64{0.1, 0.5, 1})
+	if got := h.Quantile(0.5); got != 0 {
+		t.Errorf("got %g without observations, want 0", got)
+	}
+
+	// 50 observations in the first bucket, 40 in the second and 10 in the
+	// +Inf bucket.
+	for range 50 {
+		h.Observe(50 * time.Millisecond)
+	}
+	for range 40 {
+		h.Observe(300 * time.Millisecond)
+	}
+	for range 10 {
+		h.Observe(2 * time.Second)
+	}
+
+	for _, test := range []struct {
+		q, want float64
+	}{
+		{0.25, 0.05},
+		{0.5, 0.1},
+		{0.7, 0.3},
+		{0.9, 0.5},
+		{0.99, 1},
+	} {
+		if got := h.Quantile(test.q); math.Abs(got-test.want) > 1e-9 {
+			t.Errorf("Quantile(%g) = %g, want %g", test.q, got, test.want)
+		}
+	}
+}
+
+func TestHistogramWrite(t *testing.T) {
+	h := NewHistogram([]float64{0.1, 0.5})
+	h.Observe(50 * time.Millisecond)
+	h.Observe(250 * time.Millisecond)
+	h.Observe(time.Second)
+
+	var buf bytes.Buffer
+	h.write(&buf, "latency_seconds", "The latency.")
+	want := `# HELP latency_seconds The latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{le="0.1"} 1
+latency_seconds_bucket{le="0.5"} 2
+latency_seconds_bucket{le="+Inf"} 3
+latency_seconds_sum 1.3
+latency_seconds_count 3
+`
+	if buf.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", buf.String(), want)
+	}
+}
+
+func TestMetricsLatencyQuantiles(t *testing.T) {
+	omdb := newFakeOMDb(t, func(w http.ResponseWriter, r *http.Request) {
+		writeResults(w, 1, resultsFor("tt1")...)
+	})
+
+	for _, enabled := range []bool{false, true} {
+		s := newTestApp(t, omdb, Config{LatencyQuantiles: enabled})
+		serveRequest(s, newSearchRequest(`{"title":"alien"}`))
+
+		body := send(s, "GET", "/metrics", "").Body.String()
+		if !strings.Contains(body, "omdb_upstream_latency_seconds_count 1\n") {
+			t.Errorf("got metrics %q, want the latency histogram", body)
+		}
+		for _, name := range []string{"p50", "p95", "p99"} {
+			if got := strings.Contains(body, "# TYPE omdb_upstream_latency_"+name+"_seconds gauge\n"); got != enabled {
+				t.Errorf("quantiles %t: got %s %t", enabled, name, got)
+			}
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// The page sizes of /search/history. A larger limit is clamped to
+// maxHistoryLimit.
+const (
+	defaultHistoryLimit = 20
+	maxHistoryLimit     = 100
+)
+
+// HistoryEntry is a search in the search history.
+type HistoryEntry struct {
+	Time    time.Time `json:"time"`
+	Title   string    `json:"title"`
+	Type    string    `json:"type,omitempty"`
+	Year    string    `json:"year,omitempty"`
+	Results int       `json:"results"`
+}
+
+// HistoryStore stores the search history. Implementations must be safe for
+// concurrent use.
+type HistoryStore interface {
+	// Add records a search.
+	Add(HistoryEntry) error
+
+	// Page returns up to limit searches, most recent first, skipping the
+	// first offset of them, and the number of searches in the history.
+	Page(offset, limit int) ([]HistoryEntry, int, error)
+}
+
+// SearchHistory is the default HistoryStore, which keeps the most recent
+// searches in memory, up to its size, dropping the oldest when it's full.
+type SearchHistory struct {
+	size int
+
+	mu      sync.Mutex
+	entries []HistoryEntry
+}
+
+// NewSearchHistory returns a new, empty *SearchHistory that keeps up to size
+// searches.
+func NewSearchHistory(size int) *SearchHistory {
+	return &SearchHistory{size: size}
+}
+
+// Add records a search, dropping the oldest one if the history is full.
+func (h *SearchHistory) Add(e HistoryEntry) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.entries = append(h.entries, e)
+	if len(h.entries) > h.size {
+		h.entries = h.entries[len(h.entries)-h.size:]
+	}
+	return nil
+}
+
+// Page returns up to limit searches, most recent first, skipping the first
+// offset of them, and the number of searches in the history.
+func (h *SearchHistory) Page(offset, limit int) ([]HistoryEntry, int, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	total := len(h.entries)
+	page := []HistoryEntry{}
+	for i := offset; i < total && len(page) < limit; i++ {
+		page = append(page, h.entries[total-1-i])
+	}
+	return page, total, nil
+}
+
+// all returns every search in the history, oldest first.
+func (h *SearchHistory) all() []HistoryEntry {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]HistoryEntry(nil), h.entries...)
+}
+
+// SetHistoryStore enables the search history, keeping it in store. Requests
+// to /search/history must include token as a bearer token in the
+// Authorization header, as it reveals what other clients have searched for.
+func (s *SearchApp) SetHistoryStore(store HistoryStore, token string) {
+	s.history = store
+	s.historyToken = token
+}
+
+// HistoryPage is the JSON document returned by /search/history.
+type HistoryPage struct {
+	Total    int            `json:"total"`
+	Offset   int            `json:"offset"`
+	Limit    int            `json:"limit"`
+	Searches []HistoryEntry `json:"searches"`
+}
+
+// recordSearch adds sr, which returned results, to the search history, if
+// it's enabled. A search isn't failed because it couldn't be recorded.
+func (s *SearchApp) recordSearch(sr *SearchRequest, results []*SearchResult) {
+	if s.history == nil {
+		return
+	}
+
+	err := s.history.Add(HistoryEntry{
+		Time:    time.Now(),
+		Title:   sr.Title,
+		Type:    sr.Type,
+		Year:    sr.ReleaseYear,
+		Results: len(results),
+	})
+	if err != nil {
+		log.Printf("error recording search for %q in the history: %s", sr.Title, err)
+	}
+}
+
+// History handles requests to /search/history, responding with a page of the
+// most recent searches, most recent first. The limit query parameter is the
+// page size, which defaults to defaultHistoryLimit and is clamped to
+// maxHistoryLimit, and offset is the number of searches to skip. It's a 404
+// unless the search history is enabled, and a 401 without its token.
+func (s *SearchApp) History(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" || s.history == nil {
+		http.NotFound(w, r)
+		return
+	}
+	s.requireToken(s.historyToken, s.historyPage)(w, r)
+}
+
+// historyPage responds to an authorized request to /search/history.
+func (s *SearchApp) historyPage(w http.ResponseWriter, r *http.Request) {
+	q, err := s.query(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	limit := defaultHistoryLimit
+	if v := q.Get("limit"); v != "" {
+		if limit, err = strconv.Atoi(v); err != nil || limit < 1 {
+			http.Error(w, "limit must be a positive integer", http.StatusBadRequest)
+			return
+		}
+		limit = min(limit, maxHistoryLimit)
+	}
+
+	var offset int
+	if v := q.Get("offset"); v != "" {
+		if offset, err = strconv.Atoi(v); err != nil || offset < 0 {
+			http.Error(w, "offset must not be negative", http.StatusBadRequest)
+			return
+		}
+	}
+
+	searches, total, err := s.history.Page(offset, limit)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	jsonstr, err := json.Marshal(&HistoryPage{
+		Total:    total,
+		Offset:   offset,
+		Limit:    limit,
+		Searches: searches,
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(jsonstr)
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+// historyTitles returns the titles of entries.
+func historyTitles(entries []HistoryEntry) []string {
+	titles := []string{}
+	for _, e := range entries {
+		titles = append(titles, e.Title)
+	}
+	return titles
+}
+
+// getHistory requests target from s with the admin token and decodes the
+// page of the history.
+func getHistory(t *testing.T, s *SearchApp, target string) *HistoryPage {
+	t.Helper()
+	w := sendWithToken(s, "GET", target, "secret")
+	if w.Code != http.StatusOK {
+		t.Fatalf("%s: got status %d, want 200: %s", target, w.Code, w.Body)
+	}
+	var page HistoryPage
+	if err := json.Unmarshal(w.Body.Bytes(), &page); err != nil {
+		t.Fatal(err)
+	}
+	return &page
+}
+
+func TestSearchHistoryPage(t *testing.T) {
+	h := NewSearchHistory(5)
+	for i := 1; i <= 7; i++ {
+		h.Add(HistoryEntry{Title: strconv.Itoa(i)})
+	}
+
+	for _, test := range []struct {
+		offset, limit int
+		want          []string
+	}{
+		{0, 2, []string{"7", "6"}},
+		{2, 2, []string{"5", "4"}},
+		{4, 2, []string{"3"}},
+		{5, 2, []string{}},
+		{0, 10, []string{"7", "6", "5", "4", "3"}},
+	} {
+		page, total, err := h.Page(test.offset, test.limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := historyTitles(page); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("offset %d, limit %d: got %v, want %v", test.offset, test.limit, got, test.want)
+		}
+		if total != 5 {
+			t.Errorf("got a total of %d, want the oldest searches dropped", total)
+		}
+	}
+}
+
+func TestHistory(t *testing.T) {
+	s := newTestApp(t, newFakeOMDb(t, pagedResults(3)), Config{HistorySize: 200, AdminToken: "secret"})
+
+	serveRequest(s, newSearchRequest(`{"title":"alien","type":"movie","release_year":"1999"}`))
+	page := getHistory(t, s, "/search/history")
+	if len(page.Searches) != 1 {
+		t.Fatalf("got %+v, want the search", page.Searches)
+	}
+	e := page.Searches[0]
+	if e.Title != "alien" || e.Type != "movie" || e.Year != "1999" || e.Results != 3 || e.Time.IsZero() {
+		t.Errorf("got %+v, want the search and its result count", e)
+	}
+}
+
+func TestHistoryPaging(t *testing.T) {
+	s := newTestApp(t, newFakeOMDb(t, pagedResults(0)), Config{HistorySize: 200, AdminToken: "secret"})
+	for i := 1; i <= 150; i++ {
+		s.history.Add(HistoryEntry{Title: strconv.Itoa(i)})
+	}
+
+	for _, test := range []struct {
+		target        string
+		offset, limit int
+		first         string
+		count         int
+	}{
+		{"/search/history", 0, defaultHistoryLimit, "150", defaultHistoryLimit},
+		{"/search/history?limit=5&offset=10", 10, 5, "140", 5},
+		{"/search/history?limit=1000", 0, maxHistoryLimit, "150", maxHistoryLimit},
+		{"/search/history?limit=10&offset=145", 145, 10, "5", 5},
+		{"/search/history?offset=150", 150, defaultHistoryLimit, "", 0},
+	} {
+		page := getHistory(t, s, test.target)
+		if page.Total != 150 || page.Offset != test.offset || page.Limit != test.limit {
+			t.Errorf("%s: got total %d, offset %d and limit %d, want 150, %d and %d", test.target, page.Total, page.Offset, page.Limit, test.offset, test.limit)
+		}
+		if len(page.Searches) != test.count {
+			t.Errorf("%s: got %d searches, want %d", test.target, len(page.Searches), test.count)
+		} else if test.count > 0 && page.Searches[0].Title != test.first {
+			t.Errorf("%s: got %q first, want %q", test.target, page.Searches[0].Title, test.first)
+		}
+		if page.Searches == nil {
+			t.Errorf("%s: got null searches, want a list", test.target)
+		}
+	}
+}
+
+func TestHistoryInvalidPage(t *testing.T) {
+	s := newTestApp(t, newFakeOMDb(t, pagedResults(0)), Config{HistorySize: 10, AdminToken: "secret"})
+
+	for _, target := range []string{
+		"/search/history?limit=0",
+		"/search/history?limit=-1",
+		"/search/history?limit=ten",
+		"/search/history?offset=-1",
+		"/search/history?offset=x",
+	} {
+		if w := sendWithToken(s, "GET", target, "secret"); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: got status %d, want 400", target, w.Code)
+		}
+	}
+}
+
+func TestHistoryAuth(t *testing.T) {
+	s := newTestApp(t, newFakeOMDb(t, pagedResults(0)), Config{HistorySize: 10, AdminToken: "secret"})
+
+	for token, want := range map[string]int{
+		"":       http.StatusUnauthorized,
+		"wrong":  http.StatusUnauthorized,
+		"secret": http.StatusOK,
+	} {
+		if w := sendWithToken(s, "GET", "/search/history", token); w.Code != want {
+			t.Errorf("token %q: got status %d, want %d", token, w.Code, want)
+		}
+	}
+
+	// It's not there at all unless it's enabled.
+	s = newTestApp(t, newFakeOMDb(t, pagedResults(0)), Config{AdminToken: "secret"})
+	if w := sendWithToken(s, "GET", "/search/history", "secret"); w.Code != http.StatusNotFound {
+		t.Errorf("got status %d with the history disabled, want 404", w.Code)
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// FileHistory is a HistoryStore that keeps the search history in a file, so
+// that it survives restarts. Each search is appended to the file as a line of
+// JSON, and the most recent ones are also kept in memory to serve pages from.
+// The file is rewritten with only those once it has twice as many lines, so
+// it doesn't grow without bound. It's meant for a single instance; instances
+// mustn't share a file.
+type FileHistory struct {
+	path   string
+	size   int
+	recent *SearchHistory
+
+	mu    sync.Mutex
+	f     *os.File
+	lines int
+}
+
+// OpenFileHistory returns a *FileHistory that keeps up to size searches in
+// the file at path, loading the searches already in it. The file is created
+// if it doesn't exist.
+func OpenFileHistory(path string, size int) (*FileHistory, error) {
+	h := &FileHistory{
+		path:   path,
+		size:   size,
+		recent: NewSearchHistory(size),
+	}
+	if err := h.load(); err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	h.f = f
+	return h, nil
+}
+
+// load reads the searches in the file into memory. A line that can't be
+// parsed, like a partial write cut short by a crash, is an error rather than
+// being skipped, so that it's noticed.
+func (h *FileHistory) load() error {
+	f, err := os.Open(h.path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		h.lines++
+		var e HistoryEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return fmt.Errorf("%s line %d: %w", h.path, h.lines, err)
+		}
+		h.recent.Add(e)
+	}
+	return scanner.Err()
+}
+
+// Add appends a search to the file, compacting it if it's full.
+func (h *FileHistory) Add(e HistoryEntry) error {
+	line, err := json.Marshal(&e)
+	if err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.recent.Add(e)
+	if _, err = h.f.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	h.lines++
+
+	if h.lines >= 2*h.size {
+		return h.compact()
+	}
+	return nil
+}
+
+// compact rewrites the file with only the searches kept in memory. The new
+// file is written alongside it and renamed over it, so that the history
+// isn't lost if that fails part way. The caller must hold h.mu.
+func (h *FileHistory) compact() error {
+	tmp := h.path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+
+	entries := h.recent.all()
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for i := range entries {
+		if err = enc.Encode(&entries[i]); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = w.Flush()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, h.path)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	// If the new file can't be opened, searches are still appended to the
+	// old one rather than failing, but as it has been replaced they're only
+	// kept in memory until the next compaction.
+	if f, err = os.OpenFile(h.path, os.O_WRONLY|os.O_APPEND, 0600); err != nil {
+		return err
+	}
+	h.f.Close()
+	h.f = f
+	h.lines = len(entries)
+	return nil
+}
+
+// Page returns a page of the most recent searches, from memory.
+func (h *FileHistory) Page(offset, limit int) ([]HistoryEntry, int, error) {
+	return h.recent.Page(offset, limit)
+}
+
+// Close closes the file.
+func (h *FileHistory) Close() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.f.Close()
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// testHistoryStore adds 5 searches to a store that keeps 3 of them, and checks
+// that it pages the most recent ones.
+func testHistoryStore(t *testing.T, store HistoryStore) {
+	t.Helper()
+	for i := 1; i <= 5; i++ {
+		if err := store.Add(HistoryEntry{Title: strconv.Itoa(i), Results: i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	page, total, err := store.Page(1, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := historyTitles(page), []string{"4", "3"}; !reflect.DeepEqual(got, want) || total != 3 {
+		t.Errorf("got %v of %d, want %v of 3", got, total, want)
+	}
+}
+
+// openFileHistory opens the *FileHistory at path, closing it when the test
+// ends.
+func openFileHistory(t *testing.T, path string, size int) *FileHistory {
+	t.Helper()
+	h, err := OpenFileHistory(path, size)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { h.Close() })
+	return h
+}
+
+func TestHistoryStores(t *testing.T) {
+	t.Run("memory", func(t *testing.T) {
+		testHistoryStore(t, NewSearchHistory(3))
+	})
+	t.Run("file", func(t *testing.T) {
+		testHistoryStore(t, openFileHistory(t, filepath.Join(t.TempDir(), "history"), 3))
+	})
+}
+
+func TestFileHistoryReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history")
+	h := openFileHistory(t, path, 3)
+	h.Add(HistoryEntry{Title: "alien", Type: "movie", Year: "1979", Results: 4})
+	h.Add(HistoryEntry{Title: "heat", Results: 2})
+	h.Close()
+
+	h = openFileHistory(t, path, 3)
+	page, total, err := h.Page(0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []HistoryEntry{
+		{Title: "heat", Results: 2},
+		{Title: "alien", Type: "movie", Year: "1979", Results: 4},
+	}
+	if !reflect.DeepEqual(page, want) || total != 2 {
+		t.Errorf("got %+v of %d, want %+v", page, total, want)
+	}
+}
+
+func TestFileHistoryCompact(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history")
+	h := openFileHistory(t, path, 3)
+	for i := 1; i <= 10; i++ {
+		if err := h.Add(HistoryEntry{Title: strconv.Itoa(i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// It was compacted to 3 lines after the 6th search and the 9th, then the
+	// 10th was appended.
+	if lines := bytes.Count(b, []byte("\n")); lines != 4 {
+		t.Errorf("got %d lines in the file, want 4", lines)
+	}
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("got %v for the temporary file, want it removed", err)
+	}
+
+	h.Close()
+	page, _, _ := openFileHistory(t, path, 3).Page(0, 10)
+	if got, want := historyTitles(page), []string{"10", "9", "8"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v after reopening, want %v", got, want)
+	}
+}
+
+func TestFileHistoryCorrupt(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history")
+	os.WriteFile(path, []byte(`{"title":"alien"}`+"\n"+`{"title":"he`), 0600)
+
+	if _, err := OpenFileHistory(path, 3); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("got error %v, want the corrupt line reported", err)
+	}
+}
+
+func TestHistoryFileConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history")
+	omdb := newFakeOMDb(t, pagedResults(3))
+	cfg := Config{HistorySize: 10, HistoryFile: path, AdminToken: "secret"}
+
+	s := newTestApp(t, omdb, cfg)
+	serveRequest(s, newSearchRequest(`{"title":"alien"}`))
+	s.history.(*FileHistory).Close()
+
+	// A new instance has the history of the last one.
+	s = newTestApp(t, omdb, cfg)
+	t.Cleanup(func() { s.history.(*FileHistory).Close() })
+	page := getHistory(t, s, "/search/history")
+	if got, want := historyTitles(page.Searches), []string{"alien"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+// failingHistory is a HistoryStore that can't record searches.
+type failingHistory struct {
+	SearchHistory
+}
+
+func (*failingHistory) Add(HistoryEntry) error {
+	return errors.New("disk full")
+}
+
+func TestHistoryStoreFailure(t *testing.T) {
+	log := captureLog(t)
+	s := newTestApp(t, newFakeOMDb(t, pagedResults(3)), Config{})
+	s.SetHistoryStore(&failingHistory{}, "secret")
+
+	if w := serveRequest(s, newSearchRequest(`{"title":"alien"}`)); w.Code != http.StatusOK {
+		t.Errorf("got status %d, want the search to succeed", w.Code)
+	}
+	if !strings.Contains(log.String(), "disk full") {
+		t.Errorf("got log %q, want the error logged", log)
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// The keys of the localized error messages returned by the handlers.
+const (
+	MsgNotFound    = "not_found"
+	MsgInvalidType = "invalid_type"
+	MsgInvalidYear = "invalid_year"
+
+	MsgTooManyResults = "too_many_results"
+	MsgTypeNotAllowed = "type_not_allowed"
+
+	MsgInvalidIDPattern = "invalid_id_pattern"
+	MsgEmptyTitle       = "empty_title"
+	MsgTitleTooLong     = "title_too_long"
+	MsgTitleTooShort    = "title_too_short"
+	MsgInvalidPage      = "invalid_page"
+	MsgInvalidCursor    = "invalid_cursor"
+
+	MsgInvalidPosterWidth = "invalid_poster_width"
+	MsgInvalidMinYear     = "invalid_min_year"
+)
+
+// defaultLanguage is used when none of the languages in the Accept-Language
+// header are in the catalog.
+const defaultLanguage = "en"
+
+// Catalog maps language tags to message keys to messages. Messages may
+// contain fmt verbs, which are filled in by Message.
+type Catalog map[string]map[string]string
+
+// DefaultCatalog is the catalog used by a new *SearchApp. Languages can be
+// added to it, or a *SearchApp can be given a different catalog.
+var DefaultCatalog = Catalog{
+	"en": {
+		MsgNotFound:    "no title matches %q",
+		MsgInvalidType: "invalid type %q, it is not a supported type",
+		MsgInvalidYear: "invalid year %q, must be four digits",
+
+		MsgTooManyResults: "too many titles match %q, try a longer or more specific title",
+		MsgTypeNotAllowed: "searching for type %q is not allowed",
+
+		MsgInvalidIDPattern: "invalid id_pattern %q, must be a regular expression of at most 64 characters",
+		MsgEmptyTitle:       "a title is required",
+		MsgTitleTooLong:     "title must be at most %d characters",
+		MsgTitleTooShort:    "title must be at least %d characters, shorter titles match too many titles to search for",
+		MsgInvalidPage:      "page must be between 1 and %d",
+		MsgInvalidCursor:    "invalid or expired cursor, start again from the first page",
+
+		MsgInvalidPosterWidth: "poster_width must be between 1 and %d",
+		MsgInvalidMinYear:     "invalid min_year %d, must not be negative or later than the year",
+	},
+	"es": {
+		MsgNotFound:    "ningún título coincide con %q",
+		MsgInvalidType: "tipo %q no válido, no es un tipo admitido",
+		MsgInvalidYear: "año %q no válido, debe tener cuatro dígitos",
+
+		MsgTooManyResults: "demasiados títulos coinciden con %q, pruebe un título más largo o específico",
+		MsgTypeNotAllowed: "no se permite buscar el tipo %q",
+
+		MsgInvalidIDPattern: "id_pattern %q no válido, debe ser una expresión regular de 64 caracteres como máximo",
+		MsgEmptyTitle:       "se requiere un título",
+		MsgTitleTooLong:     "el título debe tener como máximo %d caracteres",
+		MsgTitleTooShort:    "el título debe tener al menos %d caracteres, los títulos más cortos coinciden con demasiados títulos para buscarlos",
+		MsgInvalidPage:      "la página debe estar entre 1 y %d",
+		MsgInvalidCursor:    "cursor no válido o caducado, empiece de nuevo desde la primera página",
+
+		MsgInvalidPosterWidth: "poster_width debe estar entre 1 y %d",
+		MsgInvalidMinYear:     "min_year %d no válido, no debe ser negativo ni posterior al año",
+	},
+}
+
+// acceptedLanguages returns the language tags in an Accept-Language header,
+// ordered by their quality values.
+func acceptedLanguages(header string) []string {
+	type weighted struct {
+		tag string
+		q   float64
+	}
+
+	var langs []weighted
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(part, ";")
+		tag := strings.ToLower(strings.TrimSpace(fields[0]))
+		if tag == "" || tag == "*" {
+			continue
+		}
+
+		q := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if v, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					q = v
+				}
+			}
+		}
+		langs = append(langs, weighted{tag, q})
+	}
+
+	sort.SliceStable(langs, func(i, j int) bool {
+		return langs[i].q > langs[j].q
+	})
+
+	tags := make([]string, len(langs))
+	for i, l := range langs {
+		tags[i] = l.tag
+	}
+	return tags
+}
+
+// Message returns the message for key in the best language from the
+// Accept-Language header, formatted with args. A tag such as "es-MX" falls
+// back to "es", and English is used if no accepted language has the message.
+func (c Catalog) Message(acceptLanguage, key string, args ...interface{}) string {
+	for _, tag := range acceptedLanguages(acceptLanguage) {
+		candidates := []string{tag}
+		if i := strings.Index(tag, "-"); i > 0 {
+			candidates = append(candidates, tag[:i])
+		}
+
+		for _, lang := range candidates {
+			if msg, ok := c[lang][key]; ok {
+				return fmt.Sprintf(msg, args...)
+			}
+		}
+	}
+
+	if msg, ok := c[defaultLanguage][key]; ok {
+		return fmt.Sprintf(msg, args...)
+	}
+	return key
+}
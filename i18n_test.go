@@ -0,0 +1,66 @@
+package main
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAcceptedLanguages(t *testing.T) {
+	got := acceptedLanguages("fr;q=0.5, es-MX, *, EN;q=0.8")
+	if want := []string{"es-mx", "en", "fr"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestCatalogMessage(t *testing.T) {
+	for _, test := range []struct {
+		acceptLanguage, want string
+	}{
+		{"", `no title matches "alien"`},
+		{"es", `ningún título coincide con "alien"`},
+		{"es-MX", `ningún título coincide con "alien"`},
+		{"de, es;q=0.9", `ningún título coincide con "alien"`},
+		{"de", `no title matches "alien"`},
+	} {
+		if got := DefaultCatalog.Message(test.acceptLanguage, MsgNotFound, "alien"); got != test.want {
+			t.Errorf("%q: got %q, want %q", test.acceptLanguage, got, test.want)
+		}
+	}
+
+	if got := DefaultCatalog.Message("es", "unknown"); got != "unknown" {
+		t.Errorf("got %q for an unknown key, want the key", got)
+	}
+}
+
+func TestCatalogLanguages(t *testing.T) {
+	for lang, messages := range DefaultCatalog {
+		for key := range DefaultCatalog[defaultLanguage] {
+			if _, ok := messages[key]; !ok {
+				t.Errorf("%s is missing %s", lang, key)
+			}
+		}
+	}
+}
+
+func TestSearchLocalizedError(t *testing.T) {
+	omdb := newFakeOMDb(t, func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, "Incorrect IMDb ID.")
+	})
+	s := newTestApp(t, omdb, Config{})
+
+	req := newSearchRequest(`{"title":"tt0000000"}`)
+	req.Header.Set("Accept-Language", "es-ES,es;q=0.9")
+	w := serveRequest(s, req)
+	if w.Code != http.StatusNotFound || !strings.Contains(w.Body.String(), `ningún título coincide con "tt0000000"`) {
+		t.Errorf("got %d %q, want the Spanish not found message", w.Code, w.Body)
+	}
+
+	req = newSearchRequest(`{"title":"alien","type":"cartoon"}`)
+	req.Header.Set("Accept-Language", "es")
+	w = serveRequest(s, req)
+	if !strings.Contains(w.Body.String(), `tipo "cartoon" no válido`) {
+		t.Errorf("got %q, want the Spanish invalid type message", w.Body)
+	}
+}
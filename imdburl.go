@@ -0,0 +1,24 @@
+package main
+
+// imdbTitleURL is the prefix of the IMDb web page of a title.
+const imdbTitleURL = "https://www.imdb.com/title/"
+
+// IMDBURL returns the URL of the result's page on the IMDb website, or an
+// empty string if its IMDb ID is malformed.
+func (r *SearchResult) IMDBURL() string {
+	if !imdbIDPattern.MatchString(r.IMDBID) {
+		return ""
+	}
+	return imdbTitleURL + r.IMDBID + "/"
+}
+
+// withIMDBURLs returns copies of results with their URL set to their IMDBURL.
+func withIMDBURLs(results []*SearchResult) []*SearchResult {
+	linked := make([]*SearchResult, len(results))
+	for i, r := range results {
+		c := *r
+		c.URL = r.IMDBURL()
+		linked[i] = &c
+	}
+	return linked
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestIMDBURL(t *testing.T) {
+	for id, want := range map[string]string{
+		"tt0133093":  "https://www.imdb.com/title/tt0133093/",
+		"tt10838180": "https://www.imdb.com/title/tt10838180/",
+		"":           "",
+		"nm0000206":  "",
+		"tt":         "",
+		"tt123/../x": "",
+	} {
+		if got := (&SearchResult{IMDBID: id}).IMDBURL(); got != want {
+			t.Errorf("%q: got %q, want %q", id, got, want)
+		}
+	}
+}
+
+func TestSearchIMDBURLs(t *testing.T) {
+	omdb := newFakeOMDb(t, func(w http.ResponseWriter, r *http.Request) {
+		writeResults(w, 2, &SearchResult{Title: "The Matrix", IMDBID: "tt0133093"}, &SearchResult{Title: "Broken", IMDBID: "N/A"})
+	})
+
+	for _, enabled := range []bool{false, true} {
+		s := newTestApp(t, omdb, Config{IMDBURLs: enabled})
+		results := decodeResults(t, serveRequest(s, newSearchRequest(`{"title":"matrix"}`)))
+
+		want := ""
+		if enabled {
+			want = "https://www.imdb.com/title/tt0133093/"
+		}
+		if len(results) != 2 || results[0].URL != want || results[1].URL != "" {
+			t.Errorf("IMDb URLs %t: got %+v, want %q", enabled, results, want)
+		}
+	}
+}
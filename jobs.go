@@ -0,0 +1,310 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// maxConcurrentJobs is the number of search jobs that can run at once.
+	maxConcurrentJobs = 4
+
+	// maxJobResults is the most results a search job will crawl. It's the
+	// most OMDb will return for a single search.
+	maxJobResults = maxPage * PageSize
+
+	// defaultJobResults is the number of results crawled if the request
+	// doesn't say.
+	defaultJobResults = 100
+
+	// jobTimeout bounds how long a search job can run for.
+	jobTimeout = 10 * time.Minute
+
+	// jobTTL is how long a finished job is kept for before it's removed.
+	jobTTL = time.Hour
+)
+
+// The statuses of a search job.
+const (
+	JobRunning   = "running"
+	JobDone      = "done"
+	JobFailed    = "failed"
+	JobCancelled = "cancelled"
+)
+
+// JobRequest represents the variables accepted by /jobs/search.
+type JobRequest struct {
+	SearchRequest
+	MaxResults int `json:"max_results,omitempty"`
+}
+
+// Job is a search crawl running in the background.
+type Job struct {
+	ID      string          `json:"id"`
+	Status  string          `json:"status"`
+	Pages   int             `json:"pages"`
+	Count   int             `json:"count"`
+	Results []*SearchResult `json:"results"`
+	Error   string          `json:"error,omitempty"`
+
+	// Warning is why a job in best-effort mode only has the results of some
+	// of the pages, although it's done.
+	Warning string `json:"warning,omitempty"`
+
+	// Truncated is true if the job stopped at the max pages, before getting
+	// all of the results asked for.
+	Truncated bool `json:"truncated,omitempty"`
+
+	Created  time.Time  `json:"created"`
+	Finished *time.Time `json:"finished,omitempty"`
+
+	cancel context.CancelFunc
+}
+
+// JobManager keeps track of search jobs in memory. Finished jobs are removed
+// once they're older than jobTTL.
+type JobManager struct {
+	mu   sync.Mutex
+	jobs map[string]*Job
+}
+
+// NewJobManager returns a new *JobManager with no jobs.
+func NewJobManager() *JobManager {
+	return &JobManager{
+		jobs: make(map[string]*Job),
+	}
+}
+
+// newJobID returns a random job ID.
+func newJobID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// expire removes the finished jobs that are older than jobTTL and returns the
+// number of jobs that are still running. The caller must hold m.mu.
+func (m *JobManager) expire(now time.Time) int {
+	var running int
+	for id, j := range m.jobs {
+		if j.Finished != nil && now.Sub(*j.Finished) > jobTTL {
+			delete(m.jobs, id)
+		} else if j.Finished == nil {
+			running++
+		}
+	}
+	return running
+}
+
+// start creates a job for r and crawls its results in the background using
+// api, keeping the results of each page that filter returns. Once the crawl
+// stops, the results are put in the same stable order as SearchAll's. It
+// returns an error if too many jobs are already running.
+func (m *JobManager) start(api *OMDBAPI, r *SearchRequest, maxResults int, filter func([]*SearchResult) []*SearchResult) (*Job, error) {
+	id, err := newJobID()
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.expire(time.Now()) >= maxConcurrentJobs {
+		return nil, fmt.Errorf("at most %d jobs can run at once", maxConcurrentJobs)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), jobTimeout)
+	job := &Job{
+		ID:      id,
+		Status:  JobRunning,
+		Results: []*SearchResult{},
+		Created: time.Now(),
+		cancel:  cancel,
+	}
+	m.jobs[id] = job
+
+	go func() {
+		defer cancel()
+		truncated, err := api.searchStream(ctx, r, maxResults, func(results []*SearchResult) error {
+			results = filter(results)
+			m.mu.Lock()
+			job.Pages++
+			job.Count += len(results)
+			job.Results = append(job.Results, results...)
+			m.mu.Unlock()
+			return nil
+		})
+
+		m.mu.Lock()
+		job.Truncated = truncated
+		if len(job.Results) > 0 {
+			job.Results = stableResults(job.Results, api.collator)
+			job.Count = len(job.Results)
+		}
+		if err != nil {
+			if partial := api.partialError(ctx, job.Pages, err); partial != nil {
+				job.Warning = partial.Error()
+				err = nil
+			}
+		}
+		m.mu.Unlock()
+		m.finish(job, err)
+	}()
+
+	return job, nil
+}
+
+// finish records that job has stopped running because of err, which is nil
+// if it completed.
+func (m *JobManager) finish(job *Job, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if job.Finished != nil {
+		return
+	}
+
+	now := time.Now()
+	job.Finished = &now
+	switch {
+	case err == nil:
+		job.Status = JobDone
+	case job.Status == JobCancelled:
+	default:
+		job.Status = JobFailed
+		job.Error = errorMessage(err)
+	}
+}
+
+// get returns the job with the given ID as JSON, or false if there isn't one.
+func (m *JobManager) get(id string) ([]byte, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.expire(time.Now())
+	job, ok := m.jobs[id]
+	if !ok {
+		return nil, false, nil
+	}
+
+	b, err := json.Marshal(job)
+	return b, true, err
+}
+
+// cancel stops the job with the given ID if it's running. It returns false if
+// there isn't a job with that ID.
+func (m *JobManager) cancel(id string) bool {
+	m.mu.Lock()
+	job, ok := m.jobs[id]
+	if ok && job.Finished == nil {
+		job.Status = JobCancelled
+	}
+	m.mu.Unlock()
+
+	if ok {
+		job.cancel()
+	}
+	return ok
+}
+
+// StartJob handles requests to /jobs/search, starting a background crawl of
+// the results for a search and returning the ID of its job.
+func (s *SearchApp) StartJob(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	if r.Method != "POST" {
+		http.NotFound(w, r)
+		return
+	}
+
+	b, ok := s.readJSONBody(w, r)
+	if !ok {
+		return
+	}
+
+	var jobRequest *JobRequest
+	if err := json.Unmarshal(b, &jobRequest); err != nil || jobRequest == nil {
+		s.invalidJSON(w, err, jobExample)
+		return
+	}
+
+	searchRequest := &jobRequest.SearchRequest
+	s.applyMiddleware(r, searchRequest)
+
+	if err := s.validate(searchRequest); err != nil {
+		s.searchError(w, r, searchRequest, err)
+		return
+	}
+
+	if !s.typeAllowed(searchRequest.Type) {
+		s.searchError(w, r, searchRequest, fmt.Errorf("%w: %q", ErrTypeNotAllowed, searchRequest.Type))
+		return
+	}
+
+	maxResults := jobRequest.MaxResults
+	if maxResults == 0 {
+		maxResults = defaultJobResults
+	}
+	if maxResults < 0 || maxResults > maxJobResults {
+		msg := fmt.Sprintf("max_results must be between 1 and %d", maxJobResults)
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	job, err := s.jobs.start(s.omdb, searchRequest, maxResults, func(results []*SearchResult) []*SearchResult {
+		return s.filterSearch(searchRequest, results)
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+		return
+	}
+
+	jsonstr, err := json.Marshal(map[string]string{"id": job.ID})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Location", s.basePath+"/jobs/"+job.ID)
+	w.WriteHeader(http.StatusAccepted)
+	w.Write(jsonstr)
+}
+
+// Job handles requests to /jobs/{id}. GET returns the job's progress and the
+// results so far, and DELETE cancels it.
+func (s *SearchApp) Job(w http.ResponseWriter, r *http.Request) {
+	id := strings.TrimPrefix(r.URL.Path, "/jobs/")
+
+	switch r.Method {
+	case "GET":
+		jsonstr, ok, err := s.jobs.get(id)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(jsonstr)
+	case "DELETE":
+		if !s.jobs.cancel(id) {
+			http.NotFound(w, r)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.Header().Set("Allow", "GET, DELETE")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+)
+
+// The landing modes control what is served for requests to /.
+const (
+	// LandingGreeting responds with a plain text greeting for any path that
+	// isn't handled elsewhere. It's the default, and what the liveness and
+	// readiness probes expect.
+	LandingGreeting = "greeting"
+
+	// LandingIndex serves search.html from the site directory, or the
+	// bundled theme, for /, and the rest of its files for other paths.
+	LandingIndex = "index"
+
+	// LandingRedirect redirects / to the landing URL.
+	LandingRedirect = "redirect"
+
+	// LandingJSON responds to / with a JSON description of the service.
+	LandingJSON = "json"
+)
+
+// Welcome is the JSON document returned for / in the LandingJSON mode.
+type Welcome struct {
+	Service   string   `json:"service"`
+	Endpoints []string `json:"endpoints"`
+}
+
+// SetLanding configures what Home serves. The site directory is only used by
+// LandingIndex and the URL is only used by LandingRedirect.
+func (s *SearchApp) SetLanding(mode, siteDir, landingURL string) error {
+	switch mode {
+	case LandingGreeting, LandingJSON:
+	case LandingIndex:
+		if siteDir == "" {
+			return fmt.Errorf("the %s landing mode requires a site directory", mode)
+		}
+	case LandingRedirect:
+		if landingURL == "" {
+			return fmt.Errorf("the %s landing mode requires a URL", mode)
+		}
+	default:
+		return fmt.Errorf("unsupported landing mode %q", mode)
+	}
+
+	s.landing = mode
+	s.siteDir = siteDir
+	s.site = os.DirFS(siteDir)
+	s.landingURL = landingURL
+	return nil
+}
+
+// fallbackPage is served for / in the LandingIndex mode when the site
+// directory is missing.
+const fallbackPage = `<!DOCTYPE html>
+<html>
+<head><title>omdb-example</title></head>
+<body>
+<h1>omdb-example</h1>
+<p>The search page isn't installed on this server. The API is available at /search.</p>
+</body>
+</html>
+`
+
+// CheckSiteDir checks that the site directory exists when it will be served
+// from. If it doesn't, an error is returned if failFast is true, otherwise a
+// warning is logged and a minimal built-in page is served in its place.
+func (s *SearchApp) CheckSiteDir(failFast bool) error {
+	if s.landing != LandingIndex || s.theme != "" {
+		return nil
+	}
+
+	info, err := os.Stat(s.siteDir)
+	if err == nil && info.IsDir() {
+		s.siteMissing = false
+		return nil
+	}
+
+	if failFast {
+		return fmt.Errorf("site directory %s is missing", s.siteDir)
+	}
+
+	log.Printf("site directory %s is missing, serving a minimal page for / instead", s.siteDir)
+	s.siteMissing = true
+	return nil
+}
+
+// Home handles requests to /, and any other path that isn't handled elsewhere.
+func (s *SearchApp) Home(w http.ResponseWriter, r *http.Request) {
+	if s.landing == LandingIndex && s.siteMissing {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, fallbackPage)
+		return
+	}
+
+	if s.landing == LandingIndex {
+		if r.URL.Path == "/" {
+			http.ServeFileFS(w, r, s.site, "search.html")
+			return
+		}
+		if !s.staticAllowed(r.URL.Path) || !s.staticExists(r.URL.Path) {
+			s.notFound(w, r)
+			return
+		}
+		http.FileServerFS(s.site).ServeHTTP(w, r)
+		return
+	}
+
+	if s.landing != LandingGreeting && r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+
+	switch s.landing {
+	case LandingRedirect:
+		http.Redirect(w, r, s.landingURL, http.StatusFound)
+	case LandingJSON:
+		endpoints := []string{"/search"}
+		if s.enabled(FeatureMerge) {
+			endpoints = append(endpoints, "/search/merge")
+		}
+		if s.enabled(FeatureDetailed) {
+			endpoints = append(endpoints, "/search/detailed")
+		}
+
+		jsonstr, err := json.Marshal(&Welcome{
+			Service:   "omdb-example",
+			Endpoints: endpoints,
+		})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(jsonstr)
+	default:
+		fmt.Fprintf(w, "Hello from the omdb-example service.")
+	}
+}
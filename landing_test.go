@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// newSiteDir returns a temporary site directory with files, keyed by their
+// paths within it.
+func newSiteDir(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestLandingGreeting(t *testing.T) {
+	s := newTestApp(t, newFakeOMDb(t, pagedResults(0)), Config{})
+
+	for _, target := range []string{"/", "/anything"} {
+		w := send(s, "GET", target, "")
+		if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "Hello") {
+			t.Errorf("%s: got %d %q, want the greeting", target, w.Code, w.Body)
+		}
+	}
+}
+
+func TestLandingJSON(t *testing.T) {
+	s := newTestApp(t, newFakeOMDb(t, pagedResults(0)), Config{
+		Landing:          LandingJSON,
+		DisabledFeatures: []string{FeatureMerge},
+	})
+
+	w := send(s, "GET", "/", "")
+	if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("got Content-Type %q, want application/json", ct)
+	}
+	var welcome Welcome
+	if err := json.Unmarshal(w.Body.Bytes(), &welcome); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"/search", "/search/detailed"}; !reflect.DeepEqual(welcome.Endpoints, want) {
+		t.Errorf("got endpoints %v, want %v", welcome.Endpoints, want)
+	}
+
+	if w := send(s, "GET", "/anything", ""); w.Code != http.StatusNotFound {
+		t.Errorf("got status %d for another path, want 404", w.Code)
+	}
+}
+
+func TestLandingRedirect(t *testing.T) {
+	s := newTestApp(t, newFakeOMDb(t, pagedResults(0)), Config{
+		Landing:    LandingRedirect,
+		LandingURL: "https://example.com/search",
+	})
+
+	w := send(s, "GET", "/", "")
+	if w.Code != http.StatusFound || w.Header().Get("Location") != "https://example.com/search" {
+		t.Errorf("got %d to %q, want a redirect to the landing URL", w.Code, w.Header().Get("Location"))
+	}
+}
+
+func TestLandingIndex(t *testing.T) {
+	dir := newSiteDir(t, map[string]string{
+		"search.html": "<h1>search</h1>",
+		"app.js":      "search()",
+	})
+	s := newTestApp(t, newFakeOMDb(t, pagedResults(0)), Config{Landing: LandingIndex, SiteDir: dir})
+
+	for target, want := range map[string]string{
+		"/":       "<h1>search</h1>",
+		"/app.js": "search()",
+	} {
+		w := send(s, "GET", target, "")
+		if w.Code != http.StatusOK || w.Body.String() != want {
+			t.Errorf("%s: got %d %q, want %q", target, w.Code, w.Body, want)
+		}
+	}
+}
+
+func TestLandingConfig(t *testing.T) {
+	for _, cfg := range []Config{
+		{Landing: "nope"},
+		{Landing: LandingRedirect},
+	} {
+		cfg.Key = testKey
+		if _, err := NewSearchAppWithConfig(cfg); err == nil {
+			t.Errorf("%+v: got no error", cfg)
+		}
+	}
+}
+
+func TestSiteDirMissing(t *testing.T) {
+	buf := captureLog(t)
+	dir := filepath.Join(t.TempDir(), "site")
+	s := newTestApp(t, newFakeOMDb(t, pagedResults(0)), Config{Landing: LandingIndex, SiteDir: dir})
+
+	if !strings.Contains(buf.String(), "is missing") {
+		t.Errorf("got log %q, want a warning", buf)
+	}
+
+	w := send(s, "GET", "/", "")
+	if w.Code != http.StatusOK || w.Body.String() != fallbackPage {
+		t.Errorf("got %d %q, want the fallback page", w.Code, w.Body)
+	}
+	if w := send(s, "GET", "/app.js", ""); w.Code != http.StatusNotFound {
+		t.Errorf("got status %d for a static file, want 404", w.Code)
+	}
+	if w := serveRequest(s, newSearchRequest(`{"title":"alien"}`)); w.Code != http.StatusOK {
+		t.Errorf("got status %d for a search, want 200", w.Code)
+	}
+}
+
+func TestSiteDirRequired(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "site")
+	cfg := Config{Key: testKey, Landing: LandingIndex, SiteDir: dir, RequireSite: true}
+	if _, err := NewSearchAppWithConfig(cfg); err == nil {
+		t.Error("got no error for a missing site directory")
+	}
+
+	// It's only required when it's served.
+	cfg.Landing = LandingGreeting
+	if _, err := NewSearchAppWithConfig(cfg); err != nil {
+		t.Errorf("got error %v for the greeting", err)
+	}
+}
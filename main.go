@@ -1,15 +1,29 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io/fs"
 	"io/ioutil"
 	"log"
+	"math"
+	"mime"
 	"net/http"
 	"net/url"
 	"os"
+	"regexp"
+	"strconv"
 	"strings"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
 )
 
 // SearchRequest represents the variables that are passed to the OMDb API.
@@ -17,7 +31,47 @@ type SearchRequest struct {
 	Title       string `json:"title"` // This is the only required field for the API.
 	Type        string `json:"type,omitempty"`
 	ReleaseYear string `json:"release_year,omitempty"`
+	Page        int    `json:"page,omitempty"` // Zero requests the first page.
 	APIVersion  string `json:"api_verison"`
+
+	// Score and SortByScore aren't sent to OMDb. Score attaches a local
+	// relevance score to each result, SortByScore also sorts by it.
+	Score       bool `json:"score,omitempty"`
+	SortByScore bool `json:"sort_by_score,omitempty"`
+
+	// RequirePoster isn't sent to OMDb. It filters out results without a
+	// poster image.
+	RequirePoster bool `json:"require_poster,omitempty"`
+
+	// ExactYear and YearRanges aren't sent to OMDb. ExactYear filters out
+	// results that weren't released in ReleaseYear, which OMDb sometimes
+	// includes for series. YearRanges also keeps series whose run includes
+	// ReleaseYear. When Type is set too, the results are always filtered
+	// by both, as described by typeYearFilter, and these are ignored.
+	ExactYear  bool `json:"exact_year,omitempty"`
+	YearRanges bool `json:"year_ranges,omitempty"`
+
+	// MinYear isn't sent to OMDb. It filters out results released before
+	// it, though series that were still running in it are kept. It can't be
+	// later than ReleaseYear.
+	MinYear int `json:"min_year,omitempty"`
+
+	// IDPattern isn't sent to OMDb. It's a regular expression that filters
+	// out results whose IMDb ID doesn't match it.
+	IDPattern string `json:"id_pattern,omitempty"`
+
+	// SortByRating isn't sent to OMDb. It looks up the IMDb rating of the top
+	// results, which costs an extra upstream call for each, and sorts by it.
+	SortByRating bool `json:"sort_by_rating,omitempty"`
+
+	// PosterWidth isn't sent to OMDb. It rewrites the poster URLs of the
+	// results to ask for images scaled to that many pixels wide, e.g. for
+	// thumbnails. Zero leaves them unchanged.
+	PosterWidth int `json:"poster_width,omitempty"`
+
+	// Cursor is the X-Next-Cursor header of a previous response. It replaces
+	// the title, type, release year and page.
+	Cursor string `json:"cursor,omitempty"`
 }
 
 // SearchResult represents the variables that are returned by the OMDb API.
@@ -26,14 +80,108 @@ type SearchResult struct {
 	Year   string
 	IMDBID string
 	Type   string
+	Poster string
+
+	// Score is the local relevance of the result to the search title. It's
+	// only set when requested.
+	Score float64 `json:",omitempty"`
+
+	// IMDBRating is only set when sorting by rating.
+	IMDBRating string `json:",omitempty"`
+
+	// RawTitle is the title as OMDb returned it. It's only set when titles
+	// are normalized to title case.
+	RawTitle string `json:",omitempty"`
+
+	// URL is the result's page on the IMDb website. It's only set when IMDb
+	// URLs are included in responses.
+	URL string `json:",omitempty"`
+}
+
+// Rating is a single rating from one of the sources aggregated by OMDb.
+type Rating struct {
+	Source string
+	Value  string
+}
+
+// Detail represents the full record returned by the OMDb API for a single
+// title.
+type Detail struct {
+	Title      string
+	Year       string
+	Rated      string
+	Released   string
+	Runtime    string
+	Genre      string
+	Director   string
+	Writer     string
+	Actors     string
+	Plot       string
+	Language   string
+	Country    string
+	Awards     string
+	Poster     string
+	Ratings    []*Rating
+	Metascore  string
+	IMDBRating string
+	IMDBVotes  string
+	IMDBID     string
+	Type       string
+	DVD        string
+	BoxOffice  string
+	Production string
+	Website    string
+	Response   string
+	Error      string
 }
 
+// SearchResult returns the subset of the Detail that is included in a
+// *SearchResult.
+func (d *Detail) SearchResult() *SearchResult {
+	return &SearchResult{
+		Title:  d.Title,
+		Year:   d.Year,
+		IMDBID: d.IMDBID,
+		Type:   d.Type,
+		Poster: d.Poster,
+	}
+}
+
+// yearPattern matches the release years accepted by OMDb.
+var yearPattern = regexp.MustCompile(`^\d{4}$`)
+
+// imdbIDPattern matches IMDb title IDs, e.g. tt0133093.
+var imdbIDPattern = regexp.MustCompile(`^tt\d+$`)
+
 // SearchWrapper is the outer-wrapper around the search results returned by
 // the API.
 type SearchWrapper struct {
-	Search []*SearchResult
+	Search       []*SearchResult
+	TotalResults string
+	Response     string
+	Error        string
+
+	// Raw is the full response body when Response is "False", as OMDb can
+	// include more detail than the Error message.
+	Raw json.RawMessage `json:"-"`
+}
+
+// UnmarshalJSON unmarshals b into w, keeping b as w.Raw if it's an error.
+func (w *SearchWrapper) UnmarshalJSON(b []byte) error {
+	type searchWrapper SearchWrapper
+	if err := json.Unmarshal(b, (*searchWrapper)(w)); err != nil {
+		return err
+	}
+
+	if w.Response == "False" {
+		w.Raw = append(json.RawMessage(nil), b...)
+	}
+	return nil
 }
 
+// maxPage is the highest page of search results that OMDb will return.
+const maxPage = 100
+
 // NewSearchRequest returns a *SearchRequest populated with default values for
 // the OMDb API request.
 func NewSearchRequest(title string) *SearchRequest {
@@ -45,19 +193,62 @@ func NewSearchRequest(title string) *SearchRequest {
 
 // API is the interface for making requests against a remote api.
 type API interface {
-	Init(key string) API
-	Search(*SearchRequest) ([]*SearchResult, error)
+	Search(context.Context, *SearchRequest) ([]*SearchResult, error)
+	GetByID(context.Context, string) (*Detail, error)
 }
 
 // OMDBAPI is a concrete implementation of the API interface that interacts with
 // the Open Movie Database, located at https://www.omdbapi.com.
 type OMDBAPI struct {
-	url *url.URL
+	url     *url.URL
+	started time.Time
+	debug   bool
+	backoff *Backoff
+	quota   *QuotaTracker
+	latency *Histogram
+	shedder *LoadShedder
+
+	// collator orders the results merged from several pages, or is nil for
+	// DefaultCollator.
+	collator Collator
+
+	// client is the client that requests are sent with, or nil for the
+	// default client.
+	client *http.Client
+
+	// maxPages caps the pages requested for a single search by SearchStream.
+	// It's uncapped if zero.
+	maxPages int
+
+	// pageFailures is what SearchAll does when a page fails after earlier
+	// ones succeeded. It fails fast if empty.
+	pageFailures string
+
+	// bodyLogLimit is the number of bytes of each request and response body
+	// that's logged, or zero if they aren't logged.
+	bodyLogLimit int
+
+	// lastSuccess is the time of the last successful call in Unix
+	// nanoseconds, or zero if there hasn't been one. It's accessed atomically.
+	lastSuccess int64
+
+	// lastFailure is the time of the last failed call in Unix nanoseconds, or
+	// zero if there hasn't been one. It's accessed atomically.
+	lastFailure int64
 }
 
+// defaultBaseURL is the URL of the OMDb API.
+const defaultBaseURL = "http://www.omdbapi.com/?"
+
 // Init will return a newly instantiated OMDBAPI instance.
 func Init(key string) (*OMDBAPI, error) {
-	u, err := url.Parse("http://www.omdbapi.com/?")
+	return InitWithURL(defaultBaseURL, key)
+}
+
+// InitWithURL will return a newly instantiated OMDBAPI instance that sends
+// requests to baseURL, e.g. a mirror of the OMDb API.
+func InitWithURL(baseURL, key string) (*OMDBAPI, error) {
+	u, err := url.Parse(baseURL)
 	if err != nil {
 		return nil, err
 	}
@@ -67,7 +258,10 @@ func Init(key string) (*OMDBAPI, error) {
 	u.RawQuery = v.Encode()
 
 	return &OMDBAPI{
-		url: u,
+		url:     u,
+		started: time.Now(),
+		quota:   NewQuotaTracker(defaultDailyQuota, 0),
+		latency: NewHistogram(upstreamLatencyBuckets),
 	}, nil
 }
 
@@ -87,31 +281,257 @@ func (o *OMDBAPI) searchURL(r *SearchRequest) *url.URL {
 		v.Set("y", r.ReleaseYear)
 	}
 
+	if r.Page > 0 {
+		v.Set("page", strconv.Itoa(r.Page))
+	}
+
 	n.RawQuery = v.Encode()
 	return &n
 }
 
-// Search calls the OMDBAPI and returns a *SearchResult.
-func (o *OMDBAPI) Search(r *SearchRequest) ([]*SearchResult, error) {
-	searchURL := o.searchURL(r)
-	resp, err := http.Get(searchURL.String())
+// redact returns s with the API key, and the one in ctx, if they appear,
+// replaced by asterisks.
+func (o *OMDBAPI) redact(ctx context.Context, s string) string {
+	if key := o.url.Query().Get("apikey"); key != "" {
+		s = strings.Replace(s, key, "***", -1)
+	}
+	if key, ok := apiKeyFrom(ctx); ok {
+		s = strings.Replace(s, key, "***", -1)
+	}
+	return s
+}
+
+// idURL returns a *url.URL that looks up a single title by its IMDb ID.
+func (o *OMDBAPI) idURL(id string) *url.URL {
+	n := *o.url
+	v := n.Query()
+
+	v.Set("i", id)
+
+	n.RawQuery = v.Encode()
+	return &n
+}
+
+// redactURL returns a copy of u with the value of the apikey query parameter
+// replaced with ***, so that it's safe to log.
+func redactURL(u *url.URL) *url.URL {
+	n := *u
+	v := n.Query()
+	if _, ok := v["apikey"]; !ok {
+		return &n
+	}
+
+	// Encode would escape the asterisks, so the redacted parameter is added
+	// to the already encoded query. It sorts first, as Encode would put it.
+	v.Del("apikey")
+	n.RawQuery = "apikey=***"
+	if rest := v.Encode(); rest != "" {
+		n.RawQuery += "&" + rest
+	}
+	return &n
+}
+
+// RedactedSearchURL returns the URL that Search requests for r, with the API
+// key redacted.
+func (o *OMDBAPI) RedactedSearchURL(r *SearchRequest) *url.URL {
+	return redactURL(o.searchURL(r))
+}
+
+// get requests u and unmarshals the JSON response body into v. Transient
+// failures are retried according to the OMDBAPI's backoff, if it has one. The
+// request is cancelled if ctx is done before it completes.
+func (o *OMDBAPI) get(ctx context.Context, u *url.URL, v interface{}) error {
+	err := o.getOnce(ctx, u, v)
+	for attempt := 0; err != nil && attempt < o.backoff.retries() && retryable(err) && o.backoff.allowRetry(); attempt++ {
+		if serr := sleep(ctx, o.backoff.Delay(attempt)); serr != nil {
+			return err
+		}
+		err = o.getOnce(ctx, u, v)
+	}
+	return err
+}
+
+// getOnce makes a single request for u and unmarshals the JSON response body
+// into v. The request uses the API key in ctx, if there is one, in place of
+// the OMDBAPI's own.
+func (o *OMDBAPI) getOnce(ctx context.Context, u *url.URL, v interface{}) (err error) {
+	u = withRequestKey(ctx, u)
+	req, err := http.NewRequest("GET", u.String(), nil)
 	if err != nil {
-		return nil, err
+		return err
+	}
+
+	ctx, span := startSpan(ctx, "omdb.get")
+	defer func() { endSpan(span, err) }()
+	span.SetAttributes(attribute.String("http.url", redactURL(u).String()))
+	injectTraceParent(ctx, req.Header)
+
+	if o.debug {
+		log.Printf("omdb request: GET %s", redactURL(u))
+	}
+
+	// Calls made with a client's key don't use up this instance's quota.
+	if _, ok := apiKeyFrom(ctx); !ok {
+		o.quota.Record()
+	}
+	start := time.Now()
+	defer func() {
+		recordUpstream(ctx, start)
+		o.latency.Observe(time.Since(start))
+		o.shedder.Record(err, time.Since(start))
+		// A search the client gave up on isn't a failure of OMDb.
+		if err != nil && !errors.Is(ctx.Err(), context.Canceled) {
+			atomic.StoreInt64(&o.lastFailure, time.Now().UnixNano())
+		}
+	}()
+
+	resp, err := o.httpClient().Do(req.WithContext(ctx))
+	if err != nil {
+		return err
 	}
+	defer resp.Body.Close()
+	span.SetAttributes(attribute.Int("http.status_code", resp.StatusCode))
 
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return nil, err
+		if connectionDropped(err) {
+			return &APIError{
+				StatusCode: resp.StatusCode,
+				URL:        redactURL(u).String(),
+				Err:        fmt.Errorf("%w: %s", ErrConnectionDropped, err),
+			}
+		}
+		return err
+	}
+	body = trimBody(body)
+	if o.bodyLogLimit > 0 {
+		log.Printf("omdb response: %d %s: %s", resp.StatusCode, redactURL(u), o.loggedBody(ctx, body))
+	}
+
+	// OMDb reports an exhausted quota or a bad key with a 401, so the body has
+	// to be checked before the status code.
+	// An error body from a proxy may not be JSON, which leaves errResp empty.
+	var errResp errorResponse
+	json.Unmarshal(body, &errResp)
+	apiErr := &APIError{
+		StatusCode:   resp.StatusCode,
+		OMDbResponse: errResp.Response,
+		OMDbError:    errResp.Error,
+		URL:          redactURL(u).String(),
+		Body:         o.redact(ctx, string(body)),
+	}
+
+	if errResp.Response == "False" {
+		switch errResp.Error {
+		case quotaExceededMessage:
+			apiErr.Err = ErrQuotaExceeded
+			return apiErr
+		case invalidAPIKeyMessage, noAPIKeyMessage:
+			apiErr.Err = ErrInvalidAPIKey
+			return apiErr
+		}
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		apiErr.Err = &StatusError{StatusCode: resp.StatusCode}
+		return apiErr
 	}
 
+	if err = json.Unmarshal(body, v); err != nil {
+		return err
+	}
+
+	atomic.StoreInt64(&o.lastSuccess, time.Now().UnixNano())
+	return nil
+}
+
+// trimBody returns body without any leading UTF-8 byte order marks or
+// whitespace, which some proxies add in front of the JSON from OMDb.
+func trimBody(body []byte) []byte {
+	return bytes.TrimLeft(body, "\ufeff \t\r\n")
+}
+
+// searchPage calls the OMDBAPI and returns the *SearchWrapper for a single
+// page of results.
+func (o *OMDBAPI) searchPage(ctx context.Context, r *SearchRequest) (*SearchWrapper, error) {
 	var result *SearchWrapper
-	if err = json.Unmarshal(body, &result); err != nil {
+	if err := o.get(ctx, o.searchURL(r), &result); err != nil {
+		return nil, err
+	}
+
+	// A search that matches nothing is reported as an error by OMDb, but
+	// isn't one here.
+	if result.Response == "False" {
+		switch result.Error {
+		case movieNotFoundMessage, "":
+		case tooManyResultsMessage:
+			return nil, ErrTooManyResults
+		default:
+			return nil, &APIError{
+				StatusCode:   http.StatusOK,
+				OMDbResponse: result.Response,
+				OMDbError:    result.Error,
+				URL:          o.RedactedSearchURL(r).String(),
+				Body:         o.redact(ctx, string(result.Raw)),
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// Search calls the OMDBAPI and returns a *SearchResult.
+func (o *OMDBAPI) Search(ctx context.Context, r *SearchRequest) ([]*SearchResult, error) {
+	result, err := o.searchPage(ctx, r)
+	if err != nil {
 		return nil, err
 	}
 
 	return result.Search, nil
 }
 
+// SearchDebug is Search, but also returns the URL that was requested, with the
+// API key redacted, so that library users can log exactly what was asked of
+// OMDb. The URL is returned even if the search fails.
+func (o *OMDBAPI) SearchDebug(ctx context.Context, r *SearchRequest) ([]*SearchResult, *url.URL, error) {
+	u := redactURL(withRequestKey(ctx, o.searchURL(r)))
+	results, err := o.Search(ctx, r)
+	return results, u, err
+}
+
+// SearchByID calls the OMDBAPI and returns the results keyed by their IMDb ID.
+// If OMDb returns the same ID more than once, the first result for it wins.
+// As with any map, iterating over the results has no defined order.
+func (o *OMDBAPI) SearchByID(ctx context.Context, r *SearchRequest) (map[string]*SearchResult, error) {
+	results, err := o.Search(ctx, r)
+	if err != nil {
+		return nil, err
+	}
+
+	byID := make(map[string]*SearchResult, len(results))
+	for _, result := range results {
+		if _, ok := byID[result.IMDBID]; !ok {
+			byID[result.IMDBID] = result
+		}
+	}
+	return byID, nil
+}
+
+// GetByID calls the OMDBAPI and returns the *Detail for the title with the
+// given IMDb ID.
+func (o *OMDBAPI) GetByID(ctx context.Context, id string) (*Detail, error) {
+	var detail *Detail
+	if err := o.get(ctx, o.idURL(id), &detail); err != nil {
+		return nil, err
+	}
+
+	if detail.Response == "False" {
+		return nil, fmt.Errorf("%w: %s", ErrMovieNotFound, id)
+	}
+
+	return detail, nil
+}
+
 // App interface defines the base functionality that a type must support to be
 // considered an App.
 type App interface {
@@ -122,68 +542,490 @@ type App interface {
 // SearchApp implements the App interface for sending handling requests from
 // the frontend.
 type SearchApp struct {
-	searchAPI *OMDBAPI
-	mux       *http.ServeMux
+	omdb         *OMDBAPI
+	searchAPI    API
+	mux          *http.ServeMux
+	notifier     *ErrorNotifier
+	recentErrors *ErrorLog
+	history      HistoryStore
+	historyToken string
+	limiter      *ClientLimiter
+	tracer       trace.Tracer
+	cache        *SearchCache
+	cacheKeys    string
+	details      *DetailCache
+	flights      flightGroup
+	jobs         *JobManager
+	jsonCase     string
+
+	formatter  ResultFormatter
+	formatters map[string]ResultFormatter
+
+	middleware     []RequestMiddleware
+	validators     []Validator
+	allowedTypes   map[string]bool
+	allowedOrigins map[string]bool
+	maxTitleLength int
+	minTitleLength int
+	messages       Catalog
+	readyWindow    time.Duration
+	bodyTimeout    time.Duration
+
+	noContentOnEmpty bool
+	quotaHeader      bool
+	upstreamErrors   bool
+	latencyQuantiles bool
+	titleCase        bool
+	clientKeys       bool
+	imdbURLs         bool
+	docsURL          string
+	cursorSecret     []byte
+	duplicateParams  string
+	disabled         map[string]bool
+
+	landing          string
+	siteDir          string
+	site             fs.FS
+	theme            string
+	siteMissing      bool
+	staticExtensions map[string]bool
+	notFoundPage     string
+	posterHosts      map[string]bool
+	landingURL       string
+	basePath         string
+
+	// inFlight is the number of /search requests being handled. It's
+	// accessed atomically.
+	inFlight int64
 }
 
-// NewSearchApp returns a new *SearchApp.
+// NewSearchApp returns a new *SearchApp that uses key, and the defaults for
+// everything else.
 func NewSearchApp(key string) (*SearchApp, error) {
-	api, err := Init(key)
+	return NewSearchAppWithConfig(Config{Key: key})
+}
+
+// NewSearchAppWithConfig returns a new *SearchApp configured by cfg. Zero
+// values in cfg are replaced with their defaults.
+func NewSearchAppWithConfig(cfg Config) (*SearchApp, error) {
+	cfg = cfg.withDefaults()
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+
+	api, err := InitWithURL(cfg.BaseURL, cfg.Key)
 	if err != nil {
 		return nil, err
 	}
 
 	m := http.NewServeMux()
 	s := &SearchApp{
+		omdb:      api,
 		searchAPI: api,
 		mux:       m,
+		details:   NewDetailCache(),
+		jobs:      NewJobManager(),
+
+		formatters: map[string]ResultFormatter{
+			"csv": CSVFormatter{},
+		},
+		messages: DefaultCatalog,
+		tracer:   noop.NewTracerProvider().Tracer(tracerName),
+
+		cursorSecret: []byte(cfg.CursorSecret),
+	}
+	if len(s.cursorSecret) == 0 {
+		s.cursorSecret = newCursorSecret()
+	}
+	s.validators = []Validator{
+		ValidateTitle,
+		s.validateTitleLength,
+		s.validateMinTitleLength,
+		ValidateType,
+		ValidateYear,
+		ValidatePage,
+		ValidateIDPattern,
+		ValidatePosterWidth,
+		ValidateMinYear,
+	}
+
+	if err = s.registerRoutes(cfg.DisabledFeatures); err != nil {
+		return nil, err
 	}
-	s.mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		fmt.Fprintf(w, "Hello from the omdb-example service.")
-	})
-	s.mux.HandleFunc("/search", s.Search)
-	return s, nil
-}
 
-// Home handles requests to /.
-func (s *SearchApp) Home(w http.ResponseWriter, r *http.Request) {
-	http.ServeFile(w, r, "search.html")
+	if err = s.apply(cfg); err != nil {
+		return nil, err
+	}
+	return s, nil
 }
 
-// Search handles requests to /search
+// Search handles requests to /search. An OPTIONS request gets a description
+// of the accepted parameters.
 func (s *SearchApp) Search(w http.ResponseWriter, r *http.Request) {
 	defer r.Body.Close()
+
+	ctx, upstream := withUpstreamTiming(r.Context())
+	tw := newTimingWriter(w, upstream)
+	w = tw
+
+	ctx, span := s.startRequestSpan(ctx, r, "search")
+	defer func() {
+		span.SetAttributes(attribute.Int("http.status_code", tw.Status()))
+		span.End()
+	}()
+
+	if r.Method == "OPTIONS" {
+		s.describeSearch(w)
+		return
+	}
+
 	if r.Method != "POST" {
 		http.NotFound(w, r)
 		return
 	}
 
-	b, err := ioutil.ReadAll(r.Body)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	b, ok := s.readJSONBody(w, r)
+	if !ok {
 		return
 	}
 
 	var searchRequest *SearchRequest
-	if err = json.Unmarshal(b, &searchRequest); err != nil {
-		log.Println(err)
-		http.Error(w, err.Error(), http.StatusBadRequest)
+	if err := json.Unmarshal(b, &searchRequest); err != nil || searchRequest == nil {
+		s.invalidJSON(w, err, searchExample)
 		return
 	}
 
-	results, err := s.searchAPI.Search(searchRequest)
+	cursor, err := s.applyCursor(searchRequest)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		s.searchError(w, r, searchRequest, err)
 		return
 	}
 
-	jsonstr, err := json.Marshal(results)
+	s.applyMiddleware(r, searchRequest)
+	span.SetAttributes(attribute.String("omdb.query", searchRequest.Title))
+
+	if err := s.validate(searchRequest); err != nil {
+		s.searchError(w, r, searchRequest, err)
+		return
+	}
+
+	if !s.typeAllowed(searchRequest.Type) {
+		s.searchError(w, r, searchRequest, fmt.Errorf("%w: %q", ErrTypeNotAllowed, searchRequest.Type))
+		return
+	}
+
+	results, err := s.search(ctx, searchRequest)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		s.searchError(w, r, searchRequest, err)
+		return
+	}
+	defer func() { span.SetAttributes(attribute.Int("omdb.result_count", len(results))) }()
+	s.setNextCursor(w, searchRequest, cursor, results)
+
+	results = s.filterSearch(searchRequest, results)
+
+	if searchRequest.SortByRating {
+		results = s.sortByRating(ctx, results)
+	}
+
+	if searchRequest.Score || searchRequest.SortByScore {
+		results = scoreResults(searchRequest.Title, results, searchRequest.SortByScore)
+	}
+
+	if s.titleCase {
+		results = titleCaseResults(results)
+	}
+
+	if s.imdbURLs {
+		results = withIMDBURLs(results)
+	}
+
+	if searchRequest.PosterWidth > 0 {
+		results = resizePosters(results, searchRequest.PosterWidth)
+	}
+
+	if s.quotaHeader {
+		w.Header().Set("X-Quota-Remaining", strconv.Itoa(s.omdb.RemainingQuota()))
+	}
+
+	// A search that matches nothing isn't an error, errors get a 4xx or 5xx.
+	// Some clients prefer a 204 to an empty array for it.
+	if len(results) == 0 && s.noContentOnEmpty {
+		s.recordSearch(searchRequest, results)
+		w.WriteHeader(http.StatusNoContent)
 		return
 	}
 
-	w.Write(jsonstr)
+	s.recordSearch(searchRequest, results)
+
+	if err = s.formatterFor(r).Format(w, results); err != nil {
+		log.Println(err)
+	}
+}
+
+// message returns the localized message for err, which was returned while
+// searching for sr, and false if it doesn't have one.
+func (s *SearchApp) message(lang string, sr *SearchRequest, err error) (string, bool) {
+	switch {
+	case errors.Is(err, ErrEmptyTitle):
+		return s.messages.Message(lang, MsgEmptyTitle), true
+	case errors.Is(err, ErrTitleTooLong):
+		return s.messages.Message(lang, MsgTitleTooLong, s.maxTitleLength), true
+	case errors.Is(err, ErrTitleTooShort):
+		return s.messages.Message(lang, MsgTitleTooShort, s.minTitleLength), true
+	case errors.Is(err, ErrInvalidType):
+		return s.messages.Message(lang, MsgInvalidType, sr.Type), true
+	case errors.Is(err, ErrTypeNotAllowed):
+		return s.messages.Message(lang, MsgTypeNotAllowed, sr.Type), true
+	case errors.Is(err, ErrInvalidYear):
+		return s.messages.Message(lang, MsgInvalidYear, sr.ReleaseYear), true
+	case errors.Is(err, ErrInvalidPage):
+		return s.messages.Message(lang, MsgInvalidPage, maxPage), true
+	case errors.Is(err, ErrInvalidPosterWidth):
+		return s.messages.Message(lang, MsgInvalidPosterWidth, maxPosterWidth), true
+	case errors.Is(err, ErrInvalidMinYear):
+		return s.messages.Message(lang, MsgInvalidMinYear, sr.MinYear), true
+	case errors.Is(err, ErrInvalidIDPattern):
+		return s.messages.Message(lang, MsgInvalidIDPattern, sr.IDPattern), true
+	case errors.Is(err, ErrInvalidCursor):
+		return s.messages.Message(lang, MsgInvalidCursor), true
+	case errors.Is(err, ErrMovieNotFound):
+		return s.messages.Message(lang, MsgNotFound, sr.Title), true
+	case errors.Is(err, ErrTooManyResults):
+		return s.messages.Message(lang, MsgTooManyResults, sr.Title), true
+	}
+	return "", false
+}
+
+// searchError records err, which was returned while searching for sr, and
+// responds with the matching status code. A *ValidationError gets a 422 with
+// one message per line, or a 400 if the title is too short, which is a
+// malformed search rather than one that merely fails a check.
+func (s *SearchApp) searchError(w http.ResponseWriter, r *http.Request, sr *SearchRequest, err error) {
+	lang := r.Header.Get("Accept-Language")
+
+	var validationErr *ValidationError
+	if errors.As(err, &validationErr) {
+		msgs := make([]string, len(validationErr.Errors))
+		for i, e := range validationErr.Errors {
+			if msg, ok := s.message(lang, sr, e); ok {
+				msgs[i] = msg
+			} else {
+				msgs[i] = e.Error()
+			}
+		}
+		status := http.StatusUnprocessableEntity
+		if errors.Is(err, ErrTitleTooShort) {
+			status = http.StatusBadRequest
+		}
+		http.Error(w, strings.Join(msgs, "\n"), status)
+		return
+	}
+
+	if msg, ok := s.message(lang, sr, err); ok {
+		status := http.StatusBadRequest
+		switch {
+		case errors.Is(err, ErrTypeNotAllowed):
+			status = http.StatusForbidden
+		case errors.Is(err, ErrMovieNotFound):
+			status = http.StatusNotFound
+		}
+		http.Error(w, msg, status)
+		return
+	}
+
+	s.notifier.Record(err)
+	s.recentErrors.Add(err)
+
+	// The upstream error is only included verbatim when asked for, as it's
+	// meant for troubleshooting rather than for clients. The message never
+	// includes the request URL, as it has the API key.
+	msg := errorMessage(err)
+	var (
+		apiErr *APIError
+		urlErr *url.Error
+	)
+	isAPIErr := errors.As(err, &apiErr)
+	if s.upstreamErrors && isAPIErr && apiErr.Body != "" {
+		msg += "\nomdb response: " + apiErr.Body
+	}
+
+	switch {
+	case errors.Is(err, ErrQuotaExceeded):
+		retry := s.omdb.quota.untilReset().Seconds()
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retry))))
+		http.Error(w, msg, http.StatusTooManyRequests)
+	case errors.Is(err, ErrInvalidAPIKey) && r.Header.Get(apiKeyHeader) != "":
+		http.Error(w, msg, http.StatusUnauthorized)
+	case errors.Is(err, ErrInvalidAPIKey):
+		http.Error(w, msg, http.StatusBadGateway)
+	case isAPIErr, errors.As(err, &urlErr):
+		// OMDb failed or couldn't be reached, rather than this service.
+		http.Error(w, msg, http.StatusBadGateway)
+	default:
+		http.Error(w, msg, http.StatusInternalServerError)
+	}
+}
+
+// maxRequestBodySize is the largest request body, in bytes, that is accepted
+// by the search handlers.
+const maxRequestBodySize = 64 * 1024
+
+// defaultBodyTimeout is how long a client has to send a request body.
+const defaultBodyTimeout = 5 * time.Second
+
+// readJSONBody reads the body of r, which must have a JSON content type, be
+// no larger than maxRequestBodySize and arrive within the body timeout, so
+// that a client trickling its body can't hold on to a handler. If it's not, an
+// error response is written to w and false is returned.
+func (s *SearchApp) readJSONBody(w http.ResponseWriter, r *http.Request) ([]byte, bool) {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "application/json" {
+		http.Error(w, "Content-Type must be application/json", http.StatusUnsupportedMediaType)
+		return nil, false
+	}
+
+	// Not every ResponseWriter supports deadlines, in which case the server's
+	// read timeout is all that applies.
+	rc := http.NewResponseController(w)
+	if err := rc.SetReadDeadline(time.Now().Add(s.bodyTimeout)); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		log.Println(err)
+	}
+
+	b, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestBodySize))
+	if err != nil {
+		// The connection is closed rather than reused, otherwise the server
+		// waits for the rest of the body before writing the response.
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			w.Header().Set("Connection", "close")
+			http.Error(w, "timed out reading the request body", http.StatusRequestTimeout)
+			return nil, false
+		}
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			msg := fmt.Sprintf("request body must be at most %d bytes", maxRequestBodySize)
+			http.Error(w, msg, http.StatusRequestEntityTooLarge)
+			return nil, false
+		}
+		// The client has most likely gone away, so the response is only for
+		// the rare one that's still listening.
+		if connectionDropped(err) {
+			log.Printf("client %s dropped the connection while sending the request body: %s", r.RemoteAddr, err)
+			http.Error(w, "the request body was cut short", http.StatusBadRequest)
+			return nil, false
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, false
+	}
+
+	// The deadline is cleared once the body is read, otherwise the server's
+	// background read of the connection times out while the search is running,
+	// which it treats as the client going away and cancels the request's
+	// context.
+	rc.SetReadDeadline(time.Time{})
+
+	if s.omdb.bodyLogLimit > 0 {
+		log.Printf("request body: %s %s: %s", r.Method, r.URL.Path, s.omdb.loggedBody(r.Context(), b))
+	}
+	return b, true
+}
+
+// The examples of valid request bodies shown when a body isn't valid JSON.
+const (
+	searchExample   = `{"title":"..."}`
+	mergeExample    = `{"titles":["...","..."]}`
+	jobExample      = `{"title":"...","max_results":100}`
+	detailedExample = `{"title":"...","limit":5}`
+)
+
+// defaultDocsURL is the documentation linked to from error responses.
+const defaultDocsURL = "https://github.com/johnworth/omdb-example"
+
+// invalidJSON responds to a request body that isn't a valid JSON object, which
+// err describes, with a 400 showing an example of a valid one. The error from
+// the JSON decoder is only logged in debug mode, as it's rarely helpful to
+// integrators.
+func (s *SearchApp) invalidJSON(w http.ResponseWriter, err error, example string) {
+	if s.omdb.debug && err != nil {
+		log.Printf("invalid JSON body: %s", err)
+	}
+
+	msg := "invalid JSON body; expected " + example
+	if s.docsURL != "" {
+		msg += "\nsee " + s.docsURL
+	}
+	http.Error(w, msg, http.StatusBadRequest)
+}
+
+// search returns the results for r. Titles that are IMDb IDs are looked up
+// directly rather than searched for, and the single match is returned as the
+// only result.
+func (s *SearchApp) search(ctx context.Context, r *SearchRequest) ([]*SearchResult, error) {
+	if s.cacheKeys == CacheKeyTerm && s.cache != nil && r.Page <= pagesFor(maxTermCacheResults) &&
+		(s.omdb.maxPages == 0 || r.Page <= s.omdb.maxPages) &&
+		!imdbIDPattern.MatchString(strings.TrimSpace(r.Title)) {
+		return s.searchTerm(ctx, r)
+	}
+	return s.searchSingle(ctx, r)
+}
+
+// searchSingle returns the results for r, as search does, but with a single
+// upstream call whatever the cache key strategy, as the results are cached by
+// page.
+func (s *SearchApp) searchSingle(ctx context.Context, r *SearchRequest) ([]*SearchResult, error) {
+	id := strings.TrimSpace(r.Title)
+	isID := imdbIDPattern.MatchString(id)
+
+	fetch := func(ctx context.Context) ([]*SearchResult, error) {
+		if isID {
+			detail, err := s.searchAPI.GetByID(ctx, id)
+			if err != nil {
+				return nil, err
+			}
+			return []*SearchResult{detail.SearchResult()}, nil
+		}
+		return s.searchAPI.Search(ctx, r)
+	}
+
+	// An IMDb ID that wasn't found is cached as empty results.
+	key := cacheKey(r)
+	if results, ok, refresh := s.cache.lookup(key); ok {
+		if refresh {
+			s.refreshAhead(ctx, key, fetch)
+		}
+		if isID && len(results) == 0 {
+			return nil, fmt.Errorf("%w: %s", ErrMovieNotFound, id)
+		}
+		return results, nil
+	}
+
+	// Identical searches that arrive while this one is in flight share its
+	// upstream call, which is detached from the first of them, so that it
+	// carries on for the others if that client goes away.
+	results, err, _ := s.flights.Do(ctx, flightKey(ctx, key), fetch)
+	if err != nil {
+		if isID && errors.Is(err, ErrMovieNotFound) {
+			s.cache.Set(key, nil)
+		}
+		return nil, err
+	}
+
+	s.cache.Set(key, results)
+	return results, nil
+}
+
+// serverProtocols returns the protocols for the server to accept. HTTP/2 is
+// negotiated automatically over TLS, but a proxy that terminates TLS can only
+// speak HTTP/2 to the service in cleartext, h2c, which has to be enabled. It
+// lets the proxy multiplex concurrent browser requests over one connection,
+// but h2c has no protection of its own, so it should only be enabled on a
+// trusted network.
+func serverProtocols(h2c bool) *http.Protocols {
+	p := &http.Protocols{}
+	p.SetHTTP1(true)
+	p.SetHTTP2(true)
+	p.SetUnencryptedHTTP2(h2c)
+	return p
 }
 
 func fixAddr(addr string) string {
@@ -195,20 +1037,235 @@ func fixAddr(addr string) string {
 
 func main() {
 	var (
-		key  = flag.String("key", "", "The OMDb API key.")
-		port = flag.String("port", "60000", "The port number to listen on.")
+		key         = flag.String("key", "", "The OMDb API key.")
+		checkConfig = flag.Bool("check-config", false, "Validate the configuration, print a report of the checks and exit, without starting the server.")
+		port        = flag.String("port", "60000", "The port number to listen on.")
+
+		jsonCase         = flag.String("json-case", PascalCase, "The casing of JSON keys in search responses, either pascal or camel.")
+		noContentOnEmpty = flag.Bool("no-content-on-empty", false, "Respond with a 204 instead of an empty array when a search matches nothing.")
+		querySyntax      = flag.Bool("query-syntax", false, "Parse type:, year: and page: tokens out of search titles, e.g. \"type:movie year:1999 the matrix\".")
+		imdbURLs         = flag.Bool("imdb-urls", false, "Include the URL of each result's IMDb page in search responses.")
+		titleCase        = flag.Bool("title-case", false, "Normalize result titles to title case. The original titles are returned as RawTitle.")
+		pageFailures     = flag.String("page-failures", PageFailuresFailFast, "What search jobs do when a page fails after earlier ones succeeded, either fail-fast, to fail the search, or best-effort, to return the results of the earlier pages with a warning.")
+		collation        = flag.String("collation", "", "The locale, such as sv or es, whose alphabetical order is used for results merged from several pages. The root Unicode collation order, ignoring case and accents, if empty.")
+		maxPages         = flag.Int("max-pages", 0, "The maximum number of pages fetched for a single search by jobs, exports and the term cache. Only limited by OMDb if zero.")
+		maxTitleLength   = flag.Int("max-title-length", 256, "The maximum number of characters allowed in a search title.")
+		minTitleLength   = flag.Int("min-title-length", defaultMinTitleLength, "The minimum number of characters allowed in a search title. 1 allows any title.")
+
+		cacheTTL        = flag.Duration("cache-ttl", 0, "How long search results are cached for. Caching is disabled if zero.")
+		negativeTTL     = flag.Duration("negative-cache-ttl", 0, "How long searches that match nothing are cached for, usually less than the cache TTL. They aren't cached if zero.")
+		cacheSize       = flag.Int("cache-size", 1000, "The maximum number of searches to cache.")
+		refreshAhead    = flag.Duration("refresh-ahead", 0, "Refresh popular cached searches in the background when they're this close to expiring. Disabled if zero.")
+		refreshHits     = flag.Int("refresh-ahead-hits", 5, "The number of times a cached search must be read before it's refreshed ahead of expiring.")
+		cacheKeys       = flag.String("cache-keys", CacheKeyPage, "What search results are cached by, either page, for each page of results, or term, for all of the results of a search at once.")
+		coalesceWindow  = flag.Duration("coalesce-window", 0, "How long after an OMDb call completes that identical searches still share its results, e.g. 50ms. Only searches made while it's in flight share it if zero.")
+		warmupFile      = flag.String("warmup-file", "", "A file of search titles, one per line, to populate the cache with at startup.")
+		warmupInterval  = flag.Duration("warmup-interval", 250*time.Millisecond, "The delay between searches during warmup.")
+		adminToken      = flag.String("admin-token", "", "The bearer token for the /admin endpoints, which are disabled if empty.")
+		cursorSecret    = flag.String("cursor-secret", "", "The secret that pagination cursors are signed with. A random one is used if empty, so cursors can't be used across restarts or instances.")
+		duplicateParams = flag.String("duplicate-params", DuplicateReject, "How GET endpoints handle a query parameter given more than once, one of reject, first or last.")
+		docsURL         = flag.String("docs-url", defaultDocsURL, "The documentation linked to from error responses.")
+		dashboard       = flag.Bool("dashboard", false, "Serve an HTML page of live stats at /debug, protected by the admin token.")
+		clientKeys      = flag.Bool("client-keys", false, "Allow clients to make searches with their own OMDb API key in the X-OMDb-Key header.")
+		enablePprof     = flag.Bool("pprof", false, "Serve profiles under /debug/pprof/, protected by the admin token. Only enable this in trusted environments.")
+		disable         = flag.String("disable", "", "A comma separated list of the features to disable, of "+strings.Join(Features(), ", ")+".")
+		allowedOrigins  = flag.String("allowed-origins", "", "A comma separated list of the origins, e.g. https://example.com, that browsers may POST from. Any origin is allowed if empty.")
+
+		landing     = flag.String("landing", LandingGreeting, "What to serve for /, one of greeting, index, redirect or json.")
+		siteDir     = flag.String("site-dir", "site", "The directory of static files served in the index landing mode.")
+		landingURL  = flag.String("landing-url", "", "The URL that / redirects to in the redirect landing mode.")
+		requireSite = flag.Bool("require-site", false, "Exit at startup if the site directory is missing in the index landing mode, instead of serving a minimal page.")
+		basePath    = flag.String("base-path", "", "The path prefix to serve all routes under, e.g. /omdb when behind a reverse proxy.")
+		staticExts  = flag.String("static-extensions", strings.Join(defaultStaticExtensions, ","), "A comma separated list of the extensions of the files served from the site directory.")
+		notFound    = flag.String("not-found-page", defaultNotFoundPage, "The page in the site directory to serve for static files that don't exist. A plain 404 is served if it's missing.")
+		posterHosts = flag.String("poster-hosts", strings.Join(defaultPosterHosts, ","), "A comma separated list of the hosts that /poster and /export/posters fetch images from.")
+		theme       = flag.String("theme", "", "The bundled theme to serve in the index landing mode, one of "+strings.Join(Themes(), ", ")+". Defaults to the site directory.")
+
+		fallbackURL = flag.String("fallback-url", "", "The base URL of a secondary OMDb API to use when the primary fails.")
+
+		readTimeout     = flag.Duration("read-timeout", 10*time.Second, "The maximum duration for reading an entire request, including the body.")
+		writeTimeout    = flag.Duration("write-timeout", 30*time.Second, "The maximum duration before timing out writes of a response.")
+		bodyTimeout     = flag.Duration("body-timeout", defaultBodyTimeout, "The maximum duration for reading a request body before responding with a 408.")
+		idleTimeout     = flag.Duration("idle-timeout", 120*time.Second, "The maximum time to wait for the next request on a keep-alive connection.")
+		shutdownTimeout = flag.Duration("shutdown-timeout", defaultShutdownTimeout, "The maximum time to wait for in-flight requests to finish on SIGINT or SIGTERM before closing their connections.")
+		h2c             = flag.Bool("h2c", false, "Accept HTTP/2 without TLS, as well as HTTP/1, for when TLS is terminated by a proxy in front of the service that speaks HTTP/2 to it.")
+
+		allowedTypes = flag.String("allowed-types", "", "A comma separated list of the types that may be searched for. All types are allowed if empty.")
+		extraTypes   = flag.String("extra-types", "", "A comma separated list of types to accept in addition to movie, series and episode.")
+
+		clientRateLimit  = flag.Int("client-rate-limit", 0, "The maximum number of requests that call OMDb per client rate window from each client IP. Unlimited if zero.")
+		clientRateWindow = flag.Duration("client-rate-window", time.Minute, "The window that the client rate limit applies to.")
+		trustProxy       = flag.Bool("trust-proxy", false, "Take the client IP for rate limiting from the X-Forwarded-For header. Only enable this behind a proxy that sets it.")
+
+		shedErrorRate = flag.Float64("shed-error-rate", 0, "Shed a fraction of searches with a 503 while the error rate of OMDb calls is over this, e.g. 0.5. Disabled if zero.")
+		shedLatency   = flag.Duration("shed-latency", 0, "Shed a fraction of searches with a 503 while the mean latency of OMDb calls is over this. Disabled if zero.")
+		shedWindow    = flag.Duration("shed-window", time.Minute, "The window that the error rate and latency for load shedding are measured over.")
+		shedMinCalls  = flag.Int("shed-min-calls", 20, "The number of OMDb calls within the shed window before any searches are shed.")
+
+		retries           = flag.Int("retries", 0, "The number of times to retry OMDb requests that fail with a transient error.")
+		retryDelay        = flag.Duration("retry-delay", 100*time.Millisecond, "The delay before the first retry, which doubles for each retry after it.")
+		retryMaxDelay     = flag.Duration("retry-max-delay", 2*time.Second, "The maximum delay between retries.")
+		retryJitter       = flag.String("retry-jitter", JitterFull, "The jitter applied to retry delays, one of none, full or equal.")
+		retryBudget       = flag.Int("retry-budget", 0, "The maximum number of retries across all requests per retry budget window. Unlimited if zero.")
+		retryBudgetWindow = flag.Duration("retry-budget-window", time.Minute, "The window that the retry budget applies to.")
+
+		dailyQuota     = flag.Int("daily-quota", defaultDailyQuota, "The daily request limit of the API key, used to estimate the remaining quota.")
+		quotaResetHour = flag.Int("quota-reset-hour", 0, "The hour, in UTC, that the daily request limit resets at.")
+		quotaHeader    = flag.Bool("quota-header", false, "Add an X-Quota-Remaining header with the estimated remaining quota to search responses.")
+
+		debug          = flag.Bool("debug", false, "Log the URL of each OMDb request, with the API key redacted.")
+		insecure       = flag.Bool("insecure-skip-verify", false, "Don't verify the TLS certificates of the OMDb and fallback APIs, e.g. for a test proxy with a self-signed certificate. Never enable this in production.")
+		logBodies      = flag.Bool("log-bodies", false, "With --debug, also log search request bodies and OMDb response bodies, redacted and cut to --body-log-limit bytes. Very verbose.")
+		bodyLogLimit   = flag.Int("body-log-limit", defaultBodyLogLimit, "The number of bytes of each body logged by --log-bodies.")
+		upstreamErrors = flag.Bool("upstream-errors", false, "Include OMDb's error response, with the API key redacted, in error responses. Only enable this for troubleshooting.")
+
+		latencyQuantiles = flag.Bool("latency-quantiles", false, "Report estimated p50, p95 and p99 upstream latencies in /metrics, in addition to the histogram.")
+
+		readyWindow = flag.Duration("ready-window", 0, "How long /readyz fails for after a failed OMDb call, unless a later call succeeds. Disabled if zero.")
+
+		historySize = flag.Int("history-size", 0, "The number of recent searches listed by /search/history. Disabled if zero. It requires --admin-token, which it's protected by.")
+		historyFile = flag.String("history-file", "", "A file to keep the search history in, so that it survives restarts. It's only kept in memory if empty.")
+
+		webhookURL       = flag.String("webhook-url", "", "The URL to POST to when upstream errors cross the threshold.")
+		webhookThreshold = flag.Int("webhook-threshold", 5, "The number of upstream errors of one type that triggers the webhook.")
+		webhookWindow    = flag.Duration("webhook-window", 5*time.Minute, "The window that upstream errors are counted in.")
+		webhookTimeout   = flag.Duration("webhook-timeout", 5*time.Second, "The timeout for webhook requests.")
 	)
 
 	flag.Parse()
 
-	if *key == "" {
+	if *key == "" && !*checkConfig {
 		fmt.Println("--key is required.")
 		os.Exit(-1)
 	}
 
-	app, err := NewSearchApp(*key)
+	if *extraTypes != "" {
+		AddTypes(strings.Split(*extraTypes, ",")...)
+	}
+
+	var types []string
+	if *allowedTypes != "" {
+		types = strings.Split(*allowedTypes, ",")
+	}
+
+	var origins []string
+	if *allowedOrigins != "" {
+		origins = strings.Split(*allowedOrigins, ",")
+	}
+
+	var disabled []string
+	if *disable != "" {
+		disabled = strings.Split(*disable, ",")
+	}
+
+	cfg := Config{
+		Key:                *key,
+		FallbackURL:        *fallbackURL,
+		InsecureSkipVerify: *insecure,
+		JSONCase:           *jsonCase,
+		MaxTitleLength:     *maxTitleLength,
+		MinTitleLength:     *minTitleLength,
+		BodyTimeout:        *bodyTimeout,
+		NoContentOnEmpty:   *noContentOnEmpty,
+		TitleCase:          *titleCase,
+		IMDBURLs:           *imdbURLs,
+		QuerySyntax:        *querySyntax,
+		AllowedTypes:       types,
+		CacheTTL:           *cacheTTL,
+		NegativeCacheTTL:   *negativeTTL,
+		CacheSize:          *cacheSize,
+		RefreshAhead:       *refreshAhead,
+		RefreshAheadHits:   *refreshHits,
+		CacheKeys:          *cacheKeys,
+		CoalesceWindow:     *coalesceWindow,
+		MaxPages:           *maxPages,
+		Collation:          *collation,
+		PageFailures:       *pageFailures,
+		Retries:            *retries,
+		RetryDelay:         *retryDelay,
+		RetryMaxDelay:      *retryMaxDelay,
+		RetryJitter:        *retryJitter,
+		RetryBudget:        *retryBudget,
+		RetryBudgetWindow:  *retryBudgetWindow,
+		ClientRateLimit:    *clientRateLimit,
+		ClientRateWindow:   *clientRateWindow,
+		TrustProxy:         *trustProxy,
+		ShedErrorRate:      *shedErrorRate,
+		ShedLatency:        *shedLatency,
+		ShedWindow:         *shedWindow,
+		ShedMinCalls:       *shedMinCalls,
+		DailyQuota:         *dailyQuota,
+		QuotaResetHour:     *quotaResetHour,
+		QuotaHeader:        *quotaHeader,
+		Landing:            *landing,
+		SiteDir:            *siteDir,
+		Theme:              *theme,
+		LandingURL:         *landingURL,
+		RequireSite:        *requireSite,
+		StaticExtensions:   strings.Split(*staticExts, ","),
+		NotFoundPage:       *notFound,
+		PosterHosts:        strings.Split(*posterHosts, ","),
+		BasePath:           *basePath,
+		AllowedOrigins:     origins,
+		AdminToken:         *adminToken,
+		DocsURL:            *docsURL,
+		DuplicateParams:    *duplicateParams,
+		CursorSecret:       *cursorSecret,
+		DisabledFeatures:   disabled,
+		Pprof:              *enablePprof,
+		ClientKeys:         *clientKeys,
+		Dashboard:          *dashboard,
+		Debug:              *debug,
+		LogBodies:          *logBodies,
+		BodyLogLimit:       *bodyLogLimit,
+		UpstreamErrors:     *upstreamErrors,
+		ReadyWindow:        *readyWindow,
+		HistorySize:        *historySize,
+		HistoryFile:        *historyFile,
+		LatencyQuantiles:   *latencyQuantiles,
+		WebhookURL:         *webhookURL,
+		WebhookThreshold:   *webhookThreshold,
+		WebhookWindow:      *webhookWindow,
+		WebhookTimeout:     *webhookTimeout,
+	}
+
+	if *checkConfig {
+		err := CheckConfig(os.Stdout, cfg, map[string]time.Duration{
+			"read timeout":     *readTimeout,
+			"write timeout":    *writeTimeout,
+			"body timeout":     *bodyTimeout,
+			"idle timeout":     *idleTimeout,
+			"shutdown timeout": *shutdownTimeout,
+		})
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	app, err := NewSearchAppWithConfig(cfg)
 	if err != nil {
 		log.Fatal(err)
 	}
-	log.Fatal(http.ListenAndServe(fixAddr(*port), app.mux))
+
+	if *warmupFile != "" {
+		titles, err := readWarmupFile(*warmupFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		go app.Warmup(context.Background(), titles, *warmupInterval)
+	}
+
+	server := &http.Server{
+		Addr:         fixAddr(*port),
+		Handler:      app.Handler(),
+		ReadTimeout:  *readTimeout,
+		WriteTimeout: *writeTimeout,
+		IdleTimeout:  *idleTimeout,
+		Protocols:    serverProtocols(*h2c),
+	}
+	if err := serve(server, app, *shutdownTimeout); err != nil && err != http.ErrServerClosed {
+		log.Fatal(err)
+	}
 }
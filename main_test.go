@@ -0,0 +1,669 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testKey is the API key the test apps are configured with.
+const testKey = "test-key"
+
+// fakeOMDb is a stand-in for the OMDb API that records the queries it's sent.
+type fakeOMDb struct {
+	*httptest.Server
+
+	mu      sync.Mutex
+	queries []url.Values
+}
+
+// newFakeOMDb starts a fakeOMDb that answers requests with h.
+func newFakeOMDb(t *testing.T, h http.HandlerFunc) *fakeOMDb {
+	t.Helper()
+	f := &fakeOMDb{}
+	f.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		f.queries = append(f.queries, r.URL.Query())
+		f.mu.Unlock()
+		h(w, r)
+	}))
+	t.Cleanup(f.Close)
+	return f
+}
+
+// baseURL returns the base URL to configure an OMDBAPI with.
+func (f *fakeOMDb) baseURL() string {
+	return f.URL + "/?"
+}
+
+// calls returns the number of requests f has been sent.
+func (f *fakeOMDb) calls() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.queries)
+}
+
+// query returns the query of the ith request f was sent.
+func (f *fakeOMDb) query(i int) url.Values {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.queries[i]
+}
+
+// writeResults writes an OMDb search response of results, out of total.
+func writeResults(w http.ResponseWriter, total int, results ...*SearchResult) {
+	if len(results) == 0 {
+		fmt.Fprintf(w, `{"Response":"False","Error":%q}`, movieNotFoundMessage)
+		return
+	}
+	json.NewEncoder(w).Encode(&SearchWrapper{
+		Search:       results,
+		TotalResults: strconv.Itoa(total),
+		Response:     "True",
+	})
+}
+
+// writeError writes an OMDb error response with msg.
+func writeError(w http.ResponseWriter, msg string) {
+	fmt.Fprintf(w, `{"Response":"False","Error":%q}`, msg)
+}
+
+// resultsFor returns a movie result for each of the IMDb IDs in ids.
+func resultsFor(ids ...string) []*SearchResult {
+	results := make([]*SearchResult, len(ids))
+	for i, id := range ids {
+		results[i] = &SearchResult{Title: "Title " + id, Year: "1999", IMDBID: id, Type: "movie"}
+	}
+	return results
+}
+
+// pagedResults returns a handler for a search that has total results, which
+// are served in pages of PageSize with IDs tt1, tt2 and so on.
+func pagedResults(total int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		page = max(page, 1)
+
+		var ids []string
+		for i := (page-1)*PageSize + 1; i <= min(page*PageSize, total); i++ {
+			ids = append(ids, "tt"+strconv.Itoa(i))
+		}
+		writeResults(w, total, resultsFor(ids...)...)
+	}
+}
+
+// newTestApp returns a *SearchApp configured by cfg that uses omdb, with
+// testKey as its key.
+func newTestApp(t *testing.T, omdb *fakeOMDb, cfg Config) *SearchApp {
+	t.Helper()
+	cfg.Key = testKey
+	cfg.BaseURL = omdb.baseURL()
+	s, err := NewSearchAppWithConfig(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// newTestAPI returns an *OMDBAPI that uses omdb, with testKey as its key.
+func newTestAPI(t *testing.T, omdb *fakeOMDb) *OMDBAPI {
+	t.Helper()
+	api, err := InitWithURL(omdb.baseURL(), testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return api
+}
+
+// send sends a request for method and target, with body as JSON if it isn't
+// empty, to s and returns the response.
+func send(s *SearchApp, method, target, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	return serveRequest(s, req)
+}
+
+// newSearchRequest returns a POST to /search with body as JSON.
+func newSearchRequest(body string) *http.Request {
+	req := httptest.NewRequest("POST", "/search", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	return req
+}
+
+// serveRequest sends req to s and returns the response.
+func serveRequest(s *SearchApp, req *http.Request) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	s.Handler().ServeHTTP(w, req)
+	return w
+}
+
+// startJob starts a search job for body and returns its ID.
+func startJob(t *testing.T, s *SearchApp, body string) string {
+	t.Helper()
+	w := send(s, "POST", "/jobs/search", body)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("got status %d starting a job, want 202: %s", w.Code, w.Body)
+	}
+	var started struct{ ID string }
+	if err := json.Unmarshal(w.Body.Bytes(), &started); err != nil {
+		t.Fatal(err)
+	}
+	return started.ID
+}
+
+// waitForJob returns the job with the given ID once it has finished.
+func waitForJob(t *testing.T, s *SearchApp, id string) *Job {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		var job Job
+		w := send(s, "GET", "/jobs/"+id, "")
+		if err := json.Unmarshal(w.Body.Bytes(), &job); err != nil {
+			t.Fatalf("decoding %q: %s", w.Body, err)
+		}
+		if job.Status != JobRunning {
+			return &job
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("job %s is still running", id)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// decodeResults returns the search results in the envelope in the body of w.
+func decodeResults(t *testing.T, w *httptest.ResponseRecorder) []*SearchResult {
+	t.Helper()
+	var env struct {
+		Results []*SearchResult `json:"results"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &env); err != nil {
+		t.Fatalf("decoding %q: %s", w.Body.String(), err)
+	}
+	return env.Results
+}
+
+// ids returns the IMDb IDs of results.
+func ids(results []*SearchResult) []string {
+	ids := make([]string, len(results))
+	for i, r := range results {
+		ids[i] = r.IMDBID
+	}
+	return ids
+}
+
+// writeDetail writes an OMDb response for a lookup of d by its IMDb ID.
+func writeDetail(w http.ResponseWriter, d *Detail) {
+	d.Response = "True"
+	json.NewEncoder(w).Encode(d)
+}
+
+func TestSearchByIMDbID(t *testing.T) {
+	omdb := newFakeOMDb(t, func(w http.ResponseWriter, r *http.Request) {
+		writeDetail(w, &Detail{Title: "The Matrix", Year: "1999", IMDBID: "tt0133093", Type: "movie"})
+	})
+	s := newTestApp(t, omdb, Config{})
+
+	w := serveRequest(s, newSearchRequest(`{"title":" tt0133093 "}`))
+	if w.Code != http.StatusOK {
+		t.Fatalf("got status %d, want 200: %s", w.Code, w.Body)
+	}
+	results := decodeResults(t, w)
+	if len(results) != 1 || results[0].IMDBID != "tt0133093" || results[0].Title != "The Matrix" {
+		t.Errorf("got results %v, want only tt0133093", ids(results))
+	}
+
+	q := omdb.query(0)
+	if q.Get("i") != "tt0133093" || q.Has("s") {
+		t.Errorf("got query %v, want a lookup of tt0133093", q)
+	}
+}
+
+func TestSearchByIMDbIDNotFound(t *testing.T) {
+	omdb := newFakeOMDb(t, func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, "Incorrect IMDb ID.")
+	})
+	s := newTestApp(t, omdb, Config{})
+
+	w := serveRequest(s, newSearchRequest(`{"title":"tt0000000"}`))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("got status %d, want 404: %s", w.Code, w.Body)
+	}
+}
+
+func TestSearchByTitle(t *testing.T) {
+	omdb := newFakeOMDb(t, func(w http.ResponseWriter, r *http.Request) {
+		writeResults(w, 2, resultsFor("tt1", "tt2")...)
+	})
+	s := newTestApp(t, omdb, Config{})
+
+	// Only a whole title that's an IMDb ID is looked up by it.
+	w := serveRequest(s, newSearchRequest(`{"title":"tt0133093 matrix"}`))
+	if w.Code != http.StatusOK {
+		t.Fatalf("got status %d, want 200: %s", w.Code, w.Body)
+	}
+	if got := ids(decodeResults(t, w)); strings.Join(got, ",") != "tt1,tt2" {
+		t.Errorf("got results %v, want tt1 and tt2", got)
+	}
+
+	q := omdb.query(0)
+	if q.Get("s") != "tt0133093 matrix" || q.Has("i") {
+		t.Errorf("got query %v, want a search for the title", q)
+	}
+}
+
+func TestSearchContentType(t *testing.T) {
+	omdb := newFakeOMDb(t, pagedResults(1))
+	s := newTestApp(t, omdb, Config{})
+
+	for _, contentType := range []string{"", "text/plain", "application/x-www-form-urlencoded"} {
+		req := httptest.NewRequest("POST", "/search", strings.NewReader(`{"title":"alien"}`))
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		if w := serveRequest(s, req); w.Code != http.StatusUnsupportedMediaType {
+			t.Errorf("%q: got status %d, want 415", contentType, w.Code)
+		}
+	}
+
+	req := newSearchRequest(`{"title":"alien"}`)
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	if w := serveRequest(s, req); w.Code != http.StatusOK {
+		t.Errorf("got status %d with a charset, want 200: %s", w.Code, w.Body)
+	}
+	if n := omdb.calls(); n != 1 {
+		t.Errorf("got %d calls to OMDb, want 1", n)
+	}
+}
+
+func TestSearchBodySize(t *testing.T) {
+	omdb := newFakeOMDb(t, pagedResults(1))
+	s := newTestApp(t, omdb, Config{})
+
+	padding := strings.Repeat(" ", maxRequestBodySize)
+	w := serveRequest(s, newSearchRequest(`{"title":"alien"}`+padding))
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("got status %d, want 413: %s", w.Code, w.Body)
+	}
+	if n := omdb.calls(); n != 0 {
+		t.Errorf("got %d calls to OMDb, want none", n)
+	}
+
+	body := `{"title":"alien"}`
+	w = serveRequest(s, newSearchRequest(body+padding[:maxRequestBodySize-len(body)]))
+	if w.Code != http.StatusOK {
+		t.Errorf("got status %d for a body of the max size, want 200: %s", w.Code, w.Body)
+	}
+}
+
+func TestSearchBodyTimeout(t *testing.T) {
+	omdb := newFakeOMDb(t, pagedResults(1))
+	s := newTestApp(t, omdb, Config{BodyTimeout: 50 * time.Millisecond})
+	srv := httptest.NewServer(s.Handler())
+	t.Cleanup(srv.Close)
+
+	conn, err := net.Dial("tcp", srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// The client sends part of the body and then stalls.
+	fmt.Fprint(conn, "POST /search HTTP/1.1\r\nHost: omdb\r\nContent-Type: application/json\r\nContent-Length: 100\r\n\r\n{\"title\":")
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusRequestTimeout {
+		t.Errorf("got status %d, want 408", resp.StatusCode)
+	}
+	if !resp.Close {
+		t.Error("got a response that keeps the connection open")
+	}
+	if n := omdb.calls(); n != 0 {
+		t.Errorf("got %d calls to OMDb, want none", n)
+	}
+
+	// A body that arrives in time isn't affected by the deadline, even when
+	// the search takes longer than it.
+	slow := newFakeOMDb(t, func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+		writeResults(w, 1, resultsFor("tt1")...)
+	})
+	s = newTestApp(t, slow, Config{BodyTimeout: 50 * time.Millisecond})
+	srv = httptest.NewServer(s.Handler())
+	t.Cleanup(srv.Close)
+
+	resp, err = http.Post(srv.URL+"/search", "application/json", strings.NewReader(`{"title":"alien"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("got status %d for a slow search, want 200", resp.StatusCode)
+	}
+}
+
+func TestSearchTooManyResults(t *testing.T) {
+	omdb := newFakeOMDb(t, func(w http.ResponseWriter, r *http.Request) {
+		// The exact response OMDb gives for a search that's too broad.
+		fmt.Fprint(w, `{"Response":"False","Error":"Too many results."}`)
+	})
+
+	_, err := newTestAPI(t, omdb).Search(context.Background(), &SearchRequest{Title: "th"})
+	if !errors.Is(err, ErrTooManyResults) {
+		t.Errorf("got error %v, want ErrTooManyResults", err)
+	}
+
+	s := newTestApp(t, omdb, Config{})
+	w := serveRequest(s, newSearchRequest(`{"title":"th"}`))
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "try a longer or more specific title") {
+		t.Errorf("got %d %q, want a 400 with a hint", w.Code, w.Body)
+	}
+}
+
+// captureLog returns a buffer that the standard logger writes to until the
+// test ends.
+func captureLog(t *testing.T) *syncBuffer {
+	t.Helper()
+	buf := &syncBuffer{}
+	log.SetOutput(buf)
+	t.Cleanup(func() { log.SetOutput(os.Stderr) })
+	return buf
+}
+
+// syncBuffer is a bytes.Buffer that's safe for concurrent use.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestRedactedSearchURL(t *testing.T) {
+	api, err := InitWithURL("http://omdb.example/?", "s3cret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	u := api.RedactedSearchURL(&SearchRequest{Title: "alien", Type: "movie", Page: 2})
+	if got, want := u.String(), "http://omdb.example/?apikey=***&page=2&s=alien&type=movie"; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+	if strings.Contains(u.String(), "s3cret") {
+		t.Errorf("the key wasn't redacted from %s", u)
+	}
+	if key := api.searchURL(&SearchRequest{Title: "alien"}).Query().Get("apikey"); key != "s3cret" {
+		t.Errorf("got key %q in the request URL, want the real one", key)
+	}
+}
+
+func TestRedactURLWithoutKey(t *testing.T) {
+	u, _ := url.Parse("http://omdb.example/?s=alien")
+	if got := redactURL(u).String(); got != u.String() {
+		t.Errorf("got %s, want it unchanged", got)
+	}
+}
+
+func TestDebugLogRedacted(t *testing.T) {
+	logs := captureLog(t)
+	s := newTestApp(t, newFakeOMDb(t, pagedResults(1)), Config{Debug: true})
+
+	serveRequest(s, newSearchRequest(`{"title":"alien"}`))
+	if !strings.Contains(logs.String(), "omdb request: GET") || !strings.Contains(logs.String(), "apikey=***") {
+		t.Errorf("got log %q, want the redacted request URL", logs)
+	}
+	if strings.Contains(logs.String(), testKey) {
+		t.Errorf("got log %q, which has the key", logs)
+	}
+}
+
+func TestSearchByID(t *testing.T) {
+	omdb := newFakeOMDb(t, func(w http.ResponseWriter, r *http.Request) {
+		writeResults(w, 3,
+			&SearchResult{Title: "Alien", IMDBID: "tt1"},
+			&SearchResult{Title: "Aliens", IMDBID: "tt2"},
+			&SearchResult{Title: "Alien (duplicate)", IMDBID: "tt1"},
+		)
+	})
+	api := newTestAPI(t, omdb)
+
+	byID, err := api.SearchByID(context.Background(), &SearchRequest{Title: "alien"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(byID) != 2 {
+		t.Errorf("got %d results, want 2", len(byID))
+	}
+	if got := byID["tt1"].Title; got != "Alien" {
+		t.Errorf("got %q for tt1, want the first result", got)
+	}
+	if got := byID["tt2"].Title; got != "Aliens" {
+		t.Errorf("got %q for tt2, want %q", got, "Aliens")
+	}
+}
+
+func TestSearchByIDError(t *testing.T) {
+	api := newTestAPI(t, newFakeOMDb(t, func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, "Invalid API key!")
+	}))
+
+	if byID, err := api.SearchByID(context.Background(), &SearchRequest{Title: "alien"}); err == nil || byID != nil {
+		t.Errorf("got %v and error %v, want an error", byID, err)
+	}
+}
+
+func TestSearchNoContentOnEmpty(t *testing.T) {
+	omdb := newFakeOMDb(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("s") {
+		case "alien":
+			writeResults(w, 1, resultsFor("tt1")...)
+		case "nothing":
+			writeError(w, movieNotFoundMessage)
+		default:
+			writeError(w, "Invalid API key!")
+		}
+	})
+
+	for _, test := range []struct {
+		noContent bool
+		title     string
+		want      int
+	}{
+		{false, "nothing", http.StatusOK},
+		{true, "nothing", http.StatusNoContent},
+		{true, "alien", http.StatusOK},
+		// Errors are never a 204.
+		{true, "broken", http.StatusBadGateway},
+	} {
+		s := newTestApp(t, omdb, Config{NoContentOnEmpty: test.noContent})
+		w := serveRequest(s, newSearchRequest(`{"title":"`+test.title+`"}`))
+		if w.Code != test.want {
+			t.Errorf("%s with a 204 %t: got status %d, want %d", test.title, test.noContent, w.Code, test.want)
+		}
+		if test.want == http.StatusNoContent && w.Body.Len() != 0 {
+			t.Errorf("got body %q for a 204", w.Body)
+		}
+	}
+}
+
+func TestInvalidJSON(t *testing.T) {
+	omdb := newFakeOMDb(t, pagedResults(1))
+	s := newTestApp(t, omdb, Config{DocsURL: "https://docs.example/omdb"})
+
+	for _, test := range []struct {
+		target, example string
+	}{
+		{"/search", searchExample},
+		{"/search/merge", mergeExample},
+		{"/jobs/search", jobExample},
+		{"/search/detailed", detailedExample},
+	} {
+		for _, body := range []string{`{"title":`, `null`, `"alien"`} {
+			w := send(s, "POST", test.target, body)
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("%s %s: got status %d, want 400", test.target, body, w.Code)
+			}
+			if want := "invalid JSON body; expected " + test.example + "\nsee https://docs.example/omdb\n"; w.Body.String() != want {
+				t.Errorf("%s %s: got %q, want %q", test.target, body, w.Body, want)
+			}
+		}
+	}
+	if n := omdb.calls(); n != 0 {
+		t.Errorf("got %d calls to OMDb, want none", n)
+	}
+}
+
+func TestInvalidJSONDebug(t *testing.T) {
+	for _, debug := range []bool{false, true} {
+		buf := captureLog(t)
+		s := newTestApp(t, newFakeOMDb(t, pagedResults(1)), Config{Debug: debug})
+
+		serveRequest(s, newSearchRequest(`{"title":`))
+		if logged := strings.Contains(buf.String(), "invalid JSON body: unexpected end of JSON input"); logged != debug {
+			t.Errorf("debug %t: got log %q", debug, buf)
+		}
+	}
+}
+
+func TestTrimBody(t *testing.T) {
+	for body, want := range map[string]string{
+		`{"Response":"True"}`: `{"Response":"True"}`,
+		"\ufeff{}":            "{}",
+		"\ufeff\ufeff {}":     "{}",
+		"\r\n\t {} \n":        "{} \n",
+		"":                    "",
+		"\ufeff":              "",
+		"x\ufeff{}":           "x\ufeff{}",
+	} {
+		if got := string(trimBody([]byte(body))); got != want {
+			t.Errorf("trimBody(%q) = %q, want %q", body, got, want)
+		}
+	}
+}
+
+func TestSearchBOM(t *testing.T) {
+	for _, prefix := range []string{"\ufeff", "\n  ", "\ufeff\r\n"} {
+		omdb := newFakeOMDb(t, func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Query().Get("s") == "broken" {
+				io.WriteString(w, prefix+`{"Response":"False","Error":"Invalid API key!"}`)
+				return
+			}
+			io.WriteString(w, prefix+`{"Search":[{"Title":"Alien","imdbID":"tt0078748"}],"totalResults":"1","Response":"True"}`)
+		})
+		api := newTestAPI(t, omdb)
+
+		results, err := api.Search(context.Background(), &SearchRequest{Title: "alien"})
+		if err != nil || len(results) != 1 || results[0].IMDBID != "tt0078748" {
+			t.Errorf("%q: got %v and error %v, want the result", prefix, results, err)
+		}
+		if _, err := api.Search(context.Background(), &SearchRequest{Title: "broken"}); !errors.Is(err, ErrInvalidAPIKey) {
+			t.Errorf("%q: got error %v, want ErrInvalidAPIKey", prefix, err)
+		}
+	}
+}
+
+func TestSearchDebug(t *testing.T) {
+	omdb := newFakeOMDb(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("s") == "broken" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		writeResults(w, 1, resultsFor("tt1")...)
+	})
+	api := newTestAPI(t, omdb)
+
+	results, u, err := api.SearchDebug(context.Background(), &SearchRequest{Title: "alien", Type: "movie", ReleaseYear: "1979", Page: 2})
+	if err != nil || len(results) != 1 {
+		t.Fatalf("got %v and error %v, want the result", results, err)
+	}
+	q := u.Query()
+	if q.Get("s") != "alien" || q.Get("type") != "movie" || q.Get("y") != "1979" || q.Get("page") != "2" {
+		t.Errorf("got URL %s, want the search's parameters", u)
+	}
+	if strings.Contains(u.String(), testKey) || q.Get("apikey") != "***" {
+		t.Errorf("got URL %s, want the key redacted", u)
+	}
+	if sent := omdb.query(0); sent.Get("s") != "alien" || sent.Get("page") != "2" {
+		t.Errorf("got query %v sent to OMDb, want the URL's", sent)
+	}
+
+	// The URL is returned even if the search fails.
+	_, u, err = api.SearchDebug(context.Background(), &SearchRequest{Title: "broken"})
+	if err == nil || u == nil || u.Query().Get("s") != "broken" {
+		t.Errorf("got URL %v and error %v, want both", u, err)
+	}
+}
+
+func TestServerProtocols(t *testing.T) {
+	for _, h2c := range []bool{false, true} {
+		if p := serverProtocols(h2c); !p.HTTP1() || !p.HTTP2() || p.UnencryptedHTTP2() != h2c {
+			t.Errorf("h2c %t: got %s", h2c, p)
+		}
+	}
+
+	s := newTestApp(t, newFakeOMDb(t, pagedResults(1)), Config{})
+
+	for _, h2c := range []bool{false, true} {
+		srv := httptest.NewUnstartedServer(s.Handler())
+		srv.Config.Protocols = serverProtocols(h2c)
+		srv.Start()
+		t.Cleanup(srv.Close)
+
+		for _, proto := range []int{1, 2} {
+			var p http.Protocols
+			if proto == 1 {
+				p.SetHTTP1(true)
+			} else {
+				p.SetUnencryptedHTTP2(true)
+			}
+			client := &http.Client{Transport: &http.Transport{Protocols: &p}}
+
+			resp, err := client.Post(srv.URL+"/search", "application/json", strings.NewReader(`{"title":"alien"}`))
+			if proto == 2 && !h2c {
+				if err == nil {
+					resp.Body.Close()
+					t.Errorf("got an HTTP/%d.%d response over h2c when it's disabled", resp.ProtoMajor, resp.ProtoMinor)
+				}
+				continue
+			}
+			if err != nil {
+				t.Fatalf("h2c %t, HTTP/%d: %s", h2c, proto, err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusOK || resp.ProtoMajor != proto {
+				t.Errorf("h2c %t: got %d over HTTP/%d, want 200 over HTTP/%d", h2c, resp.StatusCode, resp.ProtoMajor, proto)
+			}
+		}
+	}
+}
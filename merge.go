@@ -0,0 +1,153 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+)
+
+// maxMergeTitles is the maximum number of titles accepted by /search/merge.
+// Each title costs one upstream call, not counting retries, whatever the cache
+// key strategy, so this also bounds the number of calls a single merge request
+// can make.
+const maxMergeTitles = 5
+
+// MergeRequest represents the variables accepted by /search/merge. Type and
+// ReleaseYear apply to the search for every title.
+type MergeRequest struct {
+	Titles      []string `json:"titles"`
+	Type        string   `json:"type,omitempty"`
+	ReleaseYear string   `json:"release_year,omitempty"`
+}
+
+// mergeRank fuses several result sets into a single ranked list with no
+// duplicate IMDb IDs. Results are ranked by:
+//
+//  1. the number of result sets they appear in, most first;
+//  2. their best position in any of the result sets, lowest first;
+//  3. their IMDb ID, so that the order is deterministic.
+func mergeRank(resultSets [][]*SearchResult) []*SearchResult {
+	type ranked struct {
+		result  *SearchResult
+		count   int
+		bestPos int
+	}
+
+	byID := make(map[string]*ranked)
+	var merged []*ranked
+	for _, results := range resultSets {
+		seen := make(map[string]bool)
+		for pos, r := range results {
+			if seen[r.IMDBID] {
+				continue
+			}
+			seen[r.IMDBID] = true
+
+			m, ok := byID[r.IMDBID]
+			if !ok {
+				m = &ranked{result: r, bestPos: pos}
+				byID[r.IMDBID] = m
+				merged = append(merged, m)
+			}
+			m.count++
+			if pos < m.bestPos {
+				m.bestPos = pos
+			}
+		}
+	}
+
+	sort.Slice(merged, func(i, j int) bool {
+		a, b := merged[i], merged[j]
+		if a.count != b.count {
+			return a.count > b.count
+		}
+		if a.bestPos != b.bestPos {
+			return a.bestPos < b.bestPos
+		}
+		return a.result.IMDBID < b.result.IMDBID
+	})
+
+	results := make([]*SearchResult, len(merged))
+	for i, m := range merged {
+		results[i] = m.result
+	}
+	return results
+}
+
+// Merge handles requests to /search/merge. It searches for each of the titles
+// and returns the results as a single ranked list.
+func (s *SearchApp) Merge(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	if r.Method != "POST" {
+		http.NotFound(w, r)
+		return
+	}
+
+	b, ok := s.readJSONBody(w, r)
+	if !ok {
+		return
+	}
+
+	var mergeRequest *MergeRequest
+	if err := json.Unmarshal(b, &mergeRequest); err != nil || mergeRequest == nil {
+		s.invalidJSON(w, err, mergeExample)
+		return
+	}
+
+	if len(mergeRequest.Titles) == 0 || len(mergeRequest.Titles) > maxMergeTitles {
+		msg := fmt.Sprintf("between 1 and %d titles are required", maxMergeTitles)
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	var resultSets [][]*SearchResult
+	for _, title := range mergeRequest.Titles {
+		searchRequest := NewSearchRequest(title)
+		searchRequest.Type = mergeRequest.Type
+		searchRequest.ReleaseYear = mergeRequest.ReleaseYear
+
+		s.applyMiddleware(r, searchRequest)
+
+		if err := s.validate(searchRequest); err != nil {
+			s.searchError(w, r, searchRequest, err)
+			return
+		}
+
+		if !s.typeAllowed(searchRequest.Type) {
+			s.searchError(w, r, searchRequest, fmt.Errorf("%w: %q", ErrTypeNotAllowed, searchRequest.Type))
+			return
+		}
+
+		// The CacheKeyTerm strategy would fetch every page for each title.
+		results, err := s.searchSingle(r.Context(), searchRequest)
+		if err != nil {
+			s.searchError(w, r, searchRequest, err)
+			return
+		}
+		resultSets = append(resultSets, results)
+	}
+
+	results := mergeRank(resultSets)
+	if s.allowedTypes != nil {
+		results = filterResults(results, func(sr *SearchResult) bool {
+			return s.typeAllowed(sr.Type)
+		})
+	}
+
+	if s.titleCase {
+		results = titleCaseResults(results)
+	}
+
+	if s.imdbURLs {
+		results = withIMDBURLs(results)
+	}
+
+	jsonstr, err := json.Marshal(withJSONCase(results, s.jsonCase))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Write(jsonstr)
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMergeRank(t *testing.T) {
+	resultSets := [][]*SearchResult{
+		resultsFor("tt3", "tt1", "tt2"),
+		resultsFor("tt2", "tt4", "tt2"),
+		resultsFor("tt5", "tt2", "tt1"),
+	}
+
+	// tt2 is in all three sets, then tt1 is in two, then the rest are ordered
+	// by their best position, with ties broken by their IDs. The duplicate
+	// tt2 in the second set isn't counted twice.
+	want := []string{"tt2", "tt1", "tt3", "tt5", "tt4"}
+	if got := ids(mergeRank(resultSets)); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestMergeRankEmpty(t *testing.T) {
+	if got := mergeRank(nil); len(got) != 0 {
+		t.Errorf("got %v, want no results", ids(got))
+	}
+}
+
+// mergeResults returns a handler that answers each title search with the
+// results for it in byTitle.
+func mergeResults(byTitle map[string][]*SearchResult) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		results := byTitle[r.URL.Query().Get("s")]
+		writeResults(w, len(results), results...)
+	}
+}
+
+func TestMerge(t *testing.T) {
+	omdb := newFakeOMDb(t, mergeResults(map[string][]*SearchResult{
+		"alien":  resultsFor("tt1", "tt2"),
+		"aliens": resultsFor("tt2", "tt3"),
+	}))
+	s := newTestApp(t, omdb, Config{})
+
+	w := send(s, "POST", "/search/merge", `{"titles":["alien","aliens"],"type":"movie"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("got status %d, want 200: %s", w.Code, w.Body)
+	}
+	var results []*SearchResult
+	if err := json.Unmarshal(w.Body.Bytes(), &results); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := ids(results), []string{"tt2", "tt1", "tt3"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	for i := range omdb.calls() {
+		if q := omdb.query(i); q.Get("type") != "movie" {
+			t.Errorf("got query %v, want type movie", q)
+		}
+	}
+}
+
+func TestMergeTitleBounds(t *testing.T) {
+	omdb := newFakeOMDb(t, pagedResults(1))
+	s := newTestApp(t, omdb, Config{})
+
+	for _, titles := range [][]string{nil, {"a1", "a2", "a3", "a4", "a5", "a6"}} {
+		b, _ := json.Marshal(&MergeRequest{Titles: titles})
+		if w := send(s, "POST", "/search/merge", string(b)); w.Code != http.StatusBadRequest {
+			t.Errorf("%d titles: got status %d, want 400", len(titles), w.Code)
+		}
+	}
+	if n := omdb.calls(); n != 0 {
+		t.Errorf("got %d calls to OMDb, want none", n)
+	}
+}
+
+func TestMergeCallsPerTitle(t *testing.T) {
+	for _, cacheKeys := range []string{CacheKeyPage, CacheKeyTerm} {
+		t.Run(cacheKeys, func(t *testing.T) {
+			// Each title has several pages of results, which the term cache
+			// key strategy would otherwise fetch all of.
+			omdb := newFakeOMDb(t, pagedResults(3*PageSize))
+			s := newTestApp(t, omdb, Config{CacheTTL: time.Minute, CacheKeys: cacheKeys})
+
+			titles := `["a1","a2","a3","a4","a5"]`
+			if w := send(s, "POST", "/search/merge", `{"titles":`+titles+`}`); w.Code != http.StatusOK {
+				t.Fatalf("got status %d, want 200: %s", w.Code, w.Body)
+			}
+			if n := omdb.calls(); n != maxMergeTitles {
+				t.Errorf("got %d calls to OMDb, want %d", n, maxMergeTitles)
+			}
+		})
+	}
+}
+
+func TestMergeInvalidTitle(t *testing.T) {
+	omdb := newFakeOMDb(t, pagedResults(1))
+	s := newTestApp(t, omdb, Config{})
+
+	w := send(s, "POST", "/search/merge", `{"titles":["alien",""]}`)
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("got status %d, want 422: %s", w.Code, w.Body)
+	}
+	if !strings.Contains(w.Body.String(), "title") {
+		t.Errorf("got body %q, want the title error", w.Body)
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+)
+
+// writeMetric writes a single unlabelled metric to w in the Prometheus text
+// exposition format.
+func writeMetric(w io.Writer, name, metricType, help string, value float64) {
+	fmt.Fprintf(w, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(w, "# TYPE %s %s\n", name, metricType)
+	fmt.Fprintf(w, "%s %s\n", name, strconv.FormatFloat(value, 'g', -1, 64))
+}
+
+// HitRatio returns the fraction of cache lookups that were hits, or 0 if there
+// haven't been any lookups. It only reads the atomic counters, so it never
+// contends with searches.
+func (c *SearchCache) HitRatio() float64 {
+	if c == nil {
+		return 0
+	}
+
+	hits := atomic.LoadUint64(&c.hits)
+	total := hits + atomic.LoadUint64(&c.misses)
+	if total == 0 {
+		return 0
+	}
+	return float64(hits) / float64(total)
+}
+
+// Metrics handles requests to /metrics, reporting the service's metrics in the
+// Prometheus text exposition format.
+func (s *SearchApp) Metrics(w http.ResponseWriter, r *http.Request) {
+	stats := s.cache.Stats()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	writeMetric(w, "omdb_cache_entries", "gauge", "The number of searches in the cache.", float64(stats.Size))
+	writeMetric(w, "omdb_cache_hits_total", "counter", "The number of cache lookups that were hits.", float64(stats.Hits))
+	writeMetric(w, "omdb_cache_misses_total", "counter", "The number of cache lookups that were misses.", float64(stats.Misses))
+	writeMetric(w, "omdb_cache_evictions_total", "counter", "The number of entries evicted from the full cache.", float64(stats.Evictions))
+	writeMetric(w, "omdb_cache_refreshes_total", "counter", "The number of cache entries refreshed ahead of expiring.", float64(stats.Refreshes))
+	writeMetric(w, "omdb_cache_hit_ratio", "gauge", "The fraction of cache lookups that were hits.", s.cache.HitRatio())
+	writeMetric(w, "omdb_quota_used", "gauge", "The number of OMDb calls made since the daily quota reset.", float64(s.omdb.quota.Used()))
+	writeMetric(w, "omdb_quota_remaining", "gauge", "The estimated number of OMDb calls left in the daily quota.", float64(s.omdb.RemainingQuota()))
+	writeMetric(w, "omdb_upstream_last_success_age_seconds", "gauge", "The seconds since the last successful OMDb call, or since startup if there hasn't been one.", s.omdb.sinceLastSuccess().Seconds())
+	if s.omdb.shedder != nil {
+		writeMetric(w, "omdb_load_shed_fraction", "gauge", "The fraction of searches being shed because OMDb is degraded.", s.omdb.shedder.Fraction())
+		writeMetric(w, "omdb_load_shed_total", "counter", "The number of searches shed because OMDb was degraded.", float64(s.omdb.shedder.ShedCount()))
+	}
+	s.omdb.latency.write(w, "omdb_upstream_latency_seconds", "The latency of OMDb calls in seconds.")
+
+	if s.latencyQuantiles {
+		for _, q := range []struct {
+			name string
+			q    float64
+		}{{"p50", 0.5}, {"p95", 0.95}, {"p99", 0.99}} {
+			name := "omdb_upstream_latency_" + q.name + "_seconds"
+			help := fmt.Sprintf("The estimated %s latency of OMDb calls in seconds, from the histogram.", q.name)
+			writeMetric(w, name, "gauge", help, s.omdb.latency.Quantile(q.q))
+		}
+	}
+}
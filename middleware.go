@@ -0,0 +1,23 @@
+package main
+
+import "net/http"
+
+// RequestMiddleware is called with each *SearchRequest after it has been
+// parsed from the body of r, and before it's validated and sent upstream. It
+// can modify the request, e.g. to force a type for some routes.
+type RequestMiddleware func(r *http.Request, sr *SearchRequest)
+
+// Use registers m to be called for each search request. Middleware is called
+// in the order it's registered, and nil middleware is ignored.
+func (s *SearchApp) Use(m RequestMiddleware) {
+	if m != nil {
+		s.middleware = append(s.middleware, m)
+	}
+}
+
+// applyMiddleware calls each of the registered middleware with sr.
+func (s *SearchApp) applyMiddleware(r *http.Request, sr *SearchRequest) {
+	for _, m := range s.middleware {
+		m(r, sr)
+	}
+}
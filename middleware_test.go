@@ -0,0 +1,51 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestMiddleware(t *testing.T) {
+	omdb := newFakeOMDb(t, pagedResults(1))
+	s := newTestApp(t, omdb, Config{})
+
+	var order []string
+	s.Use(func(r *http.Request, sr *SearchRequest) {
+		order = append(order, "first")
+		if r.Header.Get("X-Kids") != "" {
+			sr.Type = "movie"
+		}
+	})
+	s.Use(nil)
+	s.Use(func(r *http.Request, sr *SearchRequest) {
+		order = append(order, "second")
+		sr.ReleaseYear = "1999"
+	})
+
+	req := newSearchRequest(`{"title":"alien","type":"series"}`)
+	req.Header.Set("X-Kids", "1")
+	if w := serveRequest(s, req); w.Code != http.StatusOK {
+		t.Fatalf("got status %d, want 200: %s", w.Code, w.Body)
+	}
+
+	q := omdb.query(0)
+	if q.Get("type") != "movie" || q.Get("y") != "1999" {
+		t.Errorf("got query %v, want the type and year set by the middleware", q)
+	}
+	if len(order) != 2 || order[0] != "first" || order[1] != "second" {
+		t.Errorf("got middleware called in order %v, want first then second", order)
+	}
+}
+
+func TestMiddlewareValidated(t *testing.T) {
+	omdb := newFakeOMDb(t, pagedResults(1))
+	s := newTestApp(t, omdb, Config{})
+	s.Use(func(r *http.Request, sr *SearchRequest) { sr.Type = "cartoon" })
+
+	if w := serveRequest(s, newSearchRequest(`{"title":"alien"}`)); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("got status %d, want the middleware's change to be validated", w.Code)
+	}
+	if n := omdb.calls(); n != 0 {
+		t.Errorf("got %d calls to OMDb, want none", n)
+	}
+}
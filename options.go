@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// searchMethods are the methods accepted by /search.
+var searchMethods = []string{"POST", "OPTIONS"}
+
+// EndpointDescriptor is the JSON document returned for an OPTIONS request,
+// describing how to call the endpoint.
+type EndpointDescriptor struct {
+	Path        string                 `json:"path"`
+	Methods     []string               `json:"methods"`
+	ContentType string                 `json:"contentType"`
+	Parameters  []*ParameterDescriptor `json:"parameters"`
+}
+
+// ParameterDescriptor describes a single field of a request body. Values is
+// only set for fields that accept a fixed set of values.
+type ParameterDescriptor struct {
+	Name     string   `json:"name"`
+	Type     string   `json:"type"`
+	Required bool     `json:"required,omitempty"`
+	Values   []string `json:"values,omitempty"`
+}
+
+// jsonType returns the JSON type of values of kind k.
+func jsonType(k reflect.Kind) string {
+	switch k {
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return "integer"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	}
+	return "string"
+}
+
+// searchParameters returns the descriptors of the fields of a SearchRequest,
+// taken from its JSON tags so that they can't get out of date.
+func (s *SearchApp) searchParameters() []*ParameterDescriptor {
+	t := reflect.TypeOf(SearchRequest{})
+	params := make([]*ParameterDescriptor, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := strings.Split(f.Tag.Get("json"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+
+		p := &ParameterDescriptor{
+			Name:     name,
+			Type:     jsonType(f.Type.Kind()),
+			Required: f.Name == "Title",
+		}
+		if f.Name == "Type" {
+			for typ := range validTypes {
+				if typ != "" && s.typeAllowed(typ) {
+					p.Values = append(p.Values, typ)
+				}
+			}
+			sort.Strings(p.Values)
+		}
+		params = append(params, p)
+	}
+	return params
+}
+
+// describeSearch responds to an OPTIONS request for /search with the Allow
+// header and an *EndpointDescriptor.
+func (s *SearchApp) describeSearch(w http.ResponseWriter) {
+	jsonstr, err := json.Marshal(&EndpointDescriptor{
+		Path:        s.basePath + "/search",
+		Methods:     searchMethods,
+		ContentType: "application/json",
+		Parameters:  s.searchParameters(),
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Allow", strings.Join(searchMethods, ", "))
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(jsonstr)
+}
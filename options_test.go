@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+// describe sends an OPTIONS request for target to s and returns the
+// descriptor and headers of the response.
+func describe(t *testing.T, s *SearchApp, target string) (*EndpointDescriptor, http.Header) {
+	t.Helper()
+	w := send(s, "OPTIONS", target, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("got status %d, want 200", w.Code)
+	}
+	var d EndpointDescriptor
+	if err := json.Unmarshal(w.Body.Bytes(), &d); err != nil {
+		t.Fatal(err)
+	}
+	return &d, w.Header()
+}
+
+func TestDescribeSearch(t *testing.T) {
+	omdb := newFakeOMDb(t, pagedResults(1))
+	s := newTestApp(t, omdb, Config{})
+
+	d, h := describe(t, s, "/search")
+	if got := h.Get("Allow"); got != "POST, OPTIONS" {
+		t.Errorf("got Allow %q, want POST, OPTIONS", got)
+	}
+	if d.Path != "/search" || d.ContentType != "application/json" || !reflect.DeepEqual(d.Methods, searchMethods) {
+		t.Errorf("got %+v", d)
+	}
+
+	params := make(map[string]*ParameterDescriptor)
+	for _, p := range d.Parameters {
+		params[p.Name] = p
+	}
+	for name, want := range map[string]ParameterDescriptor{
+		"title":          {Name: "title", Type: "string", Required: true},
+		"type":           {Name: "type", Type: "string", Values: []string{"episode", "movie", "series"}},
+		"page":           {Name: "page", Type: "integer"},
+		"require_poster": {Name: "require_poster", Type: "boolean"},
+	} {
+		if p, ok := params[name]; !ok || !reflect.DeepEqual(*p, want) {
+			t.Errorf("got %+v for %s, want %+v", p, name, want)
+		}
+	}
+	if n := omdb.calls(); n != 0 {
+		t.Errorf("got %d calls to OMDb, want none", n)
+	}
+}
+
+func TestDescribeSearchAllowedTypes(t *testing.T) {
+	s := newTestApp(t, newFakeOMDb(t, pagedResults(1)), Config{AllowedTypes: []string{"series", "movie"}, BasePath: "/omdb"})
+
+	d, _ := describe(t, s, "/omdb/search")
+	if d.Path != "/omdb/search" {
+		t.Errorf("got path %q, want it under the base path", d.Path)
+	}
+	for _, p := range d.Parameters {
+		if p.Name == "type" && !reflect.DeepEqual(p.Values, []string{"movie", "series"}) {
+			t.Errorf("got types %v, want only the allowed ones", p.Values)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// SetAllowedOrigins only allows browsers to POST from origins, e.g.
+// https://example.com, as a basic protection against cross-site requests
+// using up the quota. Requests without an Origin or Referer header, which
+// browsers always send with a cross-site POST, are allowed. The check is
+// disabled if origins is empty.
+func (s *SearchApp) SetAllowedOrigins(origins []string) error {
+	if len(origins) == 0 {
+		s.allowedOrigins = nil
+		return nil
+	}
+
+	s.allowedOrigins = make(map[string]bool, len(origins))
+	for _, o := range origins {
+		u, err := url.Parse(strings.TrimSpace(o))
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("invalid origin %q, must be a scheme and host, e.g. https://example.com", o)
+		}
+		s.allowedOrigins[originOf(u)] = true
+	}
+	return nil
+}
+
+// originOf returns the origin of u, its scheme and host.
+func originOf(u *url.URL) string {
+	return strings.ToLower(u.Scheme + "://" + u.Host)
+}
+
+// requestOrigin returns the origin that r was sent from, taken from the Origin
+// header or else the Referer header, and false if it has neither.
+func requestOrigin(r *http.Request) (string, bool) {
+	if o := r.Header.Get("Origin"); o != "" {
+		// Browsers send an Origin of null for privacy-sensitive contexts,
+		// which can't match any allowed origin.
+		if u, err := url.Parse(o); err == nil && u.Scheme != "" && u.Host != "" {
+			return originOf(u), true
+		}
+		return o, true
+	}
+
+	if ref := r.Header.Get("Referer"); ref != "" {
+		if u, err := url.Parse(ref); err == nil && u.Scheme != "" && u.Host != "" {
+			return originOf(u), true
+		}
+		return ref, true
+	}
+
+	return "", false
+}
+
+// checkOrigin responds to POSTs from an origin that isn't allowed with a 403,
+// and passes everything else on to next.
+func (s *SearchApp) checkOrigin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" {
+			if o, ok := requestOrigin(r); ok && !s.allowedOrigins[o] {
+				http.Error(w, "origin is not allowed", http.StatusForbidden)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
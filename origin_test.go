@@ -0,0 +1,66 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAllowedOrigins(t *testing.T) {
+	omdb := newFakeOMDb(t, pagedResults(1))
+	s := newTestApp(t, omdb, Config{AllowedOrigins: []string{"https://Example.com", "http://localhost:8080"}})
+
+	for _, test := range []struct {
+		name            string
+		origin, referer string
+		want            int
+	}{
+		{"no headers", "", "", http.StatusOK},
+		{"allowed origin", "https://example.com", "", http.StatusOK},
+		{"allowed origin with a port", "http://localhost:8080", "", http.StatusOK},
+		{"other origin", "https://evil.example", "", http.StatusForbidden},
+		{"other port", "http://localhost:9090", "", http.StatusForbidden},
+		{"other scheme", "http://example.com", "", http.StatusForbidden},
+		{"null origin", "null", "", http.StatusForbidden},
+		{"allowed referer", "", "https://example.com/search?q=alien", http.StatusOK},
+		{"other referer", "", "https://evil.example/page", http.StatusForbidden},
+		{"origin over referer", "https://evil.example", "https://example.com/", http.StatusForbidden},
+	} {
+		req := newSearchRequest(`{"title":"alien"}`)
+		if test.origin != "" {
+			req.Header.Set("Origin", test.origin)
+		}
+		if test.referer != "" {
+			req.Header.Set("Referer", test.referer)
+		}
+		if w := serveRequest(s, req); w.Code != test.want {
+			t.Errorf("%s: got status %d, want %d", test.name, w.Code, test.want)
+		}
+	}
+
+	// Only POSTs are checked.
+	req := httptest.NewRequest("GET", "/config.json", nil)
+	req.Header.Set("Origin", "https://evil.example")
+	if w := serveRequest(s, req); w.Code != http.StatusOK {
+		t.Errorf("got status %d for a GET, want 200", w.Code)
+	}
+}
+
+func TestAllowedOriginsDisabled(t *testing.T) {
+	s := newTestApp(t, newFakeOMDb(t, pagedResults(1)), Config{})
+
+	req := newSearchRequest(`{"title":"alien"}`)
+	req.Header.Set("Origin", "https://evil.example")
+	if w := serveRequest(s, req); w.Code != http.StatusOK {
+		t.Errorf("got status %d, want 200", w.Code)
+	}
+}
+
+func TestSetAllowedOrigins(t *testing.T) {
+	for _, origin := range []string{"example.com", "https://", "://example.com"} {
+		var s SearchApp
+		if err := s.SetAllowedOrigins([]string{origin}); err == nil {
+			t.Errorf("%q: got no error", origin)
+		}
+	}
+}
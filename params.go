@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// The ways that query parameters given more than once, e.g.
+// ?title=a&title=b, are handled by the GET endpoints.
+const (
+	// DuplicateReject responds with a 400, so it's never ambiguous which
+	// value was used.
+	DuplicateReject = "reject"
+
+	// DuplicateFirst uses the first value.
+	DuplicateFirst = "first"
+
+	// DuplicateLast uses the last value.
+	DuplicateLast = "last"
+)
+
+// validDuplicateParams returns an error if d isn't a way of handling duplicate
+// query parameters.
+func validDuplicateParams(d string) error {
+	switch d {
+	case DuplicateReject, DuplicateFirst, DuplicateLast:
+		return nil
+	}
+	return fmt.Errorf("unsupported duplicate parameter handling %q, must be one of %s, %s or %s", d, DuplicateReject, DuplicateFirst, DuplicateLast)
+}
+
+// query returns the query parameters of r with a single value for each, as
+// configured for duplicate parameters. In the DuplicateReject mode, an
+// error naming a duplicate parameter is returned instead.
+func (s *SearchApp) query(r *http.Request) (url.Values, error) {
+	q := r.URL.Query()
+	for name, values := range q {
+		if len(values) < 2 {
+			continue
+		}
+
+		switch s.duplicateParams {
+		case DuplicateFirst:
+			q[name] = values[:1]
+		case DuplicateLast:
+			q[name] = values[len(values)-1:]
+		default:
+			return nil, fmt.Errorf("the %s query parameter must only be given once", name)
+		}
+	}
+	return q, nil
+}
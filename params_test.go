@@ -0,0 +1,46 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestDuplicateParams(t *testing.T) {
+	for _, test := range []struct {
+		mode   string
+		status int
+		title  string
+	}{
+		{"", http.StatusBadRequest, ""},
+		{DuplicateReject, http.StatusBadRequest, ""},
+		{DuplicateFirst, http.StatusOK, "alien"},
+		{DuplicateLast, http.StatusOK, "aliens"},
+	} {
+		omdb := newFakeOMDb(t, pagedResults(1))
+		s := newTestApp(t, omdb, Config{DuplicateParams: test.mode})
+
+		w := send(s, "GET", "/export?title=alien&type=movie&title=aliens", "")
+		if w.Code != test.status {
+			t.Errorf("%q: got status %d, want %d", test.mode, w.Code, test.status)
+		}
+		if test.status == http.StatusBadRequest {
+			if !strings.Contains(w.Body.String(), "the title query parameter must only be given once") {
+				t.Errorf("%q: got %q, want the duplicate parameter", test.mode, w.Body)
+			}
+			if n := omdb.calls(); n != 0 {
+				t.Errorf("%q: got %d calls to OMDb, want none", test.mode, n)
+			}
+			continue
+		}
+		if got := omdb.query(0).Get("s"); got != test.title {
+			t.Errorf("%q: searched for %q, want %q", test.mode, got, test.title)
+		}
+	}
+}
+
+func TestDuplicateParamsConfig(t *testing.T) {
+	if _, err := NewSearchAppWithConfig(Config{Key: testKey, DuplicateParams: "merge"}); err == nil {
+		t.Error("got no error for an unsupported mode")
+	}
+}
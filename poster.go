@@ -0,0 +1,145 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// defaultPosterHosts are the hosts that the poster proxy fetches images from
+// by default, the ones that OMDb's poster URLs point to.
+var defaultPosterHosts = []string{
+	"m.media-amazon.com",
+	"images-na.ssl-images-amazon.com",
+	"ia.media-imdb.com",
+	"img.omdbapi.com",
+}
+
+// maxPosterRedirects is the number of redirects followed when fetching a
+// poster, the same as the default for an http.Client.
+const maxPosterRedirects = 10
+
+// posterClient is the client used to fetch poster images.
+var posterClient = &http.Client{Timeout: 10 * time.Second}
+
+// SetPosterHosts only lets the poster proxy fetch images from hosts, e.g.
+// m.media-amazon.com. The proxy must not fetch from anywhere else, or it
+// would be an open proxy, so a host must be a bare hostname, and is only
+// matched exactly: a subdomain or another port isn't allowed.
+func (s *SearchApp) SetPosterHosts(hosts []string) error {
+	if len(hosts) == 0 {
+		return errors.New("at least one poster host is required")
+	}
+
+	s.posterHosts = make(map[string]bool, len(hosts))
+	for _, host := range hosts {
+		host = strings.ToLower(strings.TrimSpace(host))
+		if host == "" || strings.ContainsAny(host, ":/@?# ") {
+			return fmt.Errorf("invalid poster host %q, must be a hostname", host)
+		}
+		s.posterHosts[host] = true
+	}
+	return nil
+}
+
+// allowedPosterURL returns true if u is an http(s) URL on one of the poster
+// hosts, on the default port and without userinfo.
+func (s *SearchApp) allowedPosterURL(u *url.URL) bool {
+	return (u.Scheme == "http" || u.Scheme == "https") &&
+		u.User == nil &&
+		s.posterHosts[strings.ToLower(u.Host)]
+}
+
+// fetchPoster sends req with the poster client, only following redirects to
+// the poster hosts.
+func (s *SearchApp) fetchPoster(req *http.Request) (*http.Response, error) {
+	client := *posterClient
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if len(via) >= maxPosterRedirects {
+			return fmt.Errorf("stopped after %d redirects", maxPosterRedirects)
+		}
+		if !s.allowedPosterURL(req.URL) {
+			return errors.New("poster redirected to a host that isn't allowed")
+		}
+		return nil
+	}
+	return client.Do(req)
+}
+
+// notModified returns true if the If-Modified-Since header of r is no earlier
+// than lastModified.
+func notModified(r *http.Request, lastModified string) bool {
+	ims, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+	lm, err := http.ParseTime(lastModified)
+	if err != nil {
+		return false
+	}
+	return !lm.After(ims)
+}
+
+// Poster handles requests to /poster, proxying the poster image in the url
+// query parameter. The upstream Last-Modified header is passed through, and
+// If-Modified-Since requests get a 304 when the image hasn't changed.
+func (s *SearchApp) Poster(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" && r.Method != "HEAD" {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	q, err := s.query(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	u, err := url.Parse(q.Get("url"))
+	if err != nil || !s.allowedPosterURL(u) {
+		http.Error(w, "poster URL is not allowed", http.StatusForbidden)
+		return
+	}
+
+	req, err := http.NewRequest(r.Method, u.String(), nil)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if ims := r.Header.Get("If-Modified-Since"); ims != "" {
+		req.Header.Set("If-Modified-Since", ims)
+	}
+
+	resp, err := s.fetchPoster(req.WithContext(r.Context()))
+	if err != nil {
+		http.Error(w, "poster could not be fetched", http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+
+	lastModified := resp.Header.Get("Last-Modified")
+	if lastModified != "" {
+		w.Header().Set("Last-Modified", lastModified)
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusNotModified, resp.StatusCode == http.StatusOK && notModified(r, lastModified):
+		w.WriteHeader(http.StatusNotModified)
+		return
+	case resp.StatusCode != http.StatusOK:
+		http.Error(w, "poster could not be fetched", http.StatusBadGateway)
+		return
+	}
+
+	for _, h := range []string{"Content-Type", "Content-Length", "Cache-Control"} {
+		if v := resp.Header.Get(h); v != "" {
+			w.Header().Set(h, v)
+		}
+	}
+	io.Copy(w, resp.Body)
+}
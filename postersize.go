@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// maxPosterWidth is the largest poster width that can be asked for.
+const maxPosterWidth = 2000
+
+// amazonPosterPattern matches the poster URLs that OMDb returns for images
+// hosted by Amazon, e.g.
+//
+//	https://m.media-amazon.com/images/M/MV5B...@._V1_SX300.jpg
+//
+// The part between ._V1_ and the extension controls how the image is scaled.
+var amazonPosterPattern = regexp.MustCompile(`^(https?://[^/]+/images/M/[^/]+?)\._V1_[A-Za-z0-9,_]*(\.[A-Za-z]+)$`)
+
+// ResizePoster returns the poster URL u rewritten to ask for an image scaled to
+// width pixels wide. URLs that aren't in the format of an Amazon-hosted
+// poster, like N/A, are returned unchanged.
+func ResizePoster(u string, width int) string {
+	if width <= 0 || !amazonPosterPattern.MatchString(u) {
+		return u
+	}
+	return amazonPosterPattern.ReplaceAllString(u, fmt.Sprintf("${1}._V1_SX%d${2}", width))
+}
+
+// resizePosters returns copies of results with their poster URLs rewritten by
+// ResizePoster.
+func resizePosters(results []*SearchResult, width int) []*SearchResult {
+	resized := make([]*SearchResult, len(results))
+	for i, r := range results {
+		c := *r
+		c.Poster = ResizePoster(r.Poster, width)
+		resized[i] = &c
+	}
+	return resized
+}
+
+// resizeDetailPosters is resizePosters for details. They may be cached, so
+// they're copied rather than changed in place.
+func resizeDetailPosters(details []*Detail, width int) []*Detail {
+	resized := make([]*Detail, len(details))
+	for i, d := range details {
+		c := *d
+		c.Poster = ResizePoster(d.Poster, width)
+		resized[i] = &c
+	}
+	return resized
+}
+
+// ValidatePosterWidth returns an error wrapping ErrInvalidPosterWidth if r asks
+// for a poster width that's out of range.
+func ValidatePosterWidth(r *SearchRequest) error {
+	if r.PosterWidth < 0 || r.PosterWidth > maxPosterWidth {
+		return fmt.Errorf("%w: %d", ErrInvalidPosterWidth, r.PosterWidth)
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestResizePoster(t *testing.T) {
+	for _, test := range []struct {
+		u     string
+		width int
+		want  string
+	}{
+		{
+			"https://m.media-amazon.com/images/M/MV5BNzQz@._V1_SX300.jpg", 500,
+			"https://m.media-amazon.com/images/M/MV5BNzQz@._V1_SX500.jpg",
+		},
+		{
+			"https://m.media-amazon.com/images/M/MV5BNzQz@._V1_UX182_CR0,0,182,268_AL_.png", 100,
+			"https://m.media-amazon.com/images/M/MV5BNzQz@._V1_SX100.png",
+		},
+		{
+			"https://m.media-amazon.com/images/M/MV5BNzQz._V1_.jpg", 300,
+			"https://m.media-amazon.com/images/M/MV5BNzQz._V1_SX300.jpg",
+		},
+		{"https://m.media-amazon.com/images/M/MV5BNzQz@._V1_SX300.jpg", 0, "https://m.media-amazon.com/images/M/MV5BNzQz@._V1_SX300.jpg"},
+		{"https://example.com/poster.jpg", 500, "https://example.com/poster.jpg"},
+		{"N/A", 500, "N/A"},
+		{"", 500, ""},
+	} {
+		if got := ResizePoster(test.u, test.width); got != test.want {
+			t.Errorf("ResizePoster(%q, %d) = %q, want %q", test.u, test.width, got, test.want)
+		}
+	}
+}
+
+func TestValidatePosterWidth(t *testing.T) {
+	for width, valid := range map[int]bool{-1: false, 0: true, 300: true, maxPosterWidth: true, maxPosterWidth + 1: false} {
+		err := ValidatePosterWidth(&SearchRequest{Title: "alien", PosterWidth: width})
+		if valid != (err == nil) || (err != nil && !errors.Is(err, ErrInvalidPosterWidth)) {
+			t.Errorf("width %d: got error %v, want valid %t", width, err, valid)
+		}
+	}
+}
+
+func TestSearchPosterWidth(t *testing.T) {
+	poster := "https://m.media-amazon.com/images/M/MV5BNzQz@._V1_SX300.jpg"
+	omdb := newFakeOMDb(t, func(w http.ResponseWriter, r *http.Request) {
+		writeResults(w, 2,
+			&SearchResult{Title: "Alien", IMDBID: "tt1", Poster: poster},
+			&SearchResult{Title: "Aliens", IMDBID: "tt2", Poster: "N/A"},
+		)
+	})
+	s := newTestApp(t, omdb, Config{})
+
+	results := decodeResults(t, serveRequest(s, newSearchRequest(`{"title":"alien","poster_width":600}`)))
+	if len(results) != 2 || !strings.HasSuffix(results[0].Poster, "._V1_SX600.jpg") || results[1].Poster != "N/A" {
+		t.Errorf("got %+v, want the poster resized", results)
+	}
+	results = decodeResults(t, serveRequest(s, newSearchRequest(`{"title":"alien"}`)))
+	if results[0].Poster != poster {
+		t.Errorf("got poster %q, want it unchanged by default", results[0].Poster)
+	}
+
+	w := serveRequest(s, newSearchRequest(`{"title":"alien","poster_width":5000}`))
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("got status %d for a width that's too large, want 422", w.Code)
+	}
+}
@@ -0,0 +1,17 @@
+package main
+
+import (
+	"net/http/pprof"
+)
+
+// EnablePprof registers the net/http/pprof handlers under /debug/pprof/ on the
+// mux. They expose the command line, which can include the API key, and allow
+// expensive profiles to be taken on demand, so like the /admin endpoints,
+// requests to them must include token as a bearer token.
+func (s *SearchApp) EnablePprof(token string) {
+	s.mux.HandleFunc("/debug/pprof/", s.requireToken(token, pprof.Index))
+	s.mux.HandleFunc("/debug/pprof/cmdline", s.requireToken(token, pprof.Cmdline))
+	s.mux.HandleFunc("/debug/pprof/profile", s.requireToken(token, pprof.Profile))
+	s.mux.HandleFunc("/debug/pprof/symbol", s.requireToken(token, pprof.Symbol))
+	s.mux.HandleFunc("/debug/pprof/trace", s.requireToken(token, pprof.Trace))
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// The keys recognized in the query syntax.
+const (
+	QueryType = "type"
+	QueryYear = "year"
+	QueryPage = "page"
+)
+
+// queryToken is a single whitespace separated token of a query. Quoted is
+// true if the whole token was in quotes, so it's part of the title even if it
+// looks like a key:value pair.
+type queryToken struct {
+	text   string
+	quoted bool
+}
+
+// tokenizeQuery splits q on whitespace that isn't in double quotes, removing
+// the quotes. An unclosed quote runs to the end of q.
+func tokenizeQuery(q string) []queryToken {
+	var (
+		tokens  []queryToken
+		current strings.Builder
+		inQuote bool
+		quoted  bool
+		started bool
+	)
+
+	for _, r := range q {
+		switch {
+		case r == '"':
+			if !started {
+				quoted = true
+			}
+			inQuote = !inQuote
+			started = true
+		case unicode.IsSpace(r) && !inQuote:
+			if started {
+				tokens = append(tokens, queryToken{text: current.String(), quoted: quoted})
+			}
+			current.Reset()
+			quoted, started = false, false
+		default:
+			current.WriteRune(r)
+			started = true
+		}
+	}
+	if started {
+		tokens = append(tokens, queryToken{text: current.String(), quoted: quoted})
+	}
+	return tokens
+}
+
+// ParseQuery splits q, e.g. `type:movie year:1999 the matrix`, into the title
+// and the values of the recognized keys. Tokens with unknown keys, or without
+// a value, are part of the title, so titles like "Star Trek: Voyager" are
+// left alone. Quotes group words into a single value, e.g.
+// `type:"movie"`, or make a token part of the title, e.g. `"type:movie"`.
+// Later keys override earlier ones. The values aren't validated.
+func ParseQuery(q string) (string, map[string]string) {
+	var title []string
+	fields := make(map[string]string)
+
+	for _, t := range tokenizeQuery(q) {
+		if !t.quoted {
+			if i := strings.Index(t.text, ":"); i > 0 && i < len(t.text)-1 {
+				key := strings.ToLower(t.text[:i])
+				switch key {
+				case QueryType, QueryYear, QueryPage:
+					fields[key] = t.text[i+1:]
+					continue
+				}
+			}
+		}
+		if t.text != "" {
+			title = append(title, t.text)
+		}
+	}
+
+	return strings.Join(title, " "), fields
+}
+
+// applyQuery is the RequestMiddleware for the query syntax. It replaces the
+// title of sr with the title parsed from it, and sets the fields named by
+// its keys, unless they were already set in the request. A page that isn't a
+// number is set to -1, so that it fails validation.
+func applyQuery(r *http.Request, sr *SearchRequest) {
+	title, fields := ParseQuery(sr.Title)
+	sr.Title = title
+
+	if v, ok := fields[QueryType]; ok && sr.Type == "" {
+		sr.Type = strings.ToLower(v)
+	}
+	if v, ok := fields[QueryYear]; ok && sr.ReleaseYear == "" {
+		sr.ReleaseYear = v
+	}
+	if v, ok := fields[QueryPage]; ok && sr.Page == 0 {
+		page, err := strconv.Atoi(v)
+		if err != nil {
+			page = -1
+		}
+		sr.Page = page
+	}
+}
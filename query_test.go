@@ -0,0 +1,76 @@
+package main
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestParseQuery(t *testing.T) {
+	for _, test := range []struct {
+		q      string
+		title  string
+		fields map[string]string
+	}{
+		{"the matrix", "the matrix", map[string]string{}},
+		{"type:movie year:1999 the matrix", "the matrix", map[string]string{"type": "movie", "year": "1999"}},
+		{"the matrix TYPE:movie", "the matrix", map[string]string{"type": "movie"}},
+		{"Star Trek: Voyager", "Star Trek: Voyager", map[string]string{}},
+		{"director:nolan inception", "director:nolan inception", map[string]string{}},
+		{"year: 1999 matrix", "year: 1999 matrix", map[string]string{}},
+		{`type:"movie" matrix`, "matrix", map[string]string{"type": "movie"}},
+		{`"type:movie" matrix`, "type:movie matrix", map[string]string{}},
+		{`"the matrix" page:2`, "the matrix", map[string]string{"page": "2"}},
+		{`"the matrix`, "the matrix", map[string]string{}},
+		{"type:movie type:series lost", "lost", map[string]string{"type": "series"}},
+		{`  matrix   "" `, "matrix", map[string]string{}},
+	} {
+		title, fields := ParseQuery(test.q)
+		if title != test.title || !reflect.DeepEqual(fields, test.fields) {
+			t.Errorf("ParseQuery(%q) = %q, %v, want %q, %v", test.q, title, fields, test.title, test.fields)
+		}
+	}
+}
+
+func TestSearchQuerySyntax(t *testing.T) {
+	omdb := newFakeOMDb(t, pagedResults(20))
+	s := newTestApp(t, omdb, Config{QuerySyntax: true})
+
+	for i, test := range []struct {
+		body      string
+		s, typ, y string
+		page      string
+	}{
+		{`{"title":"type:Movie year:1999 the matrix page:2"}`, "the matrix", "movie", "1999", "2"},
+		// The fields of the request take precedence.
+		{`{"title":"type:movie matrix","type":"series"}`, "matrix", "series", "", ""},
+	} {
+		if w := serveRequest(s, newSearchRequest(test.body)); w.Code != http.StatusOK {
+			t.Fatalf("%s: got status %d, want 200: %s", test.body, w.Code, w.Body)
+		}
+		q := omdb.query(i)
+		if q.Get("s") != test.s || q.Get("type") != test.typ || q.Get("y") != test.y || q.Get("page") != test.page {
+			t.Errorf("%s: got query %v", test.body, q)
+		}
+	}
+
+	// The values are validated like those of the request.
+	for _, body := range []string{`{"title":"matrix page:two"}`, `{"title":"matrix type:film"}`, `{"title":"type:movie"}`} {
+		if w := serveRequest(s, newSearchRequest(body)); w.Code != http.StatusUnprocessableEntity {
+			t.Errorf("%s: got status %d, want 422", body, w.Code)
+		}
+	}
+	if n := omdb.calls(); n != 2 {
+		t.Errorf("got %d calls to OMDb, want 2", n)
+	}
+}
+
+func TestSearchQuerySyntaxDisabled(t *testing.T) {
+	omdb := newFakeOMDb(t, pagedResults(1))
+	s := newTestApp(t, omdb, Config{})
+
+	serveRequest(s, newSearchRequest(`{"title":"type:movie matrix"}`))
+	if q := omdb.query(0); q.Get("s") != "type:movie matrix" || q.Has("type") {
+		t.Errorf("got query %v, want the title as is", q)
+	}
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// defaultDailyQuota is the daily request limit of a free OMDb API key.
+const defaultDailyQuota = 1000
+
+// QuotaTracker counts the upstream calls made since the daily quota last
+// reset, to estimate how much of it is left. It's only an estimate: OMDb
+// doesn't report usage, other processes may share the key, and the count
+// starts from zero whenever the process restarts.
+type QuotaTracker struct {
+	limit     int
+	resetHour int
+	clock     Clock
+
+	mu          sync.Mutex
+	count       int
+	periodStart time.Time
+}
+
+// NewQuotaTracker returns a *QuotaTracker for a daily limit that resets at
+// resetHour o'clock UTC.
+func NewQuotaTracker(limit, resetHour int) *QuotaTracker {
+	return &QuotaTracker{
+		limit:     limit,
+		resetHour: resetHour,
+		clock:     RealClock,
+	}
+}
+
+// SetClock makes q read the time from c.
+func (q *QuotaTracker) SetClock(c Clock) {
+	if q == nil {
+		return
+	}
+
+	q.mu.Lock()
+	q.clock = c
+	q.mu.Unlock()
+}
+
+// periodStartAt returns the time of the most recent reset at or before now.
+func (q *QuotaTracker) periodStartAt(now time.Time) time.Time {
+	now = now.UTC()
+	start := time.Date(now.Year(), now.Month(), now.Day(), q.resetHour, 0, 0, 0, time.UTC)
+	if start.After(now) {
+		start = start.AddDate(0, 0, -1)
+	}
+	return start
+}
+
+// untilReset returns how long it is until the quota next resets. A nil q
+// resets at midnight UTC.
+func (q *QuotaTracker) untilReset() time.Duration {
+	if q == nil {
+		q = NewQuotaTracker(defaultDailyQuota, 0)
+	}
+
+	q.mu.Lock()
+	now := q.clock.Now()
+	q.mu.Unlock()
+	return q.periodStartAt(now).AddDate(0, 0, 1).Sub(now)
+}
+
+// roll resets the count if the quota has reset since the last call. The
+// caller must hold q.mu.
+func (q *QuotaTracker) roll(now time.Time) {
+	if start := q.periodStartAt(now); !start.Equal(q.periodStart) {
+		q.periodStart = start
+		q.count = 0
+	}
+}
+
+// Record counts a single upstream call.
+func (q *QuotaTracker) Record() {
+	if q == nil {
+		return
+	}
+
+	q.mu.Lock()
+	q.roll(q.clock.Now())
+	q.count++
+	q.mu.Unlock()
+}
+
+// Used returns the number of upstream calls counted since the last reset.
+func (q *QuotaTracker) Used() int {
+	if q == nil {
+		return 0
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.roll(q.clock.Now())
+	return q.count
+}
+
+// Remaining returns the estimated number of calls left before the limit is
+// reached, which is never less than zero.
+func (q *QuotaTracker) Remaining() int {
+	if q == nil {
+		return 0
+	}
+
+	if remaining := q.limit - q.Used(); remaining > 0 {
+		return remaining
+	}
+	return 0
+}
+
+// RemainingQuota returns the estimated number of calls left today for the
+// OMDBAPI's key. See QuotaTracker for why it's only an estimate.
+func (o *OMDBAPI) RemainingQuota() int {
+	return o.quota.Remaining()
+}
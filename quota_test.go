@@ -0,0 +1,101 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestQuotaTracker(t *testing.T) {
+	clock := NewFakeClock(time.Date(2024, 5, 1, 22, 0, 0, 0, time.UTC))
+	q := NewQuotaTracker(3, 23)
+	q.SetClock(clock)
+
+	if got := q.Remaining(); got != 3 {
+		t.Errorf("got %d remaining, want 3", got)
+	}
+	for range 4 {
+		q.Record()
+	}
+	if used, remaining := q.Used(), q.Remaining(); used != 4 || remaining != 0 {
+		t.Errorf("got %d used and %d remaining, want 4 and 0", used, remaining)
+	}
+
+	// The quota resets at 23:00 UTC rather than midnight.
+	clock.Advance(59 * time.Minute)
+	if got := q.Used(); got != 4 {
+		t.Errorf("got %d used before the reset, want 4", got)
+	}
+	clock.Advance(time.Minute)
+	if got := q.Used(); got != 0 {
+		t.Errorf("got %d used after the reset, want 0", got)
+	}
+	q.Record()
+	if got := q.Remaining(); got != 2 {
+		t.Errorf("got %d remaining, want 2", got)
+	}
+
+	// A day without calls still resets it.
+	clock.Advance(36 * time.Hour)
+	if got := q.Used(); got != 0 {
+		t.Errorf("got %d used a day later, want 0", got)
+	}
+}
+
+func TestQuotaUntilReset(t *testing.T) {
+	for _, test := range []struct {
+		now       time.Time
+		resetHour int
+		want      time.Duration
+	}{
+		{time.Date(2024, 5, 1, 22, 0, 0, 0, time.UTC), 0, 2 * time.Hour},
+		{time.Date(2024, 5, 1, 22, 0, 0, 0, time.UTC), 23, time.Hour},
+		{time.Date(2024, 5, 1, 23, 0, 0, 0, time.UTC), 23, 24 * time.Hour},
+		{time.Date(2024, 5, 1, 8, 30, 0, 0, time.UTC), 6, 21*time.Hour + 30*time.Minute},
+		// The reset hour is in UTC, whatever the time zone of the clock.
+		{time.Date(2024, 5, 1, 22, 0, 0, 0, time.FixedZone("UTC+2", 2*60*60)), 0, 4 * time.Hour},
+	} {
+		q := NewQuotaTracker(defaultDailyQuota, test.resetHour)
+		q.SetClock(NewFakeClock(test.now))
+		if got := q.untilReset(); got != test.want {
+			t.Errorf("%s, resetting at %d: got %s, want %s", test.now, test.resetHour, got, test.want)
+		}
+	}
+}
+
+func TestSearchQuota(t *testing.T) {
+	omdb := newFakeOMDb(t, pagedResults(1))
+	s := newTestApp(t, omdb, Config{DailyQuota: 10, QuotaHeader: true})
+	s.SetClock(NewFakeClock(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)))
+
+	for i := range 3 {
+		w := serveRequest(s, newSearchRequest(`{"title":"alien `+strconv.Itoa(i)+`"}`))
+		if got, want := w.Header().Get("X-Quota-Remaining"), strconv.Itoa(9-i); got != want {
+			t.Errorf("search %d: got X-Quota-Remaining %q, want %q", i, got, want)
+		}
+	}
+
+	w := send(s, "GET", "/metrics", "")
+	if !strings.Contains(w.Body.String(), "omdb_quota_remaining 7\n") {
+		t.Errorf("got metrics %q, want 7 remaining", w.Body)
+	}
+}
+
+func TestSearchQuotaExceeded(t *testing.T) {
+	omdb := newFakeOMDb(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		writeError(w, quotaExceededMessage)
+	})
+	s := newTestApp(t, omdb, Config{QuotaResetHour: 6})
+	s.SetClock(NewFakeClock(time.Date(2024, 5, 1, 5, 59, 30, 0, time.UTC)))
+
+	w := serveRequest(s, newSearchRequest(`{"title":"alien"}`))
+	if w.Code != http.StatusTooManyRequests {
+		t.Errorf("got status %d, want 429", w.Code)
+	}
+	if got := w.Header().Get("Retry-After"); got != "30" {
+		t.Errorf("got Retry-After %q, want the seconds until 06:00 UTC", got)
+	}
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxLimitedClients is the number of clients tracked by a *ClientLimiter
+// before the ones whose buckets have refilled are forgotten.
+const maxLimitedClients = 10000
+
+// clientBucket is the token bucket of a single client.
+type clientBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// ClientLimiter limits the rate of requests from each client IP with a token
+// bucket, so that a single client can't use up the quota that every client
+// shares. Each bucket holds a burst of limit requests and refills at the
+// rate of limit per window.
+type ClientLimiter struct {
+	max  float64
+	rate float64 // tokens per second
+
+	// trustProxy takes the client IP from the X-Forwarded-For header, which
+	// is only safe behind a proxy that sets it.
+	trustProxy bool
+
+	clock Clock
+
+	mu      sync.Mutex
+	buckets map[string]*clientBucket
+}
+
+// NewClientLimiter returns a new *ClientLimiter that allows limit requests
+// per window from each client.
+func NewClientLimiter(limit int, window time.Duration, trustProxy bool) *ClientLimiter {
+	return &ClientLimiter{
+		max:        float64(limit),
+		rate:       float64(limit) / window.Seconds(),
+		trustProxy: trustProxy,
+		clock:      RealClock,
+		buckets:    make(map[string]*clientBucket),
+	}
+}
+
+// Allow takes a request from the bucket for ip. If it's empty, it returns
+// false and how long until it will have a request again.
+func (l *ClientLimiter) Allow(ip string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.clock.Now()
+	b, ok := l.buckets[ip]
+	if !ok {
+		if len(l.buckets) >= maxLimitedClients {
+			l.forget(now)
+		}
+		b = &clientBucket{tokens: l.max, last: now}
+		l.buckets[ip] = b
+	}
+
+	b.tokens = math.Min(l.max, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+	}
+	b.tokens--
+	return true, 0
+}
+
+// forget removes the buckets that would have refilled by now, as they're the
+// same as a new one.
+func (l *ClientLimiter) forget(now time.Time) {
+	for ip, b := range l.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*l.rate >= l.max {
+			delete(l.buckets, ip)
+		}
+	}
+}
+
+// clientIP returns the IP that r was sent from, canonicalized so that each
+// client has one bucket however its address is written. If the proxy is
+// trusted, it's the last address in the X-Forwarded-For header, the one the
+// proxy added, since any before it could have been sent by the client.
+func (l *ClientLimiter) clientIP(r *http.Request) string {
+	if l.trustProxy {
+		if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
+			addrs := strings.Split(xff, ",")
+			if ip := strings.TrimSpace(addrs[len(addrs)-1]); ip != "" {
+				return canonicalIP(ip)
+			}
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return canonicalIP(r.RemoteAddr)
+	}
+	return canonicalIP(host)
+}
+
+// canonicalIP returns ip in its shortest form, e.g. 192.0.2.1 for
+// ::ffff:192.0.2.1 and 2001:db8::1 for 2001:DB8:0::1, or unchanged if it
+// isn't an IP.
+func canonicalIP(ip string) string {
+	if parsed := net.ParseIP(ip); parsed != nil {
+		return parsed.String()
+	}
+	return ip
+}
+
+// limitClients wraps h so that clients over the rate limit get a 429, with a
+// Retry-After header. It does nothing if there isn't a limit. OPTIONS requests
+// aren't limited, as they never call OMDb.
+func (s *SearchApp) limitClients(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.limiter != nil && r.Method != "OPTIONS" {
+			if ok, wait := s.limiter.Allow(s.limiter.clientIP(r)); !ok {
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+				http.Error(w, "too many requests, try again later", http.StatusTooManyRequests)
+				return
+			}
+		}
+		h(w, r)
+	}
+}
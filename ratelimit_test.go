@@ -0,0 +1,120 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestClientLimiter(t *testing.T) {
+	clock := NewFakeClock(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC))
+	l := NewClientLimiter(2, time.Minute, false)
+	l.clock = clock
+
+	for i := range 2 {
+		if ok, _ := l.Allow("192.0.2.1"); !ok {
+			t.Fatalf("request %d: got limited within the burst", i)
+		}
+	}
+	ok, wait := l.Allow("192.0.2.1")
+	if ok || wait != 30*time.Second {
+		t.Errorf("got %t and a wait of %s, want to be limited for 30s", ok, wait)
+	}
+
+	// Each client has its own bucket.
+	if ok, _ := l.Allow("192.0.2.2"); !ok {
+		t.Error("got another client limited")
+	}
+
+	clock.Advance(30 * time.Second)
+	if ok, _ := l.Allow("192.0.2.1"); !ok {
+		t.Error("got limited once the bucket refilled")
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	for _, test := range []struct {
+		trustProxy      bool
+		remoteAddr, xff string
+		want            string
+	}{
+		{false, "192.0.2.1:1234", "", "192.0.2.1"},
+		{false, "192.0.2.1:1234", "198.51.100.1", "192.0.2.1"},
+		{true, "192.0.2.1:1234", "198.51.100.1", "198.51.100.1"},
+		{true, "192.0.2.1:1234", "203.0.113.9, 198.51.100.1", "198.51.100.1"},
+		{true, "192.0.2.1:1234", "", "192.0.2.1"},
+		{false, "[::ffff:192.0.2.1]:1234", "", "192.0.2.1"},
+		{false, "[2001:DB8:0::1]:1234", "", "2001:db8::1"},
+		{false, "not an address", "", "not an address"},
+	} {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = test.remoteAddr
+		if test.xff != "" {
+			r.Header.Set("X-Forwarded-For", test.xff)
+		}
+		l := NewClientLimiter(1, time.Minute, test.trustProxy)
+		if got := l.clientIP(r); got != test.want {
+			t.Errorf("%s with X-Forwarded-For %q, trusted %t: got %q, want %q", test.remoteAddr, test.xff, test.trustProxy, got, test.want)
+		}
+	}
+}
+
+func TestSearchRateLimit(t *testing.T) {
+	omdb := newFakeOMDb(t, pagedResults(1))
+	s := newTestApp(t, omdb, Config{ClientRateLimit: 1, ClientRateWindow: time.Minute})
+	s.SetClock(NewFakeClock(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)))
+
+	if w := serveRequest(s, newSearchRequest(`{"title":"alien"}`)); w.Code != http.StatusOK {
+		t.Fatalf("got status %d, want 200", w.Code)
+	}
+	w := serveRequest(s, newSearchRequest(`{"title":"alien"}`))
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "60" {
+		t.Errorf("got %d with Retry-After %q, want a 429 for 60s", w.Code, w.Header().Get("Retry-After"))
+	}
+
+	// OPTIONS never calls OMDb, so it isn't limited.
+	if w := send(s, "OPTIONS", "/search", ""); w.Code != http.StatusOK {
+		t.Errorf("got status %d for OPTIONS, want 200", w.Code)
+	}
+
+	// The other endpoints that call OMDb share the limit, the rest don't.
+	for target, want := range map[string]int{
+		"/export?title=alien":            http.StatusTooManyRequests,
+		"/compare?left=alien&right=heat": http.StatusTooManyRequests,
+		"/search.rss?title=alien":        http.StatusTooManyRequests,
+		"/poster?url=x":                  http.StatusTooManyRequests,
+		"/config.json":                   http.StatusOK,
+		"/metrics":                       http.StatusOK,
+	} {
+		if w := send(s, "GET", target, ""); w.Code != want {
+			t.Errorf("%s: got status %d, want %d", target, w.Code, want)
+		}
+	}
+	if w := send(s, "POST", "/search/merge", `{"titles":["alien"]}`); w.Code != http.StatusTooManyRequests {
+		t.Errorf("/search/merge: got status %d, want 429", w.Code)
+	}
+	if n := omdb.calls(); n != 1 {
+		t.Errorf("got %d calls to OMDb, want 1", n)
+	}
+}
+
+func TestSearchRateLimitPerClient(t *testing.T) {
+	omdb := newFakeOMDb(t, pagedResults(1))
+	s := newTestApp(t, omdb, Config{ClientRateLimit: 1, ClientRateWindow: time.Minute, TrustProxy: true})
+
+	for _, test := range []struct {
+		xff  string
+		want int
+	}{
+		{"198.51.100.1", http.StatusOK},
+		{"198.51.100.2", http.StatusOK},
+		{"203.0.113.9, 198.51.100.1", http.StatusTooManyRequests},
+	} {
+		req := newSearchRequest(`{"title":"alien"}`)
+		req.Header.Set("X-Forwarded-For", test.xff)
+		if w := serveRequest(s, req); w.Code != test.want {
+			t.Errorf("%s: got status %d, want %d", test.xff, w.Code, test.want)
+		}
+	}
+}
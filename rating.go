@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// maxRatingHydration is the maximum number of results that have their rating
+// looked up when sorting by rating. Each one costs an upstream call.
+const maxRatingHydration = 10
+
+// detailTTL is how long details are cached for. Ratings change slowly, so
+// they're cached far longer than search results.
+const detailTTL = 24 * time.Hour
+
+// maxCachedDetails is the maximum number of details kept in a *DetailCache.
+const maxCachedDetails = 5000
+
+// detailEntry is a single cached *Detail.
+type detailEntry struct {
+	detail  *Detail
+	expires time.Time
+}
+
+// DetailCache is an in-memory cache of title details keyed by IMDb ID. When
+// it's full, an arbitrary entry is evicted to make room.
+type DetailCache struct {
+	clock Clock
+
+	mu      sync.Mutex
+	entries map[string]*detailEntry
+}
+
+// NewDetailCache returns a new, empty *DetailCache.
+func NewDetailCache() *DetailCache {
+	return &DetailCache{
+		clock:   RealClock,
+		entries: make(map[string]*detailEntry),
+	}
+}
+
+// getDetail returns the *Detail for id from the cache, or from the API if it
+// isn't cached.
+func (s *SearchApp) getDetail(ctx context.Context, id string) (*Detail, error) {
+	c := s.details
+	now := c.clock.Now()
+
+	c.mu.Lock()
+	e, ok := c.entries[id]
+	c.mu.Unlock()
+	if ok && now.Before(e.expires) {
+		return e.detail, nil
+	}
+
+	detail, err := s.searchAPI.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	if len(c.entries) >= maxCachedDetails {
+		for k := range c.entries {
+			delete(c.entries, k)
+			break
+		}
+	}
+	c.entries[id] = &detailEntry{detail: detail, expires: now.Add(detailTTL)}
+	c.mu.Unlock()
+
+	return detail, nil
+}
+
+// parseRating returns the numeric IMDb rating, and false if the title is
+// unrated.
+func parseRating(rating string) (float64, bool) {
+	f, err := strconv.ParseFloat(rating, 64)
+	return f, err == nil
+}
+
+// sortByRating returns copies of results with the IMDb rating of up to
+// maxRatingHydration of them looked up, sorted by descending rating. Unrated
+// results, including ones whose lookup failed or that were beyond the limit,
+// are sorted last in their original order.
+func (s *SearchApp) sortByRating(ctx context.Context, results []*SearchResult) []*SearchResult {
+	rated := make([]*SearchResult, len(results))
+	var wg sync.WaitGroup
+	for i, r := range results {
+		c := *r
+		rated[i] = &c
+
+		if i >= maxRatingHydration {
+			continue
+		}
+
+		wg.Add(1)
+		go func(c *SearchResult) {
+			defer wg.Done()
+			if detail, err := s.getDetail(ctx, c.IMDBID); err == nil {
+				c.IMDBRating = detail.IMDBRating
+			}
+		}(&c)
+	}
+	wg.Wait()
+
+	sort.SliceStable(rated, func(i, j int) bool {
+		a, aok := parseRating(rated[i].IMDBRating)
+		b, bok := parseRating(rated[j].IMDBRating)
+		if aok != bok {
+			return aok
+		}
+		return a > b
+	})
+	return rated
+}
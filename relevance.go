@@ -0,0 +1,88 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// levenshtein returns the edit distance between a and b, counted in runes.
+func levenshtein(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
+
+// tokens returns the set of words in s.
+func tokens(s string) map[string]bool {
+	set := make(map[string]bool)
+	for _, f := range strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	}) {
+		set[f] = true
+	}
+	return set
+}
+
+// relevance returns a score between 0 and 1 for how closely title matches
+// query, with 1 being an exact match, ignoring case and accents, as compared
+// by foldTitle. It's the mean of the normalized Levenshtein similarity of the
+// two strings and the overlap (Jaccard index) of their words.
+func relevance(query, title string) float64 {
+	q := []rune(foldTitle(strings.TrimSpace(query)))
+	t := []rune(foldTitle(strings.TrimSpace(title)))
+
+	longest := max(len(q), len(t))
+	if longest == 0 {
+		return 1
+	}
+	similarity := 1 - float64(levenshtein(q, t))/float64(longest)
+
+	qt, tt := tokens(string(q)), tokens(string(t))
+	var shared int
+	for w := range qt {
+		if tt[w] {
+			shared++
+		}
+	}
+	var overlap float64
+	if union := len(qt) + len(tt) - shared; union > 0 {
+		overlap = float64(shared) / float64(union)
+	}
+
+	return (similarity + overlap) / 2
+}
+
+// scoreResults returns copies of results with their Score set to their
+// relevance to query. The copies are sorted by descending score when
+// sortByScore is true, otherwise the order is unchanged.
+func scoreResults(query string, results []*SearchResult, sortByScore bool) []*SearchResult {
+	scored := make([]*SearchResult, len(results))
+	for i, r := range results {
+		c := *r
+		c.Score = relevance(query, r.Title)
+		scored[i] = &c
+	}
+
+	if sortByScore {
+		sort.SliceStable(scored, func(i, j int) bool {
+			return scored[i].Score > scored[j].Score
+		})
+	}
+	return scored
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestLevenshtein(t *testing.T) {
+	for _, test := range []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"abc", "", 3},
+		{"kitten", "sitting", 3},
+		{"amélie", "amelie", 1},
+	} {
+		if got := levenshtein([]rune(test.a), []rune(test.b)); got != test.want {
+			t.Errorf("levenshtein(%q, %q) = %d, want %d", test.a, test.b, got, test.want)
+		}
+	}
+}
+
+func TestRelevance(t *testing.T) {
+	if got := relevance("The Matrix", "the matrix"); got != 1 {
+		t.Errorf("got %v for an exact match, want 1", got)
+	}
+	if got := relevance("Amelie", "Amélie"); got != 1 {
+		t.Errorf("got %v for a match but for accents, want 1", got)
+	}
+	if got := relevance("", ""); got != 1 {
+		t.Errorf("got %v for two empty titles, want 1", got)
+	}
+
+	best := relevance("The Matrix", "The Matrix Reloaded")
+	worst := relevance("The Matrix", "Goodfellas")
+	if best <= worst {
+		t.Errorf("got %v for the best match, which isn't more than %v for the worst", best, worst)
+	}
+	if worst < 0 || best > 1 {
+		t.Errorf("got scores %v and %v, want them between 0 and 1", worst, best)
+	}
+}
+
+func TestScoreResults(t *testing.T) {
+	results := []*SearchResult{
+		{Title: "Goodfellas", IMDBID: "tt1"},
+		{Title: "The Matrix Reloaded", IMDBID: "tt2"},
+		{Title: "The Matrix", IMDBID: "tt3"},
+	}
+
+	scored := scoreResults("the matrix", results, false)
+	if got := ids(scored); got[0] != "tt1" || got[1] != "tt2" || got[2] != "tt3" {
+		t.Errorf("got %v, want the order unchanged", got)
+	}
+	if scored[2].Score != 1 {
+		t.Errorf("got a score of %v for the exact match, want 1", scored[2].Score)
+	}
+	if results[2].Score != 0 {
+		t.Error("the results passed in were scored")
+	}
+
+	sorted := scoreResults("the matrix", results, true)
+	if got := ids(sorted); got[0] != "tt3" || got[1] != "tt2" || got[2] != "tt1" {
+		t.Errorf("got %v, want tt3, tt2, tt1", got)
+	}
+}
+
+func TestSearchScore(t *testing.T) {
+	omdb := newFakeOMDb(t, func(w http.ResponseWriter, r *http.Request) {
+		writeResults(w, 2,
+			&SearchResult{Title: "Goodfellas", IMDBID: "tt1"},
+			&SearchResult{Title: "The Matrix", IMDBID: "tt2"},
+		)
+	})
+	s := newTestApp(t, omdb, Config{})
+
+	results := decodeResults(t, serveRequest(s, newSearchRequest(`{"title":"the matrix"}`)))
+	for _, r := range results {
+		if r.Score != 0 {
+			t.Errorf("%s has a score of %v without asking for one", r.IMDBID, r.Score)
+		}
+	}
+
+	results = decodeResults(t, serveRequest(s, newSearchRequest(`{"title":"the matrix","sort_by_score":true}`)))
+	if got := ids(results); got[0] != "tt2" || results[0].Score != 1 {
+		t.Errorf("got %v, want tt2 first with a score of 1", got)
+	}
+}
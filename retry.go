@@ -0,0 +1,198 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// The jitter strategies applied to retry delays. Without jitter, clients that
+// failed at the same time retry at the same time, and can knock over an
+// upstream that is recovering.
+const (
+	// JitterNone uses the exponential delay as is.
+	JitterNone = "none"
+
+	// JitterFull uses a random delay between zero and the exponential delay.
+	JitterFull = "full"
+
+	// JitterEqual uses half of the exponential delay plus a random delay
+	// between zero and the other half.
+	JitterEqual = "equal"
+)
+
+// Backoff computes the delays between retries of failed upstream calls. The
+// delay doubles with each attempt, up to a maximum, and then has jitter
+// applied. A nil *Backoff never retries.
+type Backoff struct {
+	Retries   int
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	Jitter    string
+
+	// Budget, if set, caps the retries across all requests.
+	Budget *RetryBudget
+
+	mu   sync.Mutex
+	rand *rand.Rand
+}
+
+// NewBackoff returns a new *Backoff that retries up to retries times, using
+// the given jitter strategy.
+func NewBackoff(retries int, baseDelay, maxDelay time.Duration, jitter string) (*Backoff, error) {
+	switch jitter {
+	case JitterNone, JitterFull, JitterEqual:
+	default:
+		return nil, fmt.Errorf("unsupported jitter %q, must be one of %s, %s or %s", jitter, JitterNone, JitterFull, JitterEqual)
+	}
+
+	return &Backoff{
+		Retries:   retries,
+		BaseDelay: baseDelay,
+		MaxDelay:  maxDelay,
+		Jitter:    jitter,
+		rand:      rand.New(rand.NewSource(time.Now().UnixNano())),
+	}, nil
+}
+
+// retries returns the number of retries to make, which is zero for a nil
+// *Backoff.
+func (b *Backoff) retries() int {
+	if b == nil {
+		return 0
+	}
+	return b.Retries
+}
+
+// allowRetry returns true if there's budget for another retry, taking it if
+// there is.
+func (b *Backoff) allowRetry() bool {
+	return b.Budget == nil || b.Budget.Take()
+}
+
+// Seed resets the random number generator used for jitter, so that the
+// delays are repeatable.
+func (b *Backoff) Seed(seed int64) {
+	b.mu.Lock()
+	b.rand = rand.New(rand.NewSource(seed))
+	b.mu.Unlock()
+}
+
+// Delay returns how long to wait before the retry following the given
+// attempt, which counts from zero.
+func (b *Backoff) Delay(attempt int) time.Duration {
+	d := b.MaxDelay
+	if attempt < 32 {
+		if exp := b.BaseDelay << uint(attempt); exp > 0 && exp < b.MaxDelay {
+			d = exp
+		}
+	}
+
+	if b.Jitter == JitterNone || d <= 0 {
+		return d
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.Jitter == JitterEqual {
+		half := d / 2
+		return half + time.Duration(b.rand.Int63n(int64(d-half)+1))
+	}
+	return time.Duration(b.rand.Int63n(int64(d) + 1))
+}
+
+// RetryBudget is a token bucket that caps the number of retries across all
+// requests within a window, so that retries can't multiply the load on OMDb
+// during an outage. Once it's spent, failures are returned without retrying
+// until it refills. It refills continuously, at the rate of the whole budget
+// per window.
+type RetryBudget struct {
+	max  float64
+	rate float64 // tokens per second
+
+	mu     sync.Mutex
+	clock  Clock
+	tokens float64
+	last   time.Time
+}
+
+// NewRetryBudget returns a new, full *RetryBudget that allows retries retries
+// per window.
+func NewRetryBudget(retries int, window time.Duration) *RetryBudget {
+	return &RetryBudget{
+		max:    float64(retries),
+		rate:   float64(retries) / window.Seconds(),
+		tokens: float64(retries),
+		clock:  RealClock,
+		last:   time.Now(),
+	}
+}
+
+// SetClock makes b read the time from c. The budget refills from the time c
+// reports when it's set.
+func (b *RetryBudget) SetClock(c Clock) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.clock = c
+	b.last = c.Now()
+}
+
+// Take takes a retry from the budget, returning false if there isn't one.
+func (b *RetryBudget) Take() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.clock.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.max {
+		b.tokens = b.max
+	}
+	b.last = now
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// retryable returns true if err is a transient failure that might succeed if
+// the request is made again: a connection error, including one dropped part
+// way through the response, or a 5xx from OMDb.
+func retryable(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode >= 500
+	}
+
+	if errors.Is(err, ErrConnectionDropped) {
+		return true
+	}
+
+	var urlErr *url.Error
+	return errors.As(err, &urlErr)
+}
+
+// sleep waits for d, returning early with the context's error if ctx is done
+// first.
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestBackoffDelay(t *testing.T) {
+	for _, test := range []struct {
+		jitter string
+		min    func(time.Duration) time.Duration
+	}{
+		{JitterNone, func(d time.Duration) time.Duration { return d }},
+		{JitterFull, func(time.Duration) time.Duration { return 0 }},
+		{JitterEqual, func(d time.Duration) time.Duration { return d / 2 }},
+	} {
+		t.Run(test.jitter, func(t *testing.T) {
+			b, err := NewBackoff(5, 100*time.Millisecond, time.Second, test.jitter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			b.Seed(1)
+
+			for attempt, exp := range []time.Duration{
+				100 * time.Millisecond,
+				200 * time.Millisecond,
+				400 * time.Millisecond,
+				800 * time.Millisecond,
+				time.Second,
+				time.Second,
+			} {
+				for range 100 {
+					if d := b.Delay(attempt); d < test.min(exp) || d > exp {
+						t.Fatalf("attempt %d: got a delay of %s, want between %s and %s", attempt, d, test.min(exp), exp)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestBackoffSeed(t *testing.T) {
+	delays := func() []time.Duration {
+		b, err := NewBackoff(3, time.Second, time.Minute, JitterFull)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b.Seed(42)
+		var delays []time.Duration
+		for attempt := range 10 {
+			delays = append(delays, b.Delay(attempt))
+		}
+		return delays
+	}
+
+	first, second := delays(), delays()
+	for i := range first {
+		if first[i] != second[i] {
+			t.Fatalf("got delays %v and %v with the same seed", first, second)
+		}
+	}
+}
+
+func TestNewBackoffJitter(t *testing.T) {
+	if _, err := NewBackoff(1, time.Second, time.Minute, "random"); err == nil {
+		t.Error("got no error for an unsupported jitter")
+	}
+}
+
+func TestRetryable(t *testing.T) {
+	for _, test := range []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"5xx", &StatusError{StatusCode: http.StatusBadGateway}, true},
+		{"4xx", &StatusError{StatusCode: http.StatusNotFound}, false},
+		{"connection error", &url.Error{Op: "Get", Err: errors.New("connection refused")}, true},
+		{"dropped connection", ErrConnectionDropped, true},
+		{"cancelled", &url.Error{Op: "Get", Err: context.Canceled}, false},
+		{"not found", ErrMovieNotFound, false},
+	} {
+		if got := retryable(test.err); got != test.want {
+			t.Errorf("%s: got %t, want %t", test.name, got, test.want)
+		}
+	}
+}
+
+func TestSearchRetries(t *testing.T) {
+	for _, test := range []struct {
+		name     string
+		failures int
+		calls    int
+		ok       bool
+	}{
+		{"recovers", 2, 3, true},
+		{"gives up", 5, 4, false},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			var omdb *fakeOMDb
+			omdb = newFakeOMDb(t, func(w http.ResponseWriter, r *http.Request) {
+				if omdb.calls() <= test.failures {
+					w.WriteHeader(http.StatusServiceUnavailable)
+					return
+				}
+				writeResults(w, 1, resultsFor("tt1")...)
+			})
+			backoff, err := NewBackoff(3, time.Millisecond, time.Millisecond, JitterNone)
+			if err != nil {
+				t.Fatal(err)
+			}
+			api := newTestAPI(t, omdb)
+			api.backoff = backoff
+
+			_, err = api.Search(context.Background(), &SearchRequest{Title: "alien"})
+			if ok := err == nil; ok != test.ok {
+				t.Errorf("got error %v", err)
+			}
+			if n := omdb.calls(); n != test.calls {
+				t.Errorf("got %d calls to OMDb, want %d", n, test.calls)
+			}
+		})
+	}
+}
+
+func TestRetryBudget(t *testing.T) {
+	clock := NewFakeClock(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC))
+	b := NewRetryBudget(3, time.Minute)
+	b.SetClock(clock)
+
+	for i := range 3 {
+		if !b.Take() {
+			t.Fatalf("retry %d: got no budget, want it to start full", i)
+		}
+	}
+	if b.Take() {
+		t.Error("got budget once it was spent")
+	}
+
+	// It refills at 3 a minute, so one retry every 20s.
+	clock.Advance(19 * time.Second)
+	if b.Take() {
+		t.Error("got budget after 19s, want none")
+	}
+	clock.Advance(time.Second)
+	if !b.Take() {
+		t.Error("got no budget after 20s")
+	}
+
+	// It never holds more than the whole budget.
+	clock.Advance(time.Hour)
+	for i := range 3 {
+		if !b.Take() {
+			t.Errorf("retry %d: got no budget after refilling", i)
+		}
+	}
+	if b.Take() {
+		t.Error("got more than the whole budget after an hour")
+	}
+}
+
+func TestSearchRetryBudget(t *testing.T) {
+	omdb := newFakeOMDb(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	s := newTestApp(t, omdb, Config{
+		Retries:       2,
+		RetryDelay:    time.Millisecond,
+		RetryMaxDelay: time.Millisecond,
+		RetryJitter:   JitterNone,
+		RetryBudget:   3,
+	})
+	clock := NewFakeClock(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC))
+	s.SetClock(clock)
+
+	// The first search retries twice, the second only once before the
+	// budget runs out, and the third not at all.
+	for i, want := range []int{3, 5, 6} {
+		serveRequest(s, newSearchRequest(`{"title":"alien `+strconv.Itoa(i)+`"}`))
+		if n := omdb.calls(); n != want {
+			t.Errorf("search %d: got %d calls to OMDb, want %d", i, n, want)
+		}
+	}
+
+	clock.Advance(time.Minute)
+	serveRequest(s, newSearchRequest(`{"title":"alien"}`))
+	if n := omdb.calls(); n != 9 {
+		t.Errorf("got %d calls to OMDb once the budget refilled, want 9", n)
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+)
+
+// imdbFindURL is the prefix of the IMDb web page of the results for a search.
+const imdbFindURL = "https://www.imdb.com/find/?q="
+
+// rssFeed is the root element of an RSS 2.0 document.
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title       string    `xml:"title"`
+	Link        string    `xml:"link"`
+	Description string    `xml:"description"`
+	Items       []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string  `xml:"title"`
+	Link        string  `xml:"link,omitempty"`
+	Description string  `xml:"description,omitempty"`
+	GUID        rssGUID `xml:"guid"`
+}
+
+// rssGUID identifies an item by its IMDb ID, which isn't a URL.
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	ID          string `xml:",chardata"`
+}
+
+// RSSFormatter is a ResultFormatter that writes the results as an RSS 2.0
+// feed for the search for Title, with an item linking to the IMDb page of
+// each result.
+type RSSFormatter struct {
+	Title string
+}
+
+// Format writes results as RSS.
+func (f *RSSFormatter) Format(w http.ResponseWriter, results []*SearchResult) error {
+	feed := &rssFeed{
+		Version: "2.0",
+		Channel: rssChannel{
+			Title:       fmt.Sprintf("OMDb search for %q", f.Title),
+			Link:        imdbFindURL + url.QueryEscape(f.Title),
+			Description: fmt.Sprintf("The movies, series and episodes matching %q.", f.Title),
+		},
+	}
+	for _, r := range results {
+		title := r.Title
+		if r.Year != "" {
+			title = fmt.Sprintf("%s (%s)", r.Title, r.Year)
+		}
+		feed.Channel.Items = append(feed.Channel.Items, rssItem{
+			Title:       title,
+			Link:        r.IMDBURL(),
+			Description: r.Type,
+			GUID:        rssGUID{ID: r.IMDBID},
+		})
+	}
+
+	b, err := xml.MarshalIndent(feed, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	w.Header().Set("Content-Type", "application/rss+xml; charset=utf-8")
+	if _, err = w.Write([]byte(xml.Header)); err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+// SearchRSS handles requests to /search.rss. It searches for the title, type
+// and year query parameters and responds with the first page of results as
+// an RSS feed, so that a feed reader can follow a search. A search that
+// matches nothing is an empty feed rather than an error.
+func (s *SearchApp) SearchRSS(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		http.NotFound(w, r)
+		return
+	}
+
+	q, err := s.query(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	searchRequest := NewSearchRequest(q.Get("title"))
+	searchRequest.Type = q.Get("type")
+	searchRequest.ReleaseYear = q.Get("year")
+	s.applyMiddleware(r, searchRequest)
+
+	if err := s.validate(searchRequest); err != nil {
+		s.searchError(w, r, searchRequest, err)
+		return
+	}
+
+	if !s.typeAllowed(searchRequest.Type) {
+		s.searchError(w, r, searchRequest, fmt.Errorf("%w: %q", ErrTypeNotAllowed, searchRequest.Type))
+		return
+	}
+
+	results, err := s.search(r.Context(), searchRequest)
+	if err != nil && !errors.Is(err, ErrMovieNotFound) {
+		s.searchError(w, r, searchRequest, err)
+		return
+	}
+
+	if s.allowedTypes != nil {
+		results = filterResults(results, func(sr *SearchResult) bool {
+			return s.typeAllowed(sr.Type)
+		})
+	}
+
+	f := &RSSFormatter{Title: searchRequest.Title}
+	if err := f.Format(w, results); err != nil {
+		log.Printf("error writing RSS feed for %q: %s", searchRequest.Title, err)
+	}
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// getFeed requests target from s and decodes the RSS feed.
+func getFeed(t *testing.T, s *SearchApp, target string) *rssFeed {
+	t.Helper()
+	w := send(s, "GET", target, "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("got status %d, want 200: %s", w.Code, w.Body)
+	}
+	if got := w.Header().Get("Content-Type"); got != "application/rss+xml; charset=utf-8" {
+		t.Errorf("got Content-Type %q, want RSS", got)
+	}
+	if !strings.HasPrefix(w.Body.String(), xml.Header) {
+		t.Errorf("got body %q, want an XML declaration", w.Body)
+	}
+
+	var feed rssFeed
+	if err := xml.Unmarshal(w.Body.Bytes(), &feed); err != nil {
+		t.Fatalf("got invalid XML: %s", err)
+	}
+	return &feed
+}
+
+func TestSearchRSS(t *testing.T) {
+	omdb := newFakeOMDb(t, pagedResults(2))
+	s := newTestApp(t, omdb, Config{})
+
+	feed := getFeed(t, s, "/search.rss?title=alien+%26+co&type=movie&year=1999")
+	if feed.Version != "2.0" {
+		t.Errorf("got version %q, want 2.0", feed.Version)
+	}
+	if got, want := feed.Channel.Title, `OMDb search for "alien & co"`; got != want {
+		t.Errorf("got channel title %q, want %q", got, want)
+	}
+	if got, want := feed.Channel.Link, imdbFindURL+"alien+%26+co"; got != want {
+		t.Errorf("got channel link %q, want %q", got, want)
+	}
+
+	want := []rssItem{
+		{Title: "Title tt1 (1999)", Link: "https://www.imdb.com/title/tt1/", Description: "movie", GUID: rssGUID{ID: "tt1"}},
+		{Title: "Title tt2 (1999)", Link: "https://www.imdb.com/title/tt2/", Description: "movie", GUID: rssGUID{ID: "tt2"}},
+	}
+	if len(feed.Channel.Items) != len(want) {
+		t.Fatalf("got items %+v, want %+v", feed.Channel.Items, want)
+	}
+	for i, item := range feed.Channel.Items {
+		if item != want[i] {
+			t.Errorf("item %d: got %+v, want %+v", i, item, want[i])
+		}
+	}
+
+	q := omdb.query(0)
+	if q.Get("s") != "alien & co" || q.Get("type") != "movie" || q.Get("y") != "1999" {
+		t.Errorf("got query %v, want the title, type and year", q)
+	}
+}
+
+func TestSearchRSSEmpty(t *testing.T) {
+	s := newTestApp(t, newFakeOMDb(t, pagedResults(0)), Config{})
+
+	feed := getFeed(t, s, "/search.rss?title=nothing")
+	if len(feed.Channel.Items) != 0 {
+		t.Errorf("got items %+v, want none", feed.Channel.Items)
+	}
+	if feed.Channel.Title == "" || feed.Channel.Link == "" || feed.Channel.Description == "" {
+		t.Errorf("got channel %+v, want its title, link and description", feed.Channel)
+	}
+}
+
+func TestSearchRSSErrors(t *testing.T) {
+	captureLog(t)
+	s := newTestApp(t, newFakeOMDb(t, omdbError(http.StatusInternalServerError, "")), Config{AllowedTypes: []string{"movie"}})
+
+	for target, want := range map[string]int{
+		"/search.rss":                         http.StatusUnprocessableEntity,
+		"/search.rss?title=alien&type=series": http.StatusForbidden,
+		"/search.rss?title=alien":             http.StatusBadGateway,
+	} {
+		if w := send(s, "GET", target, ""); w.Code != want {
+			t.Errorf("%s: got status %d, want %d", target, w.Code, want)
+		}
+	}
+	if w := send(s, "POST", "/search.rss?title=alien", ""); w.Code != http.StatusNotFound {
+		t.Errorf("POST: got status %d, want 404", w.Code)
+	}
+}
+
+func TestRSSFormatterEscaping(t *testing.T) {
+	w := httptest.NewRecorder()
+	f := &RSSFormatter{Title: "<tag>"}
+	if err := f.Format(w, []*SearchResult{{Title: "Tom & Jerry", IMDBID: "tt1"}}); err != nil {
+		t.Fatal(err)
+	}
+	if body := w.Body.String(); !strings.Contains(body, "<title>Tom &amp; Jerry</title>") || strings.Contains(body, "<tag>") {
+		t.Errorf("got %s, want the titles escaped", body)
+	}
+}
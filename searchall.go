@@ -0,0 +1,234 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// PageSize is the number of results in each page returned by an OMDb search.
+// It's fixed by OMDb and can't be changed with a request parameter, so the
+// number of upstream calls needed for N results is N/PageSize rounded up.
+const PageSize = 10
+
+// pagesFor returns the number of pages that have to be requested to get
+// maxResults results, capped at the highest page OMDb will return.
+func pagesFor(maxResults int) int {
+	pages := (maxResults + PageSize - 1) / PageSize
+	if pages > maxPage {
+		return maxPage
+	}
+	return pages
+}
+
+// SearchStream calls the OMDBAPI for as many pages as are needed to return up
+// to maxResults results, starting from the first page, and calls fn with the
+// results of each page as it arrives. The Page of r is ignored. It makes the
+// minimum number of upstream calls, stopping early once OMDb has run out of
+// results or fn returns an error. No more than the OMDBAPI's max pages are
+// requested, if it has a limit.
+func (o *OMDBAPI) SearchStream(ctx context.Context, r *SearchRequest, maxResults int, fn func([]*SearchResult) error) error {
+	_, err := o.searchStream(ctx, r, maxResults, fn)
+	return err
+}
+
+// searchStream is SearchStream, but also returns true if it stopped at the max
+// pages while OMDb had more of the results that were asked for.
+func (o *OMDBAPI) searchStream(ctx context.Context, r *SearchRequest, maxResults int, fn func([]*SearchResult) error) (bool, error) {
+	var count int
+	total := -1
+
+	pages := pagesFor(maxResults)
+	capped := o.maxPages > 0 && pages > o.maxPages
+	if capped {
+		pages = o.maxPages
+	}
+
+	for page := 1; page <= pages; page++ {
+		pr := *r
+		pr.Page = page
+
+		result, err := o.searchPage(ctx, &pr)
+		if err != nil {
+			return false, err
+		}
+
+		results := result.Search
+		if count+len(results) > maxResults {
+			results = results[:maxResults-count]
+		}
+		count += len(results)
+		if err = fn(results); err != nil {
+			return false, err
+		}
+
+		// The first page says how many results there are in total, which may
+		// need fewer pages than were asked for.
+		if page == 1 {
+			if n, err := strconv.Atoi(result.TotalResults); err == nil {
+				total = n
+				if p := pagesFor(total); p < pages {
+					pages = p
+				}
+			}
+		}
+
+		if len(result.Search) < PageSize {
+			return false, nil
+		}
+	}
+
+	// Every page was full, so unless the total says otherwise, there may be
+	// more results that the cap left out.
+	truncated := capped && count < maxResults && (total < 0 || total > count)
+	return truncated, nil
+}
+
+// stableResults returns results with duplicate IMDb IDs removed, keeping the
+// first of each, sorted by title with c, then year, then IMDb ID. OMDb's order
+// can shift between page requests, so an order that depends only on the
+// results themselves is used to make the merged pages deterministic.
+func stableResults(results []*SearchResult, c Collator) []*SearchResult {
+	seen := make(map[string]bool)
+	var unique []*SearchResult
+	for _, r := range results {
+		if seen[r.IMDBID] {
+			continue
+		}
+		seen[r.IMDBID] = true
+		unique = append(unique, r)
+	}
+
+	sort.SliceStable(unique, func(i, j int) bool {
+		return compareResults(c, unique[i], unique[j]) < 0
+	})
+	return unique
+}
+
+// What SearchAll does when a page fails after earlier pages succeeded.
+//
+// PageFailuresFailFast returns the error and none of the results, so a result
+// is always complete.
+//
+// PageFailuresBestEffort returns the results of the pages before the failure,
+// along with a *PartialError, rather than losing them to a transient failure
+// part way through. A failure of the first page is still an error.
+const (
+	PageFailuresFailFast   = "fail-fast"
+	PageFailuresBestEffort = "best-effort"
+)
+
+// validPageFailures returns an error if s isn't a page failure mode.
+func validPageFailures(s string) error {
+	switch s {
+	case PageFailuresFailFast, PageFailuresBestEffort:
+		return nil
+	}
+	return fmt.Errorf("unsupported page failure mode %q, must be %q or %q", s, PageFailuresFailFast, PageFailuresBestEffort)
+}
+
+// SetPageFailures sets what SearchAll does when a page fails after earlier
+// pages succeeded, either PageFailuresFailFast, the default, or
+// PageFailuresBestEffort.
+func (o *OMDBAPI) SetPageFailures(mode string) error {
+	if err := validPageFailures(mode); err != nil {
+		return err
+	}
+	o.pageFailures = mode
+	return nil
+}
+
+// PartialError is returned with the results of SearchAll in best-effort mode
+// when a page failed after earlier ones succeeded. The results are those of
+// the pages before Page.
+type PartialError struct {
+	// Page is the page that failed.
+	Page int
+	Err  error
+}
+
+// Error returns the message of e, which is reported to clients, so it never
+// includes the request URL, as it has the API key.
+func (e *PartialError) Error() string {
+	return fmt.Sprintf("partial results: page %d failed: %s", e.Page, errorMessage(e.Err))
+}
+
+func (e *PartialError) Unwrap() error {
+	return e.Err
+}
+
+// partialError returns err, which stopped a search after pages pages
+// succeeded, as a *PartialError if the search can return those pages' results
+// in best-effort mode, otherwise nil.
+func (o *OMDBAPI) partialError(ctx context.Context, pages int, err error) *PartialError {
+	if o.pageFailures != PageFailuresBestEffort || pages == 0 || ctx.Err() != nil {
+		return nil
+	}
+	return &PartialError{Page: pages + 1, Err: err}
+}
+
+// SearchAllResult is the result of SearchAllPages.
+type SearchAllResult struct {
+	Results []*SearchResult
+
+	// Truncated is true if the search stopped at the max pages, before
+	// getting all of the results asked for that OMDb has.
+	Truncated bool
+
+	// Partial is set if a page failed in best-effort mode, in which case
+	// Results are only those of the pages before it.
+	Partial *PartialError
+}
+
+// SearchAll calls the OMDBAPI for as many pages as are needed to return up to
+// maxResults results, starting from the first page. The Page of r is ignored.
+// It makes the minimum number of upstream calls, stopping early once OMDb has
+// run out of results or the max pages is reached. The results have no
+// duplicates and are in the order described by stableResults. In best-effort
+// mode, a page that fails after earlier ones succeeded returns their results
+// with a *PartialError.
+func (o *OMDBAPI) SearchAll(ctx context.Context, r *SearchRequest, maxResults int) ([]*SearchResult, error) {
+	result, err := o.SearchAllPages(ctx, r, maxResults)
+	if err != nil {
+		return nil, err
+	}
+	if result.Partial != nil {
+		return result.Results, result.Partial
+	}
+	return result.Results, nil
+}
+
+// SearchAllPages is SearchAll, but also reports whether the results were
+// truncated by the max pages. A page that fails in best-effort mode is
+// reported by its Partial rather than as an error, unless it's the first page
+// or ctx is done.
+func (o *OMDBAPI) SearchAllPages(ctx context.Context, r *SearchRequest, maxResults int) (*SearchAllResult, error) {
+	var all []*SearchResult
+	var pages int
+	truncated, err := o.searchStream(ctx, r, maxResults, func(results []*SearchResult) error {
+		pages++
+		all = append(all, results...)
+		return nil
+	})
+
+	var partial *PartialError
+	if err != nil {
+		if partial = o.partialError(ctx, pages, err); partial == nil {
+			return nil, err
+		}
+	}
+
+	return &SearchAllResult{
+		Results:   stableResults(all, o.collator),
+		Truncated: truncated,
+		Partial:   partial,
+	}, nil
+}
+
+// SetMaxPages caps the number of pages requested for a single search by
+// SearchAll and SearchStream, to limit the quota a broad search can use. Zero
+// removes the cap.
+func (o *OMDBAPI) SetMaxPages(pages int) {
+	o.maxPages = pages
+}
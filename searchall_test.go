@@ -0,0 +1,353 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestPagesFor(t *testing.T) {
+	for maxResults, want := range map[int]int{0: 0, 1: 1, 10: 1, 11: 2, 25: 3, 1000: maxPage, 5000: maxPage} {
+		if got := pagesFor(maxResults); got != want {
+			t.Errorf("pagesFor(%d) = %d, want %d", maxResults, got, want)
+		}
+	}
+}
+
+func TestSearchAllCalls(t *testing.T) {
+	for _, test := range []struct {
+		name              string
+		total, maxResults int
+		calls, results    int
+	}{
+		{"partial last page", 100, 25, 3, 25},
+		{"whole pages", 100, 20, 2, 20},
+		{"fewer results than asked for", 15, 100, 2, 15},
+		{"total on a page boundary", 20, 100, 2, 20},
+		{"no results", 0, 100, 1, 0},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			omdb := newFakeOMDb(t, pagedResults(test.total))
+			api := newTestAPI(t, omdb)
+
+			results, err := api.SearchAll(context.Background(), &SearchRequest{Title: "alien"}, test.maxResults)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(results) != test.results {
+				t.Errorf("got %d results, want %d", len(results), test.results)
+			}
+			if n := omdb.calls(); n != test.calls {
+				t.Errorf("got %d calls to OMDb, want %d", n, test.calls)
+			}
+			for i := range omdb.calls() {
+				if page := omdb.query(i).Get("page"); page != strconv.Itoa(i+1) {
+					t.Errorf("call %d was for page %q, want %d", i, page, i+1)
+				}
+			}
+		})
+	}
+}
+
+// shiftedPages returns a handler for a search with 25 results, tt1 to tt25,
+// whose pages overlap by shift results, as they do when a result is added
+// between page requests. Each page is in the order of ids.
+func shiftedPages(shift int, order func([]string) []string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		start := max((page-1)*PageSize+1-shift, 1)
+
+		var ids []string
+		for i := start; i < start+PageSize && i <= 25; i++ {
+			ids = append(ids, "tt"+strconv.Itoa(i))
+		}
+		writeResults(w, 25, resultsFor(order(ids)...)...)
+	}
+}
+
+func reversed(ids []string) []string {
+	rev := make([]string, len(ids))
+	for i, id := range ids {
+		rev[len(ids)-1-i] = id
+	}
+	return rev
+}
+
+func TestSearchAllStableOrder(t *testing.T) {
+	same := func(ids []string) []string { return ids }
+	var want []string
+	for _, test := range []struct {
+		name  string
+		shift int
+		order func([]string) []string
+	}{
+		{"in order", 0, same},
+		{"shifted pages", 1, same},
+		{"overlapping pages", 3, same},
+		{"reordered pages", 3, reversed},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			api := newTestAPI(t, newFakeOMDb(t, shiftedPages(test.shift, test.order)))
+
+			results, err := api.SearchAll(context.Background(), &SearchRequest{Title: "alien"}, 30)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			seen := make(map[string]bool)
+			for i, r := range results {
+				if seen[r.IMDBID] {
+					t.Errorf("%s is duplicated", r.IMDBID)
+				}
+				seen[r.IMDBID] = true
+				if i > 0 && compareResults(nil, results[i-1], r) > 0 {
+					t.Errorf("%s is before %s", results[i-1].IMDBID, r.IMDBID)
+				}
+			}
+
+			got := ids(results)
+			if want == nil {
+				want = got
+			} else if !reflect.DeepEqual(got, want) {
+				t.Errorf("got %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestStableResults(t *testing.T) {
+	results := []*SearchResult{
+		{Title: "Alien", Year: "1992", IMDBID: "tt3"},
+		{Title: "alien", Year: "1979", IMDBID: "tt2"},
+		{Title: "Aliens", Year: "1986", IMDBID: "tt4"},
+		{Title: "Alien", Year: "1979", IMDBID: "tt1"},
+		{Title: "Alien 3", Year: "1992", IMDBID: "tt3"},
+	}
+	got := stableResults(results, nil)
+	if want := []string{"tt1", "tt2", "tt3", "tt4"}; !reflect.DeepEqual(ids(got), want) {
+		t.Errorf("got %v, want %v", ids(got), want)
+	}
+	// The first of the duplicates is kept.
+	if got[2].Title != "Alien" {
+		t.Errorf("got %q for tt3, want the first result", got[2].Title)
+	}
+}
+
+func TestSearchAllMaxPages(t *testing.T) {
+	for _, test := range []struct {
+		name                   string
+		total, maxResults, cap int
+		calls, results         int
+		truncated              bool
+	}{
+		{"more pages than the cap", 100, 50, 2, 2, 20, true},
+		{"fewer pages than the cap", 15, 100, 2, 2, 15, false},
+		{"as many results as asked for", 100, 20, 2, 2, 20, false},
+		{"no cap", 100, 50, 0, 5, 50, false},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			omdb := newFakeOMDb(t, pagedResults(test.total))
+			api := newTestAPI(t, omdb)
+			api.SetMaxPages(test.cap)
+
+			result, err := api.SearchAllPages(context.Background(), &SearchRequest{Title: "alien"}, test.maxResults)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(result.Results) != test.results {
+				t.Errorf("got %d results, want %d", len(result.Results), test.results)
+			}
+			if n := omdb.calls(); n != test.calls {
+				t.Errorf("got %d calls to OMDb, want %d", n, test.calls)
+			}
+			if result.Truncated != test.truncated {
+				t.Errorf("got truncated %t, want %t", result.Truncated, test.truncated)
+			}
+		})
+	}
+}
+
+func TestJobMaxPages(t *testing.T) {
+	omdb := newFakeOMDb(t, pagedResults(100))
+	s := newTestApp(t, omdb, Config{MaxPages: 3})
+
+	job := waitForJob(t, s, startJob(t, s, `{"title":"alien"}`))
+	if job.Status != JobDone {
+		t.Fatalf("got status %q, want %q: %s", job.Status, JobDone, job.Error)
+	}
+	if job.Pages != 3 || len(job.Results) != 30 {
+		t.Errorf("got %d pages of %d results, want 3 pages of 30", job.Pages, len(job.Results))
+	}
+	if !job.Truncated {
+		t.Error("got a job that wasn't truncated, want it stopped at the max pages")
+	}
+	if n := omdb.calls(); n != 3 {
+		t.Errorf("got %d calls to OMDb, want 3", n)
+	}
+}
+
+// failingPage returns a handler like pagedResults(total), except that page
+// fails with a 500.
+func failingPage(total, page int) http.HandlerFunc {
+	results := pagedResults(total)
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("page") == strconv.Itoa(page) {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		results(w, r)
+	}
+}
+
+func TestSearchAllFailFast(t *testing.T) {
+	api := newTestAPI(t, newFakeOMDb(t, failingPage(30, 2)))
+
+	results, err := api.SearchAll(context.Background(), &SearchRequest{Title: "alien"}, 30)
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || results != nil {
+		t.Errorf("got %v and error %v, want no results and the page's error", ids(results), err)
+	}
+	var partial *PartialError
+	if errors.As(err, &partial) {
+		t.Errorf("got a *PartialError %v in fail-fast mode", partial)
+	}
+}
+
+func TestSearchAllBestEffort(t *testing.T) {
+	omdb := newFakeOMDb(t, failingPage(30, 2))
+	api := newTestAPI(t, omdb)
+	if err := api.SetPageFailures(PageFailuresBestEffort); err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := api.SearchAll(context.Background(), &SearchRequest{Title: "alien"}, 30)
+	if got, want := ids(results), ids(stableResults(resultsFor(pageIDs(1, 30)...), nil)); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want the results of page 1: %v", got, want)
+	}
+	var partial *PartialError
+	if !errors.As(err, &partial) || partial.Page != 2 {
+		t.Fatalf("got error %v, want a *PartialError for page 2", err)
+	}
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusInternalServerError {
+		t.Errorf("got error %v, want it to wrap the page's error", err)
+	}
+	if msg := partial.Error(); !strings.HasPrefix(msg, "partial results: page 2 failed: ") || strings.Contains(msg, testKey) {
+		t.Errorf("got message %q, want the failed page without the key", msg)
+	}
+	// The pages after the failure aren't requested.
+	if n := omdb.calls(); n != 2 {
+		t.Errorf("got %d calls to OMDb, want 2", n)
+	}
+
+	result, err := api.SearchAllPages(context.Background(), &SearchRequest{Title: "alien"}, 30)
+	if err != nil || result.Partial == nil || len(result.Results) != 10 {
+		t.Errorf("got %+v and error %v, want the partial results reported by Partial", result, err)
+	}
+}
+
+func TestSearchAllBestEffortFirstPage(t *testing.T) {
+	api := newTestAPI(t, newFakeOMDb(t, failingPage(30, 1)))
+	api.SetPageFailures(PageFailuresBestEffort)
+
+	results, err := api.SearchAll(context.Background(), &SearchRequest{Title: "alien"}, 30)
+	var partial *PartialError
+	if err == nil || errors.As(err, &partial) || results != nil {
+		t.Errorf("got %v and error %v, want the first page's failure to be an error", ids(results), err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := api.SearchAllPages(ctx, &SearchRequest{Title: "alien"}, 30); err == nil {
+		t.Error("got no error for a canceled search")
+	}
+}
+
+func TestSetPageFailures(t *testing.T) {
+	api := newTestAPI(t, newFakeOMDb(t, pagedResults(0)))
+	if err := api.SetPageFailures("ignore"); err == nil {
+		t.Error("got no error for an unsupported mode")
+	}
+	if _, err := NewSearchAppWithConfig(Config{Key: testKey, PageFailures: "ignore"}); err == nil {
+		t.Error("got no error for an unsupported mode in the config")
+	}
+}
+
+func TestJobPartialResults(t *testing.T) {
+	captureLog(t)
+	for _, test := range []struct {
+		mode    string
+		status  string
+		results int
+	}{
+		{PageFailuresFailFast, JobFailed, 10},
+		{PageFailuresBestEffort, JobDone, 10},
+	} {
+		t.Run(test.mode, func(t *testing.T) {
+			s := newTestApp(t, newFakeOMDb(t, failingPage(30, 2)), Config{PageFailures: test.mode})
+
+			job := waitForJob(t, s, startJob(t, s, `{"title":"alien","max_results":30}`))
+			if job.Status != test.status {
+				t.Fatalf("got status %q, want %q: %s", job.Status, test.status, job.Error)
+			}
+			if len(job.Results) != test.results || job.Pages != 1 {
+				t.Errorf("got %d results from %d pages, want the %d of page 1", len(job.Results), job.Pages, test.results)
+			}
+			if test.mode == PageFailuresBestEffort {
+				if !strings.HasPrefix(job.Warning, "partial results: page 2 failed") || strings.Contains(job.Warning, testKey) {
+					t.Errorf("got warning %q, want the failed page without the key", job.Warning)
+				}
+			} else if job.Warning != "" {
+				t.Errorf("got warning %q in fail-fast mode, want none", job.Warning)
+			}
+		})
+	}
+}
+
+func TestJobFilters(t *testing.T) {
+	s := newTestApp(t, newFakeOMDb(t, pagedResults(25)), Config{})
+
+	job := waitForJob(t, s, startJob(t, s, `{"title":"alien","id_pattern":"^tt(1|2)[0-9]?$"}`))
+	if job.Status != JobDone {
+		t.Fatalf("got status %q, want %q: %s", job.Status, JobDone, job.Error)
+	}
+	want := []string{"tt1", "tt10", "tt11", "tt12", "tt13", "tt14", "tt15", "tt16", "tt17", "tt18", "tt19",
+		"tt2", "tt20", "tt21", "tt22", "tt23", "tt24", "tt25"}
+	if got := ids(job.Results); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want the matching results in stable order", got)
+	}
+	if job.Count != len(want) {
+		t.Errorf("got a count of %d, want %d", job.Count, len(want))
+	}
+
+	job = waitForJob(t, s, startJob(t, s, `{"title":"alien","min_year":2000}`))
+	if len(job.Results) != 0 || job.Count != 0 {
+		t.Errorf("got %v, want the results from 1999 filtered out", ids(job.Results))
+	}
+}
+
+func TestJobStableOrder(t *testing.T) {
+	// OMDb's order shifts between pages, so the same result can be on two
+	// of them, and they can be out of order.
+	omdb := newFakeOMDb(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("page") == "2" {
+			writeResults(w, 12, resultsFor("tt3", "tt1")...)
+			return
+		}
+		writeResults(w, 12, resultsFor("tt9", "tt8", "tt7", "tt6", "tt5", "tt4", "tt3", "tt12", "tt11", "tt10")...)
+	})
+	s := newTestApp(t, omdb, Config{})
+
+	job := waitForJob(t, s, startJob(t, s, `{"title":"alien","max_results":12}`))
+	want := []string{"tt1", "tt10", "tt11", "tt12", "tt3", "tt4", "tt5", "tt6", "tt7", "tt8", "tt9"}
+	if got := ids(job.Results); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if job.Count != len(want) {
+		t.Errorf("got a count of %d, want %d without the duplicate", job.Count, len(want))
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+)
+
+// Episode is a single episode in the season listing returned by the OMDb API.
+type Episode struct {
+	Title      string
+	Released   string
+	Episode    string
+	IMDBRating string
+	IMDBID     string
+}
+
+// SeasonWrapper is the outer-wrapper around the episodes returned by the API
+// for a season of a series.
+type SeasonWrapper struct {
+	Title        string
+	Season       string
+	TotalSeasons string
+	Episodes     []*Episode
+	Response     string
+	Error        string
+}
+
+// seasonURL returns a *url.URL that lists the episodes in a season of the
+// series with the given IMDb ID.
+func (o *OMDBAPI) seasonURL(seriesID string, season int) *url.URL {
+	n := *o.url
+	v := n.Query()
+
+	v.Set("i", seriesID)
+	v.Set("Season", strconv.Itoa(season))
+
+	n.RawQuery = v.Encode()
+	return &n
+}
+
+// GetSeason calls the OMDBAPI and returns the episodes in a season of the
+// series with the given IMDb ID as search results. Seasons are numbered from 1.
+func (o *OMDBAPI) GetSeason(ctx context.Context, seriesID string, season int) ([]*SearchResult, error) {
+	if season < 1 {
+		return nil, fmt.Errorf("%w %d, seasons are numbered from 1", ErrInvalidSeason, season)
+	}
+
+	var result *SeasonWrapper
+	if err := o.get(ctx, o.seasonURL(seriesID, season), &result); err != nil {
+		return nil, err
+	}
+
+	if result.Response == "False" {
+		return nil, fmt.Errorf("%w: season %d of %s: %s", ErrMovieNotFound, season, seriesID, result.Error)
+	}
+
+	results := make([]*SearchResult, len(result.Episodes))
+	for i, e := range result.Episodes {
+		results[i] = &SearchResult{
+			Title:  e.Title,
+			Year:   releaseYear(e.Released),
+			IMDBID: e.IMDBID,
+			Type:   "episode",
+		}
+	}
+	return results, nil
+}
+
+// releaseYear returns the year from a YYYY-MM-DD release date, or the date
+// unchanged if it isn't in that format (e.g. "N/A").
+func releaseYear(released string) string {
+	if len(released) < 4 {
+		return released
+	}
+	if _, err := strconv.Atoi(released[:4]); err != nil {
+		return released
+	}
+	return released[:4]
+}
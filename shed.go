@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// shedBuckets is the number of buckets a *LoadShedder's window is split into.
+// Outcomes drop out of the window a bucket at a time.
+const shedBuckets = 10
+
+// maxShedFraction is the largest fraction of requests that are shed, so that
+// some still reach OMDb to notice when it has recovered.
+const maxShedFraction = 0.9
+
+// shedBucket counts the outcomes of the OMDb calls started in one slice of
+// the window.
+type shedBucket struct {
+	start   time.Time
+	calls   int
+	errors  int
+	latency time.Duration
+}
+
+// LoadShedder tracks the error rate and mean latency of OMDb calls over a
+// sliding window, and sheds a fraction of searches while either is over its
+// threshold, to protect the quota and let OMDb recover. The fraction grows
+// with how far over the threshold it is: with an error rate threshold of 0.5,
+// an error rate of 0.75 sheds half of the searches. Nothing is shed until at
+// least minCalls calls have been made in the window.
+type LoadShedder struct {
+	errorRate float64
+	latency   time.Duration
+	window    time.Duration
+	minCalls  int
+	clock     Clock
+
+	mu      sync.Mutex
+	buckets [shedBuckets]shedBucket
+	rand    *rand.Rand
+	shed    int64
+}
+
+// NewLoadShedder returns a new *LoadShedder that sheds searches while the
+// error rate of OMDb calls is over errorRate, or their mean latency is over
+// latency, within window. Either threshold is ignored if it's zero.
+func NewLoadShedder(errorRate float64, latency, window time.Duration, minCalls int) *LoadShedder {
+	return &LoadShedder{
+		errorRate: errorRate,
+		latency:   latency,
+		window:    window,
+		minCalls:  minCalls,
+		clock:     RealClock,
+		rand:      rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// Seed resets the random number generator used to pick the searches to shed,
+// so that shedding is repeatable.
+func (l *LoadShedder) Seed(seed int64) {
+	l.mu.Lock()
+	l.rand = rand.New(rand.NewSource(seed))
+	l.mu.Unlock()
+}
+
+// bucket returns the bucket for now, emptying it first if it's left over from
+// an earlier window. The caller must hold l.mu.
+func (l *LoadShedder) bucket(now time.Time) *shedBucket {
+	size := l.window / shedBuckets
+	start := now.Truncate(size)
+	b := &l.buckets[int(start.UnixNano()/int64(size))%shedBuckets]
+	if !b.start.Equal(start) {
+		*b = shedBucket{start: start}
+	}
+	return b
+}
+
+// Record counts the outcome of an OMDb call that took latency. A call
+// canceled by the client doesn't say anything about OMDb, so it isn't
+// counted.
+func (l *LoadShedder) Record(err error, latency time.Duration) {
+	if l == nil || errors.Is(err, context.Canceled) {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b := l.bucket(l.clock.Now())
+	b.calls++
+	b.latency += latency
+	if err != nil {
+		b.errors++
+	}
+}
+
+// Fraction returns the fraction of searches that are being shed.
+func (l *LoadShedder) Fraction() float64 {
+	if l == nil {
+		return 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.fraction(l.clock.Now())
+}
+
+// fraction returns the fraction of searches to shed at now. The caller must
+// hold l.mu.
+func (l *LoadShedder) fraction(now time.Time) float64 {
+	var calls, errs int
+	var latency time.Duration
+	for _, b := range l.buckets {
+		if now.Sub(b.start) < l.window {
+			calls += b.calls
+			errs += b.errors
+			latency += b.latency
+		}
+	}
+	if calls == 0 || calls < l.minCalls {
+		return 0
+	}
+
+	var fraction float64
+	if rate := float64(errs) / float64(calls); l.errorRate > 0 && rate > l.errorRate {
+		fraction = (rate - l.errorRate) / (1 - l.errorRate)
+	}
+	if mean := latency / time.Duration(calls); l.latency > 0 && mean > l.latency {
+		if f := 1 - float64(l.latency)/float64(mean); f > fraction {
+			fraction = f
+		}
+	}
+	if fraction > maxShedFraction {
+		fraction = maxShedFraction
+	}
+	return fraction
+}
+
+// Shed returns true if a search should be shed.
+func (l *LoadShedder) Shed() bool {
+	if l == nil {
+		return false
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.rand.Float64() >= l.fraction(l.clock.Now()) {
+		return false
+	}
+	l.shed++
+	return true
+}
+
+// ShedCount returns the number of searches that have been shed.
+func (l *LoadShedder) ShedCount() int64 {
+	if l == nil {
+		return 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.shed
+}
+
+// shedLoad wraps h so that the searches picked by the load shedder get a 503,
+// with a Retry-After header of how long until the oldest outcomes drop out of
+// the window. It does nothing if load shedding is disabled. OPTIONS requests,
+// such as CORS preflights, aren't shed, as they never call OMDb.
+func (s *SearchApp) shedLoad(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if l := s.omdb.shedder; r.Method != "OPTIONS" && l.Shed() {
+			retry := (l.window / shedBuckets).Seconds()
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retry))))
+			http.Error(w, "OMDb is degraded, try again later", http.StatusServiceUnavailable)
+			return
+		}
+		h(w, r)
+	}
+}
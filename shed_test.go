@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadShedderFraction(t *testing.T) {
+	errOMDb := errors.New("omdb failed")
+	for _, test := range []struct {
+		name      string
+		errorRate float64
+		latency   time.Duration
+		errs, ok  int
+		took      time.Duration
+		want      float64
+	}{
+		{"healthy", 0.5, 0, 1, 3, 0, 0},
+		{"over the error rate", 0.5, 0, 3, 1, 0, 0.5},
+		{"capped", 0.05, 0, 4, 0, 0, maxShedFraction},
+		{"too few calls", 0.5, 0, 3, 0, 0, 0},
+		{"over the latency", 0, 100 * time.Millisecond, 0, 4, 200 * time.Millisecond, 0.5},
+		{"under the latency", 0, 100 * time.Millisecond, 0, 4, 50 * time.Millisecond, 0},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			l := NewLoadShedder(test.errorRate, test.latency, 10*time.Second, 4)
+			l.clock = NewFakeClock(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC))
+			for range test.errs {
+				l.Record(errOMDb, test.took)
+			}
+			for range test.ok {
+				l.Record(nil, test.took)
+			}
+			if got := l.Fraction(); got != test.want {
+				t.Errorf("got fraction %g, want %g", got, test.want)
+			}
+		})
+	}
+}
+
+func TestLoadShedderWindow(t *testing.T) {
+	l := NewLoadShedder(0.5, 0, 10*time.Second, 1)
+	clock := NewFakeClock(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC))
+	l.clock = clock
+
+	l.Record(errors.New("omdb failed"), 0)
+	l.Record(context.Canceled, 0)
+	if got := l.Fraction(); got != maxShedFraction {
+		t.Errorf("got fraction %g, want %g", got, maxShedFraction)
+	}
+
+	// The failure drops out of the window once it has passed.
+	clock.Advance(10 * time.Second)
+	if got := l.Fraction(); got != 0 {
+		t.Errorf("got fraction %g after the window, want 0", got)
+	}
+}
+
+func TestLoadShedderDisabled(t *testing.T) {
+	var l *LoadShedder
+	l.Record(errors.New("omdb failed"), 0)
+	if l.Shed() || l.Fraction() != 0 || l.ShedCount() != 0 {
+		t.Error("a nil *LoadShedder shed a search")
+	}
+}
+
+func TestSearchShed(t *testing.T) {
+	omdb := newFakeOMDb(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	s := newTestApp(t, omdb, Config{ShedErrorRate: 0.5, ShedMinCalls: 2})
+	s.SetClock(NewFakeClock(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)))
+	s.omdb.shedder.Seed(1)
+	captureLog(t)
+
+	// Nothing is shed until there have been enough calls.
+	for range 2 {
+		if w := serveRequest(s, newSearchRequest(`{"title":"alien"}`)); w.Code != http.StatusBadGateway {
+			t.Fatalf("got status %d, want 502: %s", w.Code, w.Body)
+		}
+	}
+
+	shed := 0
+	for range 100 {
+		w := serveRequest(s, newSearchRequest(`{"title":"alien"}`))
+		if w.Code == http.StatusServiceUnavailable {
+			shed++
+			if got := w.Header().Get("Retry-After"); got != "6" {
+				t.Errorf("got Retry-After %q, want 6", got)
+			}
+		}
+	}
+	if shed < 50 || shed == 100 {
+		t.Errorf("shed %d of 100 searches, want most but not all of them", shed)
+	}
+	if n := omdb.calls(); n != 102-shed {
+		t.Errorf("got %d calls to OMDb, want %d, as shed searches don't call it", n, 102-shed)
+	}
+	if got := s.omdb.shedder.ShedCount(); got != int64(shed) {
+		t.Errorf("got shed count %d, want %d", got, shed)
+	}
+	if w := send(s, "GET", "/metrics", ""); !strings.Contains(w.Body.String(), "omdb_load_shed_fraction 0.9") {
+		t.Errorf("got metrics %s, want the shed fraction", w.Body)
+	}
+}
+
+func TestSearchShedRoutes(t *testing.T) {
+	omdb := newFakeOMDb(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	s := newTestApp(t, omdb, Config{ShedErrorRate: 0.5, ShedMinCalls: 1})
+	s.SetClock(NewFakeClock(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)))
+	s.omdb.shedder.Seed(1)
+	captureLog(t)
+	serveRequest(s, newSearchRequest(`{"title":"alien"}`))
+
+	for target, want := range map[string]bool{
+		"/export?title=alien":     true,
+		"/search.rss?title=alien": true,
+		"/poster?url=x":           false,
+		"/config.json":            false,
+	} {
+		shed := 0
+		for range 10 {
+			if w := send(s, "GET", target, ""); w.Code == http.StatusServiceUnavailable {
+				shed++
+			}
+		}
+		if got := shed > 0; got != want {
+			t.Errorf("%s: shed %d of 10 requests, want shed %t", target, shed, want)
+		}
+	}
+}
+
+func TestSearchShedOptions(t *testing.T) {
+	omdb := newFakeOMDb(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	s := newTestApp(t, omdb, Config{ShedErrorRate: 0.5, ShedMinCalls: 1})
+	s.SetClock(NewFakeClock(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)))
+	s.omdb.shedder.Seed(1)
+	captureLog(t)
+	serveRequest(s, newSearchRequest(`{"title":"alien"}`))
+
+	for _, target := range []string{"/search", "/export", "/search/detailed"} {
+		for range 10 {
+			if w := send(s, "OPTIONS", target, ""); w.Code == http.StatusServiceUnavailable {
+				t.Fatalf("%s: got an OPTIONS request shed, want it let through", target)
+			}
+		}
+	}
+	if got := s.omdb.shedder.ShedCount(); got != 0 {
+		t.Errorf("got shed count %d, want OPTIONS requests not counted", got)
+	}
+}
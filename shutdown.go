@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// defaultShutdownTimeout is how long in-flight requests are given to finish
+// when the service is stopped.
+const defaultShutdownTimeout = 15 * time.Second
+
+// trackInFlight wraps h so that it's counted in InFlight while it runs.
+func (s *SearchApp) trackInFlight(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&s.inFlight, 1)
+		defer atomic.AddInt64(&s.inFlight, -1)
+		h(w, r)
+	}
+}
+
+// InFlight returns the number of /search requests being handled.
+func (s *SearchApp) InFlight() int64 {
+	return atomic.LoadInt64(&s.inFlight)
+}
+
+// serve runs server until it fails or the process is sent SIGINT or SIGTERM.
+// On a signal, it stops accepting connections and waits up to timeout for
+// in-flight requests to finish before closing the connections that are left.
+func serve(server *http.Server, app *SearchApp, timeout time.Duration) error {
+	errs := make(chan error, 1)
+	go func() {
+		errs <- server.ListenAndServe()
+	}()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	select {
+	case err := <-errs:
+		return err
+	case sig := <-signals:
+		log.Printf("received %s, waiting up to %s for %d in-flight searches to finish", sig, timeout, app.InFlight())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	err := server.Shutdown(ctx)
+	if errors.Is(err, context.DeadlineExceeded) {
+		log.Printf("shutdown timed out with %d searches in flight, closing their connections", app.InFlight())
+		return server.Close()
+	}
+	if err == nil {
+		log.Println("shut down cleanly")
+	}
+	return err
+}
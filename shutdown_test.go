@@ -0,0 +1,129 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// freeAddr returns a local address that nothing is listening on.
+func freeAddr(t *testing.T) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	return l.Addr().String()
+}
+
+// serveUntilStopped runs serve for s on a free address with the given drain
+// timeout, and starts a search that takes searchTime upstream. Once it's in
+// flight, the process is sent SIGTERM until serve returns. It returns the
+// status of the search, or 0 if it failed, and the error from serve.
+func serveUntilStopped(t *testing.T, searchTime, timeout time.Duration) (int, error) {
+	t.Helper()
+	captureLog(t)
+
+	// Registering for the signal stops it from terminating the test, even
+	// if it arrives before serve is listening for it.
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	omdb := newFakeOMDb(t, func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(searchTime)
+		writeResults(w, 1, resultsFor("tt1")...)
+	})
+	s := newTestApp(t, omdb, Config{})
+	server := &http.Server{Addr: freeAddr(t), Handler: s.Handler()}
+
+	served := make(chan error, 1)
+	go func() { served <- serve(server, s, timeout) }()
+
+	status := make(chan int, 1)
+	go func() {
+		for {
+			resp, err := http.Post("http://"+server.Addr+"/search", "application/json", strings.NewReader(`{"title":"alien"}`))
+			if err == nil {
+				resp.Body.Close()
+				status <- resp.StatusCode
+				return
+			}
+			if s.InFlight() > 0 || !strings.Contains(err.Error(), "refused") {
+				status <- 0
+				return
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}()
+
+	for s.InFlight() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	for {
+		syscall.Kill(os.Getpid(), syscall.SIGTERM)
+		select {
+		case err := <-served:
+			return <-status, err
+		case <-time.After(20 * time.Millisecond):
+		}
+	}
+}
+
+func TestServeDrains(t *testing.T) {
+	status, err := serveUntilStopped(t, 200*time.Millisecond, 5*time.Second)
+	if err != nil {
+		t.Errorf("got error %v, want a clean shutdown", err)
+	}
+	if status != http.StatusOK {
+		t.Errorf("got status %d for the in-flight search, want 200", status)
+	}
+}
+
+func TestServeDrainTimeout(t *testing.T) {
+	start := time.Now()
+	status, _ := serveUntilStopped(t, 2*time.Second, 50*time.Millisecond)
+	if status != 0 {
+		t.Errorf("got status %d for the in-flight search, want its connection closed", status)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("took %s to shut down, want the timeout to cut it short", d)
+	}
+}
+
+func TestServeError(t *testing.T) {
+	s := newTestApp(t, newFakeOMDb(t, pagedResults(1)), Config{})
+	if err := serve(&http.Server{Addr: "127.0.0.1:-1", Handler: s.Handler()}, s, time.Second); err == nil {
+		t.Error("got no error for an invalid address")
+	}
+}
+
+func TestInFlight(t *testing.T) {
+	release := make(chan struct{})
+	omdb := newFakeOMDb(t, func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		writeResults(w, 1, resultsFor("tt1")...)
+	})
+	s := newTestApp(t, omdb, Config{})
+
+	done := make(chan struct{})
+	go func() {
+		serveRequest(s, newSearchRequest(`{"title":"alien"}`))
+		close(done)
+	}()
+
+	for s.InFlight() != 1 {
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	<-done
+	if n := s.InFlight(); n != 0 {
+		t.Errorf("got %d in flight once the search finished, want 0", n)
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// defaultStaticExtensions are the extensions of the files that are served
+// from the site directory by default.
+var defaultStaticExtensions = []string{"html", "css", "js", "png", "jpg", "svg"}
+
+// SetStaticExtensions only serves files with one of exts, e.g. css, from the
+// site directory, so that anything else placed in it by mistake, like a
+// backup or a .env file, isn't exposed. Everything else, including directory
+// listings, is a 404.
+func (s *SearchApp) SetStaticExtensions(exts []string) error {
+	if len(exts) == 0 {
+		return fmt.Errorf("at least one static file extension is required")
+	}
+
+	s.staticExtensions = make(map[string]bool, len(exts))
+	for _, ext := range exts {
+		ext = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(ext), "."))
+		if ext == "" || strings.ContainsAny(ext, "./") {
+			return fmt.Errorf("invalid static file extension %q", ext)
+		}
+		s.staticExtensions["."+ext] = true
+	}
+	return nil
+}
+
+// defaultNotFoundPage is the page in the site directory that's served for
+// static files that don't exist, if there is one.
+const defaultNotFoundPage = "404.html"
+
+// SetNotFoundPage serves the file at page, relative to the site directory,
+// with a 404 for static files that don't exist or aren't allowed, instead of
+// the plain text 404. The plain 404 is still served if page doesn't exist or
+// is empty. It only applies to the site, not to the API's endpoints.
+func (s *SearchApp) SetNotFoundPage(page string) error {
+	if page != "" && (filepath.IsAbs(page) || !filepath.IsLocal(page)) {
+		return fmt.Errorf("not found page %q must be a path within the site directory", page)
+	}
+	s.notFoundPage = page
+	return nil
+}
+
+// notFound responds with a 404 for a static file, with the not found page as
+// its body if there is one. It's read for each response, so that it can be
+// changed without a restart.
+func (s *SearchApp) notFound(w http.ResponseWriter, r *http.Request) {
+	if s.notFoundPage != "" {
+		if page, err := fs.ReadFile(s.site, filepath.ToSlash(s.notFoundPage)); err == nil {
+			contentType := mime.TypeByExtension(filepath.Ext(s.notFoundPage))
+			if contentType == "" {
+				contentType = "text/html; charset=utf-8"
+			}
+			w.Header().Set("Content-Type", contentType)
+			w.WriteHeader(http.StatusNotFound)
+			w.Write(page)
+			return
+		}
+	}
+	http.NotFound(w, r)
+}
+
+// staticExists returns true if there's a file at p in the site.
+func (s *SearchApp) staticExists(p string) bool {
+	name := strings.TrimPrefix(path.Clean("/"+p), "/")
+	if name == "" {
+		return false
+	}
+	info, err := fs.Stat(s.site, name)
+	return err == nil && !info.IsDir()
+}
+
+// staticAllowed returns true if the file at p may be served from the site
+// directory.
+func (s *SearchApp) staticAllowed(p string) bool {
+	return s.staticExtensions[strings.ToLower(path.Ext(p))]
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// staticSite are the files of a site directory with files of allowed and
+// disallowed extensions.
+var staticSite = map[string]string{
+	"search.html":     "<h1>search</h1>",
+	"app.js":          "search()",
+	"style.CSS":       "body {}",
+	"img/logo.svg":    "<svg/>",
+	".env":            "OMDB_KEY=secret",
+	"search.html.bak": "<h1>old</h1>",
+	"notes.txt":       "notes",
+}
+
+func TestStaticExtensions(t *testing.T) {
+	dir := newSiteDir(t, staticSite)
+	s := newTestApp(t, newFakeOMDb(t, pagedResults(0)), Config{Landing: LandingIndex, SiteDir: dir})
+
+	for target, want := range map[string]int{
+		"/":                http.StatusOK,
+		"/app.js":          http.StatusOK,
+		"/style.CSS":       http.StatusOK,
+		"/img/logo.svg":    http.StatusOK,
+		"/.env":            http.StatusNotFound,
+		"/search.html.bak": http.StatusNotFound,
+		"/notes.txt":       http.StatusNotFound,
+		"/img/":            http.StatusNotFound,
+		"/missing.js":      http.StatusNotFound,
+	} {
+		if w := send(s, "GET", target, ""); w.Code != want {
+			t.Errorf("%s: got status %d, want %d", target, w.Code, want)
+		}
+	}
+}
+
+func TestStaticExtensionsConfig(t *testing.T) {
+	dir := newSiteDir(t, staticSite)
+	s := newTestApp(t, newFakeOMDb(t, pagedResults(0)), Config{
+		Landing:          LandingIndex,
+		SiteDir:          dir,
+		StaticExtensions: []string{".TXT", " js "},
+	})
+
+	for target, want := range map[string]int{
+		"/notes.txt":    http.StatusOK,
+		"/app.js":       http.StatusOK,
+		"/style.CSS":    http.StatusNotFound,
+		"/img/logo.svg": http.StatusNotFound,
+	} {
+		if w := send(s, "GET", target, ""); w.Code != want {
+			t.Errorf("%s: got status %d, want %d", target, w.Code, want)
+		}
+	}
+
+	for _, exts := range [][]string{{""}, {"tar.gz"}, {"a/b"}} {
+		var s SearchApp
+		if err := s.SetStaticExtensions(exts); err == nil {
+			t.Errorf("%q: got no error", exts)
+		}
+	}
+}
+
+func TestNotFoundPage(t *testing.T) {
+	files := map[string]string{"search.html": "<h1>search</h1>", "404.html": "<h1>not here</h1>"}
+	dir := newSiteDir(t, files)
+	s := newTestApp(t, newFakeOMDb(t, pagedResults(0)), Config{Landing: LandingIndex, SiteDir: dir})
+
+	for _, target := range []string{"/missing.js", "/img/missing.png", "/.env"} {
+		w := send(s, "GET", target, "")
+		if w.Code != http.StatusNotFound || w.Body.String() != files["404.html"] {
+			t.Errorf("%s: got %d %q, want the not found page", target, w.Code, w.Body)
+		}
+		if got := w.Header().Get("Content-Type"); got != "text/html; charset=utf-8" {
+			t.Errorf("%s: got Content-Type %q, want HTML", target, got)
+		}
+	}
+
+	// The API's endpoints keep their own 404s.
+	for _, target := range []string{"/search", "/jobs/missing"} {
+		if w := send(s, "GET", target, ""); w.Code != http.StatusNotFound || strings.Contains(w.Body.String(), "not here") {
+			t.Errorf("%s: got %d %q, want the API's 404", target, w.Code, w.Body)
+		}
+	}
+
+	// It's read for each response.
+	os.WriteFile(filepath.Join(dir, "404.html"), []byte("<h1>gone</h1>"), 0644)
+	if w := send(s, "GET", "/missing.js", ""); w.Body.String() != "<h1>gone</h1>" {
+		t.Errorf("got %q, want the changed page", w.Body)
+	}
+}
+
+func TestNotFoundPageConfig(t *testing.T) {
+	dir := newSiteDir(t, map[string]string{"search.html": "<h1>search</h1>", "errors/missing.htm": "<h1>missing</h1>"})
+	s := newTestApp(t, newFakeOMDb(t, pagedResults(0)), Config{Landing: LandingIndex, SiteDir: dir, NotFoundPage: "errors/missing.htm"})
+
+	if w := send(s, "GET", "/missing.js", ""); w.Code != http.StatusNotFound || w.Body.String() != "<h1>missing</h1>" {
+		t.Errorf("got %d %q, want the configured page", w.Code, w.Body)
+	}
+
+	for _, page := range []string{"/etc/passwd", "../404.html"} {
+		if err := s.SetNotFoundPage(page); err == nil {
+			t.Errorf("%s: got no error for a page outside the site directory", page)
+		}
+	}
+}
+
+func TestNotFoundPageMissing(t *testing.T) {
+	dir := newSiteDir(t, map[string]string{"search.html": "<h1>search</h1>"})
+	s := newTestApp(t, newFakeOMDb(t, pagedResults(0)), Config{Landing: LandingIndex, SiteDir: dir})
+
+	w := send(s, "GET", "/missing.js", "")
+	if w.Code != http.StatusNotFound || w.Body.String() != "404 page not found\n" {
+		t.Errorf("got %d %q, want the plain 404", w.Code, w.Body)
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"embed"
+	"io/fs"
+	"log"
+	"path"
+)
+
+// bundledThemes are the frontend asset sets built into the binary, one
+// subdirectory of themes per theme, e.g. themes/dark. They're kept out of the
+// site directory, so the static files served from it never include them.
+//
+//go:embed themes
+var bundledThemes embed.FS
+
+// Themes returns the names of the bundled themes.
+func Themes() []string {
+	entries, err := fs.ReadDir(bundledThemes, "themes")
+	if err != nil {
+		return nil
+	}
+
+	var themes []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			themes = append(themes, entry.Name())
+		}
+	}
+	return themes
+}
+
+// themeFS returns the files of the bundled theme, and false if there isn't a
+// theme by that name.
+func themeFS(theme string) (fs.FS, bool) {
+	for _, t := range Themes() {
+		if t == theme {
+			site, err := fs.Sub(bundledThemes, path.Join("themes", theme))
+			return site, err == nil
+		}
+	}
+	return nil, false
+}
+
+// SetTheme serves the site from the bundled theme in the LandingIndex mode,
+// rather than from the site directory. The site directory is still used, with
+// a warning, if theme isn't one of the bundled themes, and if it's empty.
+func (s *SearchApp) SetTheme(theme string) {
+	if theme == "" {
+		return
+	}
+
+	site, ok := themeFS(theme)
+	if !ok {
+		log.Printf("theme %q is not one of %v, using the site directory", theme, Themes())
+		return
+	}
+	s.site = site
+	s.theme = theme
+}
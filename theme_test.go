@@ -0,0 +1,76 @@
+package main
+
+import (
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestThemes(t *testing.T) {
+	if got, want := Themes(), []string{"dark", "light"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	for _, theme := range []string{"", "blue", "../themes", "dark/..", "."} {
+		if _, ok := themeFS(theme); ok {
+			t.Errorf("%q: got a theme, want none", theme)
+		}
+	}
+}
+
+func TestThemeServed(t *testing.T) {
+	dir := newSiteDir(t, map[string]string{
+		"search.html": "site",
+		"style.css":   "site styles",
+	})
+
+	for _, theme := range []string{"dark", "light"} {
+		t.Run(theme, func(t *testing.T) {
+			s := newTestApp(t, newFakeOMDb(t, pagedResults(0)), Config{
+				Landing: LandingIndex,
+				SiteDir: dir,
+				Theme:   theme,
+			})
+			want, err := bundledThemes.ReadFile("themes/" + theme + "/style.css")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			w := send(s, "GET", "/style.css", "")
+			if w.Code != http.StatusOK || w.Body.String() != string(want) {
+				t.Errorf("got %d %q, want the %s styles", w.Code, w.Body, theme)
+			}
+			if w := send(s, "GET", "/", ""); !strings.Contains(w.Body.String(), "<form") {
+				t.Errorf("got %q for /, want the theme's search page", w.Body)
+			}
+		})
+	}
+}
+
+func TestThemeUnknown(t *testing.T) {
+	dir := newSiteDir(t, map[string]string{"style.css": "site styles"})
+	s := newTestApp(t, newFakeOMDb(t, pagedResults(0)), Config{
+		Landing: LandingIndex,
+		SiteDir: dir,
+		Theme:   "blue",
+	})
+
+	if w := send(s, "GET", "/style.css", ""); w.Body.String() != "site styles" {
+		t.Errorf("got %q, want the site directory's styles", w.Body)
+	}
+}
+
+func TestThemesNotInSite(t *testing.T) {
+	// The bundled themes are never served from the site directory.
+	s := newTestApp(t, newFakeOMDb(t, pagedResults(0)), Config{
+		Landing: LandingIndex,
+		SiteDir: newSiteDir(t, map[string]string{"search.html": "site"}),
+	})
+
+	for _, target := range []string{"/themes/dark/style.css", "/dark/style.css"} {
+		if w := send(s, "GET", target, ""); w.Code != http.StatusNotFound {
+			t.Errorf("%s: got status %d, want 404", target, w.Code)
+		}
+	}
+}
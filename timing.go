@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// upstreamTimingKey is the context key for the *upstreamTiming of a request.
+type upstreamTimingKey struct{}
+
+// upstreamTiming accumulates the time spent waiting on OMDb, and the number of
+// calls made to it, while handling a single request.
+type upstreamTiming struct {
+	nanos int64
+	calls int64
+}
+
+// withUpstreamTiming returns a copy of ctx that records the time spent in
+// upstream calls made with it to the returned *upstreamTiming.
+func withUpstreamTiming(ctx context.Context) (context.Context, *upstreamTiming) {
+	t := &upstreamTiming{}
+	return context.WithValue(ctx, upstreamTimingKey{}, t), t
+}
+
+// recordUpstream counts a call that started at start in the *upstreamTiming
+// in ctx, if there is one.
+func recordUpstream(ctx context.Context, start time.Time) {
+	if t, ok := ctx.Value(upstreamTimingKey{}).(*upstreamTiming); ok {
+		atomic.AddInt64(&t.nanos, int64(time.Since(start)))
+		atomic.AddInt64(&t.calls, 1)
+	}
+}
+
+// timingWriter is an http.ResponseWriter that adds a Server-Timing header with
+// the upstream and total handling time, and an X-Upstream-Calls header with
+// the number of OMDb calls made, before the response is written. A search
+// served from the cache makes no calls, and one that shares the call of an
+// identical search in flight doesn't count it.
+type timingWriter struct {
+	http.ResponseWriter
+	start    time.Time
+	upstream *upstreamTiming
+	written  bool
+	status   int
+}
+
+// newTimingWriter returns a *timingWriter that reports the timing of the
+// request starting from now.
+func newTimingWriter(w http.ResponseWriter, upstream *upstreamTiming) *timingWriter {
+	return &timingWriter{
+		ResponseWriter: w,
+		start:          time.Now(),
+		upstream:       upstream,
+	}
+}
+
+// millis returns d in fractional milliseconds.
+func millis(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+func (t *timingWriter) setHeader() {
+	if t.written {
+		return
+	}
+	t.written = true
+
+	upstream := time.Duration(atomic.LoadInt64(&t.upstream.nanos))
+	t.Header().Set("Server-Timing", fmt.Sprintf(
+		"upstream;dur=%.1f, total;dur=%.1f",
+		millis(upstream),
+		millis(time.Since(t.start)),
+	))
+	t.Header().Set("X-Upstream-Calls", strconv.FormatInt(atomic.LoadInt64(&t.upstream.calls), 10))
+}
+
+func (t *timingWriter) WriteHeader(code int) {
+	t.setHeader()
+	if t.status == 0 {
+		t.status = code
+	}
+	t.ResponseWriter.WriteHeader(code)
+}
+
+func (t *timingWriter) Write(b []byte) (int, error) {
+	t.setHeader()
+	if t.status == 0 {
+		t.status = http.StatusOK
+	}
+	return t.ResponseWriter.Write(b)
+}
+
+// Status returns the status code of the response, or 200 if nothing has been
+// written yet, as the server would send.
+func (t *timingWriter) Status() int {
+	if t.status == 0 {
+		return http.StatusOK
+	}
+	return t.status
+}
+
+// Unwrap returns the underlying http.ResponseWriter, so that an
+// http.ResponseController can reach it.
+func (t *timingWriter) Unwrap() http.ResponseWriter {
+	return t.ResponseWriter
+}
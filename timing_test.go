@@ -0,0 +1,61 @@
+package main
+
+import (
+	"net/http"
+	"regexp"
+	"testing"
+	"time"
+)
+
+// serverTimingPattern matches the Server-Timing header of a search.
+var serverTimingPattern = regexp.MustCompile(`^upstream;dur=\d+\.\d, total;dur=\d+\.\d$`)
+
+func TestUpstreamCalls(t *testing.T) {
+	omdb := newFakeOMDb(t, pagedResults(35))
+	s := newTestApp(t, omdb, Config{CacheTTL: time.Minute, CacheKeys: CacheKeyTerm})
+
+	// The first search fetches every page for the cache, and the next is
+	// served from it.
+	for _, want := range []string{"4", "0"} {
+		w := serveRequest(s, newSearchRequest(`{"title":"alien","page":2}`))
+		if w.Code != http.StatusOK {
+			t.Fatalf("got status %d, want 200: %s", w.Code, w.Body)
+		}
+		if got := w.Header().Get("X-Upstream-Calls"); got != want {
+			t.Errorf("got X-Upstream-Calls %q, want %q", got, want)
+		}
+		if got := w.Header().Get("Server-Timing"); !serverTimingPattern.MatchString(got) {
+			t.Errorf("got Server-Timing %q, want the upstream and total durations", got)
+		}
+	}
+}
+
+func TestUpstreamCallsSingle(t *testing.T) {
+	captureLog(t)
+	for _, test := range []struct {
+		name    string
+		handler http.HandlerFunc
+		want    string
+	}{
+		{"search", pagedResults(35), "1"},
+		{"failed search", omdbError(http.StatusInternalServerError, ""), "1"},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			s := newTestApp(t, newFakeOMDb(t, test.handler), Config{})
+			w := serveRequest(s, newSearchRequest(`{"title":"alien"}`))
+			if got := w.Header().Get("X-Upstream-Calls"); got != test.want {
+				t.Errorf("got X-Upstream-Calls %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+func TestUpstreamCallsInvalid(t *testing.T) {
+	omdb := newFakeOMDb(t, pagedResults(1))
+	s := newTestApp(t, omdb, Config{})
+
+	w := serveRequest(s, newSearchRequest(`{"title":""}`))
+	if got := w.Header().Get("X-Upstream-Calls"); got != "0" {
+		t.Errorf("got X-Upstream-Calls %q for an invalid search, want 0", got)
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// TitleCaseExceptions are the words that TitleCase leaves in upper case, as
+// they're acronyms rather than a title in all caps. Roman numerals don't need
+// to be listed.
+var TitleCaseExceptions = map[string]bool{
+	"AI":   true,
+	"CIA":  true,
+	"DC":   true,
+	"FBI":  true,
+	"LA":   true,
+	"NASA": true,
+	"NYC":  true,
+	"TV":   true,
+	"UFO":  true,
+	"UK":   true,
+	"US":   true,
+	"USA":  true,
+	"USSR": true,
+	"WWI":  true,
+	"WWII": true,
+}
+
+// minorWords are left in lower case by TitleCase, unless they start or end the
+// title.
+var minorWords = map[string]bool{
+	"a": true, "an": true, "and": true, "as": true, "at": true, "but": true,
+	"by": true, "for": true, "in": true, "nor": true, "of": true, "on": true,
+	"or": true, "the": true, "to": true, "vs": true, "vs.": true,
+}
+
+// romanNumeralWords are words that are also roman numerals, which TitleCase
+// treats as words when they're in lower case.
+var romanNumeralWords = map[string]bool{
+	"di": true, "li": true, "mi": true, "mix": true,
+}
+
+// romanNumeralPattern matches the roman numerals from I to MMMCMXCIX.
+var romanNumeralPattern = regexp.MustCompile(`^M{0,3}(CM|CD|D?C{0,3})(XC|XL|L?X{0,3})(IX|IV|V?I{0,3})$`)
+
+// TitleCase returns title in title case, e.g. "the lord OF THE RINGS" becomes
+// "The Lord of the Rings". Words in mixed case, like "McQueen", are assumed
+// to be cased deliberately and are left alone, as are TitleCaseExceptions in
+// upper case. Roman numerals are put in upper case. Minor words are capitalized at
+// the start and end of the title, and of a subtitle after a colon or dash.
+func TitleCase(title string) string {
+	words := strings.Fields(title)
+	for i, word := range words {
+		minor := i > 0 && i < len(words)-1 && !startsSubtitle(words[i-1])
+		words[i] = titleCaseWord(word, minor)
+	}
+	return strings.Join(words, " ")
+}
+
+// startsSubtitle returns true if the word after prev starts a subtitle.
+func startsSubtitle(prev string) bool {
+	return strings.HasSuffix(prev, ":") || prev == "-" || prev == "–" || prev == "—"
+}
+
+// titleCaseWord returns word in title case. If minor is true, minor words are
+// put in lower case.
+func titleCaseWord(word string, minor bool) string {
+	upper := strings.ToUpper(word)
+	lower := strings.ToLower(word)
+	if word != upper && word != lower {
+		return word
+	}
+
+	if word == upper && TitleCaseExceptions[strings.Trim(word, ".,:;!?'\"()")] {
+		return word
+	}
+	if isRomanNumeral(upper) && (word == upper || !romanNumeralWords[lower]) {
+		return upper
+	}
+
+	if minor && minorWords[lower] {
+		return lower
+	}
+
+	parts := strings.Split(lower, "-")
+	for i, part := range parts {
+		parts[i] = capitalize(part)
+	}
+	return strings.Join(parts, "-")
+}
+
+// isRomanNumeral returns true if word, ignoring trailing punctuation, is a
+// roman numeral.
+func isRomanNumeral(word string) bool {
+	word = strings.TrimRight(word, ".,:;!?")
+	return word != "" && romanNumeralPattern.MatchString(word)
+}
+
+// capitalize returns s with its first letter in upper case, skipping leading
+// punctuation like an opening parenthesis.
+func capitalize(s string) string {
+	for i, r := range s {
+		if unicode.IsLetter(r) {
+			return s[:i] + string(unicode.ToUpper(r)) + s[i+utf8.RuneLen(r):]
+		}
+	}
+	return s
+}
+
+// titleCaseResults returns copies of results with their titles in title case,
+// and the original titles in RawTitle.
+func titleCaseResults(results []*SearchResult) []*SearchResult {
+	cased := make([]*SearchResult, len(results))
+	for i, r := range results {
+		c := *r
+		c.RawTitle = r.Title
+		c.Title = TitleCase(r.Title)
+		cased[i] = &c
+	}
+	return cased
+}
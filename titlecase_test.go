@@ -0,0 +1,48 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestTitleCase(t *testing.T) {
+	for title, want := range map[string]string{
+		"the lord OF THE RINGS":            "The Lord of the Rings",
+		"THE LORD OF THE RINGS":            "The Lord of the Rings",
+		"star wars: a new hope":            "Star Wars: A New Hope",
+		"mission: impossible - the final":  "Mission: Impossible - The Final",
+		"rocky iv":                         "Rocky IV",
+		"mix of the year":                  "Mix of the Year",
+		"the bourne identity 2: CIA files": "The Bourne Identity 2: CIA Files",
+		"McQueen":                          "McQueen",
+		"spider-man: far from home":        "Spider-Man: Far From Home",
+		"what are you looking at":          "What Are You Looking At",
+		"(500) days of summer":             "(500) Days of Summer",
+		"  extra   spaces ":                "Extra Spaces",
+		"":                                 "",
+	} {
+		if got := TitleCase(title); got != want {
+			t.Errorf("TitleCase(%q) = %q, want %q", title, got, want)
+		}
+	}
+}
+
+func TestSearchTitleCase(t *testing.T) {
+	omdb := newFakeOMDb(t, func(w http.ResponseWriter, r *http.Request) {
+		writeResults(w, 1, &SearchResult{Title: "ALIEN VS. PREDATOR", IMDBID: "tt0370263"})
+	})
+
+	for _, test := range []struct {
+		titleCase       bool
+		title, rawTitle string
+	}{
+		{false, "ALIEN VS. PREDATOR", ""},
+		{true, "Alien vs. Predator", "ALIEN VS. PREDATOR"},
+	} {
+		s := newTestApp(t, omdb, Config{TitleCase: test.titleCase})
+		results := decodeResults(t, serveRequest(s, newSearchRequest(`{"title":"alien"}`)))
+		if len(results) != 1 || results[0].Title != test.title || results[0].RawTitle != test.rawTitle {
+			t.Errorf("title case %t: got %+v, want %q and %q", test.titleCase, results, test.title, test.rawTitle)
+		}
+	}
+}
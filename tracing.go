@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// tracerName is the instrumentation scope of the service's spans.
+const tracerName = "github.com/johnworth/omdb-example"
+
+// tracePropagator reads and writes the W3C traceparent header.
+var tracePropagator = propagation.TraceContext{}
+
+// SetTracerProvider sets the OpenTelemetry TracerProvider that the /search
+// handler, and the OMDb calls it makes, are traced with. Spans record nothing
+// by default, though trace context is still propagated to OMDb.
+func (s *SearchApp) SetTracerProvider(tp trace.TracerProvider) {
+	if tp == nil {
+		tp = noop.NewTracerProvider()
+	}
+	s.tracer = tp.Tracer(tracerName)
+}
+
+// startRequestSpan starts a server span named name for r, as a child of the
+// span in its traceparent header, if it has one.
+func (s *SearchApp) startRequestSpan(ctx context.Context, r *http.Request, name string) (context.Context, trace.Span) {
+	ctx = tracePropagator.Extract(ctx, propagation.HeaderCarrier(r.Header))
+	return s.tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindServer))
+}
+
+// startSpan starts a client span named name as a child of the span in ctx,
+// with the TracerProvider that started it. It records nothing if there isn't
+// a span, e.g. when an OMDBAPI is used directly rather than through a
+// *SearchApp.
+func startSpan(ctx context.Context, name string) (context.Context, trace.Span) {
+	tracer := trace.SpanFromContext(ctx).TracerProvider().Tracer(tracerName)
+	return tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKindClient))
+}
+
+// endSpan sets the status of span from err and ends it. The error message
+// never includes the request URL, as it has the API key.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.SetStatus(codes.Error, errorMessage(err))
+	}
+	span.End()
+}
+
+// injectTraceParent sets the traceparent header of h to the span context of
+// the span in ctx, if it has one.
+func injectTraceParent(ctx context.Context, h http.Header) {
+	tracePropagator.Inject(ctx, propagation.HeaderCarrier(h))
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// newTracedApp returns a *SearchApp that uses omdb and exports its spans to
+// the returned exporter.
+func newTracedApp(t *testing.T, omdb *fakeOMDb) (*SearchApp, *tracetest.InMemoryExporter) {
+	t.Helper()
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	t.Cleanup(func() { tp.Shutdown(t.Context()) })
+
+	s := newTestApp(t, omdb, Config{})
+	s.SetTracerProvider(tp)
+	return s, exporter
+}
+
+// spanNamed returns the span called name in spans.
+func spanNamed(t *testing.T, spans tracetest.SpanStubs, name string) tracetest.SpanStub {
+	t.Helper()
+	for _, span := range spans {
+		if span.Name == name {
+			return span
+		}
+	}
+	t.Fatalf("no %q span in %d spans", name, len(spans))
+	return tracetest.SpanStub{}
+}
+
+// attr returns the value of the attribute key of span.
+func attr(span tracetest.SpanStub, key string) attribute.Value {
+	for _, kv := range span.Attributes {
+		if string(kv.Key) == key {
+			return kv.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+func TestSearchSpans(t *testing.T) {
+	var traceparent string
+	omdb := newFakeOMDb(t, func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+		writeResults(w, 2, resultsFor("tt1", "tt2")...)
+	})
+	s, exporter := newTracedApp(t, omdb)
+
+	req := newSearchRequest(`{"title":"matrix"}`)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	if w := serveRequest(s, req); w.Code != http.StatusOK {
+		t.Fatalf("got status %d: %s", w.Code, w.Body)
+	}
+
+	spans := exporter.GetSpans()
+	search := spanNamed(t, spans, "search")
+	get := spanNamed(t, spans, "omdb.get")
+
+	if got := search.SpanContext.TraceID().String(); got != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("search span has trace ID %s, want the one from traceparent", got)
+	}
+	if got := search.Parent.SpanID().String(); got != "00f067aa0ba902b7" {
+		t.Errorf("search span has parent %s, want the one from traceparent", got)
+	}
+	if search.SpanKind != trace.SpanKindServer || get.SpanKind != trace.SpanKindClient {
+		t.Errorf("got span kinds %s and %s, want server and client", search.SpanKind, get.SpanKind)
+	}
+	if get.Parent.SpanID() != search.SpanContext.SpanID() {
+		t.Error("omdb.get span isn't a child of the search span")
+	}
+
+	want := "00-" + get.SpanContext.TraceID().String() + "-" + get.SpanContext.SpanID().String() + "-01"
+	if traceparent != want {
+		t.Errorf("OMDb got traceparent %q, want %q", traceparent, want)
+	}
+
+	if got := attr(search, "omdb.query").AsString(); got != "matrix" {
+		t.Errorf("got omdb.query %q, want matrix", got)
+	}
+	if got := attr(search, "omdb.result_count").AsInt64(); got != 2 {
+		t.Errorf("got omdb.result_count %d, want 2", got)
+	}
+	if got := attr(search, "http.status_code").AsInt64(); got != http.StatusOK {
+		t.Errorf("got http.status_code %d, want 200", got)
+	}
+	if got := attr(get, "http.url").AsString(); strings.Contains(got, testKey) {
+		t.Errorf("http.url %q has the API key", got)
+	}
+}
+
+func TestSearchSpanError(t *testing.T) {
+	omdb := newFakeOMDb(t, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	})
+	s, exporter := newTracedApp(t, omdb)
+
+	if w := serveRequest(s, newSearchRequest(`{"title":"matrix"}`)); w.Code != http.StatusBadGateway {
+		t.Fatalf("got status %d, want 502", w.Code)
+	}
+
+	get := spanNamed(t, exporter.GetSpans(), "omdb.get")
+	if get.Status.Code != codes.Error {
+		t.Errorf("got status %s, want error", get.Status.Code)
+	}
+	if strings.Contains(get.Status.Description, testKey) {
+		t.Errorf("status %q has the API key", get.Status.Description)
+	}
+}
+
+func TestSearchWithoutTracerProvider(t *testing.T) {
+	var traceparent string
+	omdb := newFakeOMDb(t, func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+		writeResults(w, 1, resultsFor("tt1")...)
+	})
+	s := newTestApp(t, omdb, Config{})
+
+	// Without a TracerProvider the trace context is still passed on to OMDb.
+	req := newSearchRequest(`{"title":"matrix"}`)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	serveRequest(s, req)
+
+	if !strings.HasPrefix(traceparent, "00-4bf92f3577b34da6a3ce929d0e0e4736-") {
+		t.Errorf("OMDb got traceparent %q, want the client's trace", traceparent)
+	}
+}
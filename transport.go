@@ -0,0 +1,31 @@
+package main
+
+import (
+	"crypto/tls"
+	"log"
+	"net/http"
+)
+
+// SetInsecureSkipVerify makes the OMDBAPI skip verifying the TLS certificate
+// of the OMDb API if skip is true, e.g. for a test proxy with a self-signed
+// certificate. It's only for development: anyone between the service and the
+// API could intercept its requests, including the API key.
+func (o *OMDBAPI) SetInsecureSkipVerify(skip bool) {
+	if !skip {
+		o.client = nil
+		return
+	}
+
+	log.Printf("WARNING: TLS certificate verification is disabled for %s, so requests to it, including the API key, can be intercepted. Never disable it in production.", o.url.Host)
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	o.client = &http.Client{Transport: transport}
+}
+
+// httpClient returns the client that the OMDBAPI sends requests with.
+func (o *OMDBAPI) httpClient() *http.Client {
+	if o.client != nil {
+		return o.client
+	}
+	return http.DefaultClient
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newTLSOMDb starts a fake OMDb with a self-signed certificate that answers
+// every search with a single result, and returns an *OMDBAPI that uses it.
+func newTLSOMDb(t *testing.T) *OMDBAPI {
+	t.Helper()
+	srv := httptest.NewTLSServer(pagedResults(1))
+	t.Cleanup(srv.Close)
+	api, err := InitWithURL(srv.URL+"/?", testKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return api
+}
+
+func TestInsecureSkipVerify(t *testing.T) {
+	log := captureLog(t)
+	api := newTLSOMDb(t)
+	if api.httpClient() != http.DefaultClient {
+		t.Error("got a custom client by default, want the default client")
+	}
+	if _, err := api.Search(context.Background(), &SearchRequest{Title: "alien"}); err == nil || !strings.Contains(err.Error(), "certificate") {
+		t.Errorf("got error %v, want the self-signed certificate rejected", err)
+	}
+
+	api.SetInsecureSkipVerify(true)
+	transport, ok := api.httpClient().Transport.(*http.Transport)
+	if !ok || transport.TLSClientConfig == nil || !transport.TLSClientConfig.InsecureSkipVerify {
+		t.Fatalf("got transport %+v, want one that skips verification", api.httpClient().Transport)
+	}
+	if c := http.DefaultTransport.(*http.Transport).TLSClientConfig; c != nil && c.InsecureSkipVerify {
+		t.Error("the default transport was changed")
+	}
+	if !strings.Contains(log.String(), "WARNING: TLS certificate verification is disabled") {
+		t.Errorf("got log %q, want a warning", log)
+	}
+	if _, err := api.Search(context.Background(), &SearchRequest{Title: "alien"}); err != nil {
+		t.Errorf("got error %v with verification skipped", err)
+	}
+
+	api.SetInsecureSkipVerify(false)
+	if api.httpClient() != http.DefaultClient {
+		t.Error("got a custom client after verification was enabled again")
+	}
+}
+
+func TestInsecureSkipVerifyConfig(t *testing.T) {
+	captureLog(t)
+	omdb := newFakeOMDb(t, pagedResults(1))
+	for _, skip := range []bool{false, true} {
+		s := newTestApp(t, omdb, Config{InsecureSkipVerify: skip})
+		if got := s.omdb.httpClient() != http.DefaultClient; got != skip {
+			t.Errorf("InsecureSkipVerify %t: got a custom client %t", skip, got)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// validTypes are the values of SearchRequest.Type that are accepted. The
+// empty string searches all types. OMDb has experimented with other types, so
+// more can be added with AddTypes. Types in search results aren't checked
+// against it, so unknown types from OMDb are passed through.
+var validTypes = map[string]bool{
+	"":        true,
+	"movie":   true,
+	"series":  true,
+	"episode": true,
+}
+
+// AddTypes adds types to the set that may be searched for, e.g. "game". It
+// isn't safe to call once the server has started.
+func AddTypes(types ...string) {
+	for _, t := range types {
+		if t = strings.TrimSpace(t); t != "" {
+			validTypes[t] = true
+		}
+	}
+}
+
+// SetAllowedTypes restricts searches to the given types. Requests for any
+// other type are rejected, and results of any other type, including ones
+// OMDb returns that aren't known, are filtered out of searches that don't
+// specify a type. All types are allowed if types is empty.
+func (s *SearchApp) SetAllowedTypes(types []string) error {
+	if len(types) == 0 {
+		s.allowedTypes = nil
+		return nil
+	}
+
+	allowed := make(map[string]bool)
+	for _, t := range types {
+		t = strings.TrimSpace(t)
+		if t == "" || !validTypes[t] {
+			return fmt.Errorf("%w: %q", ErrInvalidType, t)
+		}
+		allowed[t] = true
+	}
+
+	s.allowedTypes = allowed
+	return nil
+}
+
+// typeAllowed returns true if t may be searched for. The empty type, which
+// searches all types, is always allowed since disallowed types are filtered
+// out of its results.
+func (s *SearchApp) typeAllowed(t string) bool {
+	return s.allowedTypes == nil || t == "" || s.allowedTypes[t]
+}
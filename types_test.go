@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// mixedTypes are results of every type.
+var mixedTypes = []*SearchResult{
+	{Title: "Alien", IMDBID: "tt1", Type: "movie"},
+	{Title: "Alien Nation", IMDBID: "tt2", Type: "series"},
+	{Title: "Alien Abduction", IMDBID: "tt3", Type: "episode"},
+	{Title: "Alien Game", IMDBID: "tt4", Type: "game"},
+}
+
+// mixedTypesHandler answers searches with the results of mixedTypes that are
+// of the type searched for, or all of them for a search without a type.
+func mixedTypesHandler(w http.ResponseWriter, r *http.Request) {
+	results := mixedTypes
+	if typ := r.URL.Query().Get("type"); typ != "" {
+		results = filterResults(mixedTypes, func(sr *SearchResult) bool { return sr.Type == typ })
+	}
+	writeResults(w, len(results), results...)
+}
+
+func TestAllowedTypes(t *testing.T) {
+	omdb := newFakeOMDb(t, mixedTypesHandler)
+	s := newTestApp(t, omdb, Config{AllowedTypes: []string{"movie", "series"}})
+
+	w := serveRequest(s, newSearchRequest(`{"title":"alien","type":"episode"}`))
+	if w.Code != http.StatusForbidden {
+		t.Errorf("got status %d for a blocked type, want 403: %s", w.Code, w.Body)
+	}
+	if n := omdb.calls(); n != 0 {
+		t.Errorf("got %d calls to OMDb for a blocked type, want none", n)
+	}
+
+	for typ, want := range map[string][]string{
+		"movie":  {"tt1"},
+		"series": {"tt2"},
+		// Results of the other types, including unknown ones, are dropped.
+		"": {"tt1", "tt2"},
+	} {
+		w := serveRequest(s, newSearchRequest(`{"title":"alien","type":"`+typ+`"}`))
+		if got := ids(decodeResults(t, w)); !reflect.DeepEqual(got, want) {
+			t.Errorf("%q: got %v, want %v", typ, got, want)
+		}
+	}
+}
+
+func TestAllowedTypesDefault(t *testing.T) {
+	s := newTestApp(t, newFakeOMDb(t, mixedTypesHandler), Config{})
+
+	for _, typ := range []string{"movie", "series", "episode"} {
+		if w := serveRequest(s, newSearchRequest(`{"title":"alien","type":"`+typ+`"}`)); w.Code != http.StatusOK {
+			t.Errorf("%s: got status %d, want 200", typ, w.Code)
+		}
+	}
+	w := serveRequest(s, newSearchRequest(`{"title":"alien"}`))
+	if got := ids(decodeResults(t, w)); len(got) != len(mixedTypes) {
+		t.Errorf("got %v, want every result", got)
+	}
+}
+
+func TestSetAllowedTypes(t *testing.T) {
+	var s SearchApp
+	if err := s.SetAllowedTypes([]string{"movie", "cartoon"}); !errors.Is(err, ErrInvalidType) {
+		t.Errorf("got error %v, want ErrInvalidType", err)
+	}
+	if err := s.SetAllowedTypes(nil); err != nil || !s.typeAllowed("episode") {
+		t.Errorf("got error %v, want every type allowed", err)
+	}
+}
+
+func TestAllowedTypesRoutes(t *testing.T) {
+	omdb := newFakeOMDb(t, mixedTypesHandler)
+	s := newTestApp(t, omdb, Config{AllowedTypes: []string{"movie"}})
+
+	for _, test := range []struct {
+		method, target, body string
+	}{
+		{"POST", "/search/merge", `{"titles":["alien"],"type":"series"}`},
+		{"POST", "/jobs/search", `{"title":"alien","type":"series"}`},
+		{"GET", "/export?title=alien&type=series", ""},
+		{"GET", "/export/posters?title=alien&type=series", ""},
+	} {
+		if w := send(s, test.method, test.target, test.body); w.Code != http.StatusForbidden {
+			t.Errorf("%s: got status %d, want 403: %s", test.target, w.Code, w.Body)
+		}
+	}
+	if n := omdb.calls(); n != 0 {
+		t.Errorf("got %d calls to OMDb, want none", n)
+	}
+}
+
+func TestAllowedTypesJob(t *testing.T) {
+	s := newTestApp(t, newFakeOMDb(t, mixedTypesHandler), Config{AllowedTypes: []string{"movie", "series"}})
+
+	job := waitForJob(t, s, startJob(t, s, `{"title":"alien"}`))
+	if got, want := ids(job.Results), []string{"tt1", "tt2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if job.Count != 2 {
+		t.Errorf("got a count of %d, want 2", job.Count)
+	}
+}
+
+func TestSearchUnknownType(t *testing.T) {
+	omdb := newFakeOMDb(t, pagedResults(1))
+	s := newTestApp(t, omdb, Config{})
+
+	w := serveRequest(s, newSearchRequest(`{"title":"alien","type":"game"}`))
+	if w.Code != http.StatusUnprocessableEntity || !strings.Contains(w.Body.String(), `invalid type "game"`) {
+		t.Errorf("got %d %q, want a 422", w.Code, w.Body)
+	}
+	if n := omdb.calls(); n != 0 {
+		t.Errorf("got %d calls to OMDb, want none", n)
+	}
+}
+
+func TestAddTypes(t *testing.T) {
+	t.Cleanup(func() { delete(validTypes, "game") })
+	AddTypes(" game ", "")
+
+	omdb := newFakeOMDb(t, mixedTypesHandler)
+	s := newTestApp(t, omdb, Config{})
+
+	w := serveRequest(s, newSearchRequest(`{"title":"alien","type":"game"}`))
+	if got, want := ids(decodeResults(t, w)), []string{"tt4"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got := omdb.query(0).Get("type"); got != "game" {
+		t.Errorf("got type=%q sent to OMDb, want game", got)
+	}
+	if err := s.SetAllowedTypes([]string{"movie", "game"}); err != nil {
+		t.Errorf("got error %v allowing an added type", err)
+	}
+}
+
+func TestUnknownResultType(t *testing.T) {
+	api := newTestAPI(t, newFakeOMDb(t, func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"Search":[{"Title":"Alien: Isolation","Year":"2014","imdbID":"tt2937276","Type":"game","Poster":"N/A"}],"totalResults":"1","Response":"True"}`)
+	}))
+
+	results, err := api.Search(context.Background(), &SearchRequest{Title: "alien"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Type != "game" || results[0].IMDBID != "tt2937276" {
+		t.Errorf("got %+v, want the game", results)
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Validator checks a *SearchRequest before it's sent upstream, returning an
+// error describing what's wrong with it.
+type Validator func(*SearchRequest) error
+
+// ValidationError is every error returned by the validators for a single
+// request. The handlers respond to it with a 422, or a 400 if it includes
+// ErrTitleTooShort.
+type ValidationError struct {
+	Errors []error
+}
+
+func (e *ValidationError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Unwrap returns the errors, so that errors.Is and errors.As check each one.
+func (e *ValidationError) Unwrap() []error {
+	return e.Errors
+}
+
+// maxIDPatternLength is the longest IDPattern, in bytes, that is accepted.
+const maxIDPatternLength = 64
+
+// defaultMinTitleLength is the default minimum number of characters in a
+// search title. Single letters match too many titles for OMDb to return them,
+// so they'd only use up the quota.
+const defaultMinTitleLength = 2
+
+// ValidateTitle returns ErrEmptyTitle if r doesn't have a title.
+func ValidateTitle(r *SearchRequest) error {
+	if strings.TrimSpace(r.Title) == "" {
+		return ErrEmptyTitle
+	}
+	return nil
+}
+
+// ValidateType returns an error wrapping ErrInvalidType if r has a type that
+// isn't supported.
+func ValidateType(r *SearchRequest) error {
+	if !validTypes[r.Type] {
+		return fmt.Errorf("%w: %q", ErrInvalidType, r.Type)
+	}
+	return nil
+}
+
+// ValidateYear returns an error wrapping ErrInvalidYear if r has a release
+// year that isn't four digits.
+func ValidateYear(r *SearchRequest) error {
+	if r.ReleaseYear != "" && !yearPattern.MatchString(r.ReleaseYear) {
+		return fmt.Errorf("%w: %q", ErrInvalidYear, r.ReleaseYear)
+	}
+	return nil
+}
+
+// ValidateMinYear returns an error wrapping ErrInvalidMinYear if r has a
+// minimum year that's negative or later than its release year.
+func ValidateMinYear(r *SearchRequest) error {
+	if r.MinYear < 0 {
+		return fmt.Errorf("%w: %d", ErrInvalidMinYear, r.MinYear)
+	}
+	if year, err := strconv.Atoi(r.ReleaseYear); err == nil && r.MinYear > year {
+		return fmt.Errorf("%w: %d is later than %d", ErrInvalidMinYear, r.MinYear, year)
+	}
+	return nil
+}
+
+// ValidatePage returns an error wrapping ErrInvalidPage if r asks for a page
+// that OMDb won't return.
+func ValidatePage(r *SearchRequest) error {
+	if r.Page < 0 || r.Page > maxPage {
+		return fmt.Errorf("%w: %d", ErrInvalidPage, r.Page)
+	}
+	return nil
+}
+
+// ValidateIDPattern returns an error wrapping ErrInvalidIDPattern if r has an
+// IMDb ID pattern that's too long or isn't a valid regular expression.
+func ValidateIDPattern(r *SearchRequest) error {
+	if len(r.IDPattern) > maxIDPatternLength {
+		return fmt.Errorf("%w: longer than %d characters", ErrInvalidIDPattern, maxIDPatternLength)
+	}
+	if _, err := regexp.Compile(r.IDPattern); err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidIDPattern, err)
+	}
+	return nil
+}
+
+// validateTitleLength returns an error wrapping ErrTitleTooLong if r has a
+// title longer than the configured maximum.
+func (s *SearchApp) validateTitleLength(r *SearchRequest) error {
+	if n := utf8.RuneCountInString(r.Title); n > s.maxTitleLength {
+		return fmt.Errorf("%w: %d characters", ErrTitleTooLong, n)
+	}
+	return nil
+}
+
+// validateMinTitleLength returns an error wrapping ErrTitleTooShort if r has a
+// title shorter than the configured minimum, ignoring surrounding spaces. An
+// empty title is left to ValidateTitle.
+func (s *SearchApp) validateMinTitleLength(r *SearchRequest) error {
+	n := utf8.RuneCountInString(strings.TrimSpace(r.Title))
+	if n > 0 && n < s.minTitleLength {
+		return fmt.Errorf("%w: %d characters", ErrTitleTooShort, n)
+	}
+	return nil
+}
+
+// AddValidator adds v to the validators run for each search request, after
+// the built-in ones.
+func (s *SearchApp) AddValidator(v Validator) {
+	if v != nil {
+		s.validators = append(s.validators, v)
+	}
+}
+
+// validate runs all of the validators against r, rather than stopping at the
+// first failure, so that every problem can be reported at once. It returns a
+// *ValidationError if any of them fail.
+func (s *SearchApp) validate(r *SearchRequest) error {
+	var errs []error
+	for _, v := range s.validators {
+		if err := v(r); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+	return &ValidationError{Errors: errs}
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMaxTitleLength(t *testing.T) {
+	omdb := newFakeOMDb(t, func(w http.ResponseWriter, r *http.Request) {
+		writeResults(w, 1, resultsFor("tt1")...)
+	})
+	s := newTestApp(t, omdb, Config{MaxTitleLength: 5})
+
+	for _, test := range []struct {
+		title string
+		want  int
+	}{
+		{"abcde", http.StatusOK},
+		// The limit is in characters rather than bytes.
+		{"ábcdé", http.StatusOK},
+		{"abcdef", http.StatusUnprocessableEntity},
+	} {
+		w := serveRequest(s, newSearchRequest(`{"title":"`+test.title+`"}`))
+		if w.Code != test.want {
+			t.Errorf("%q: got status %d, want %d: %s", test.title, w.Code, test.want, w.Body)
+		}
+		if test.want != http.StatusOK && !strings.Contains(w.Body.String(), "title must be at most 5 characters") {
+			t.Errorf("%q: got body %q, want the max length", test.title, w.Body)
+		}
+	}
+	if n := omdb.calls(); n != 2 {
+		t.Errorf("got %d calls to OMDb, want 2", n)
+	}
+}
+
+func TestMaxTitleLengthDefault(t *testing.T) {
+	omdb := newFakeOMDb(t, func(w http.ResponseWriter, r *http.Request) {
+		writeResults(w, 1, resultsFor("tt1")...)
+	})
+	s := newTestApp(t, omdb, Config{})
+
+	if w := serveRequest(s, newSearchRequest(`{"title":"`+strings.Repeat("a", 256)+`"}`)); w.Code != http.StatusOK {
+		t.Errorf("got status %d for 256 characters, want 200", w.Code)
+	}
+	if w := serveRequest(s, newSearchRequest(`{"title":"`+strings.Repeat("a", 257)+`"}`)); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("got status %d for 257 characters, want 422", w.Code)
+	}
+}
+
+func TestMinTitleLength(t *testing.T) {
+	omdb := newFakeOMDb(t, func(w http.ResponseWriter, r *http.Request) {
+		writeResults(w, 1, resultsFor("tt1")...)
+	})
+	s := newTestApp(t, omdb, Config{MinTitleLength: 3})
+
+	for _, test := range []struct {
+		title string
+		want  int
+	}{
+		{"ab", http.StatusBadRequest},
+		// Surrounding spaces don't count.
+		{"  ab  ", http.StatusBadRequest},
+		{"abc", http.StatusOK},
+		// The limit is in characters rather than bytes.
+		{"éé", http.StatusBadRequest},
+		{"ébc", http.StatusOK},
+	} {
+		w := serveRequest(s, newSearchRequest(`{"title":"`+test.title+`"}`))
+		if w.Code != test.want {
+			t.Errorf("%q: got status %d, want %d: %s", test.title, w.Code, test.want, w.Body)
+		}
+		if test.want != http.StatusOK && !strings.Contains(w.Body.String(), "title must be at least 3 characters") {
+			t.Errorf("%q: got body %q, want the min length", test.title, w.Body)
+		}
+	}
+	if n := omdb.calls(); n != 2 {
+		t.Errorf("got %d calls to OMDb, want 2", n)
+	}
+
+	// Other failures are still reported along with it.
+	w := serveRequest(s, newSearchRequest(`{"title":"ab","page":101}`))
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "page must be between 1 and 100") {
+		t.Errorf("got %d %q, want a 400 with every message", w.Code, w.Body)
+	}
+}
+
+func TestMinTitleLengthDefault(t *testing.T) {
+	omdb := newFakeOMDb(t, func(w http.ResponseWriter, r *http.Request) {
+		writeResults(w, 1, resultsFor("tt1")...)
+	})
+
+	s := newTestApp(t, omdb, Config{})
+	if w := serveRequest(s, newSearchRequest(`{"title":"a"}`)); w.Code != http.StatusBadRequest {
+		t.Errorf("got status %d for 1 character, want 400", w.Code)
+	}
+	if w := serveRequest(s, newSearchRequest(`{"title":"ab"}`)); w.Code != http.StatusOK {
+		t.Errorf("got status %d for 2 characters, want 200", w.Code)
+	}
+	// An empty title is reported as empty rather than too short.
+	if w := serveRequest(s, newSearchRequest(`{"title":" "}`)); strings.Contains(w.Body.String(), "at least") {
+		t.Errorf("got body %q for an empty title, want it reported as empty", w.Body)
+	}
+
+	s = newTestApp(t, omdb, Config{MinTitleLength: 1})
+	if w := serveRequest(s, newSearchRequest(`{"title":"a"}`)); w.Code != http.StatusOK {
+		t.Errorf("got status %d for 1 character with a minimum of 1, want 200", w.Code)
+	}
+}
+
+func TestMinTitleLengthConfig(t *testing.T) {
+	for _, cfg := range []Config{
+		{MinTitleLength: -1},
+		{MinTitleLength: 10, MaxTitleLength: 5},
+	} {
+		cfg.Key = testKey
+		if _, err := NewSearchAppWithConfig(cfg); err == nil {
+			t.Errorf("%+v: got no error", cfg)
+		}
+	}
+}
+
+func TestValidatePage(t *testing.T) {
+	for page, valid := range map[int]bool{-1: false, 0: true, 1: true, maxPage: true, maxPage + 1: false} {
+		err := ValidatePage(&SearchRequest{Title: "alien", Page: page})
+		if valid != (err == nil) {
+			t.Errorf("page %d: got error %v, want valid %t", page, err, valid)
+		}
+		if err != nil && !errors.Is(err, ErrInvalidPage) {
+			t.Errorf("page %d: got error %v, want ErrInvalidPage", page, err)
+		}
+	}
+}
+
+func TestSearchInvalidPage(t *testing.T) {
+	omdb := newFakeOMDb(t, pagedResults(1))
+	s := newTestApp(t, omdb, Config{})
+
+	for _, page := range []string{"-1", "101", "99999"} {
+		w := serveRequest(s, newSearchRequest(`{"title":"alien","page":`+page+`}`))
+		if w.Code != http.StatusUnprocessableEntity || !strings.Contains(w.Body.String(), "page must be between 1 and 100") {
+			t.Errorf("page %s: got %d %q, want a 422 for the page", page, w.Code, w.Body)
+		}
+	}
+	if n := omdb.calls(); n != 0 {
+		t.Errorf("got %d calls to OMDb, want none", n)
+	}
+
+	// Zero is the first page.
+	if w := serveRequest(s, newSearchRequest(`{"title":"alien","page":0}`)); w.Code != http.StatusOK {
+		t.Errorf("page 0: got status %d, want 200", w.Code)
+	}
+	if q := omdb.query(0); q.Has("page") {
+		t.Errorf("page 0: got query %v, want no page", q)
+	}
+}
+
+func TestValidators(t *testing.T) {
+	for _, test := range []struct {
+		v     Validator
+		r     SearchRequest
+		valid bool
+		err   error
+	}{
+		{ValidateTitle, SearchRequest{Title: "alien"}, true, nil},
+		{ValidateTitle, SearchRequest{Title: "  "}, false, ErrEmptyTitle},
+		{ValidateType, SearchRequest{Type: "series"}, true, nil},
+		{ValidateType, SearchRequest{Type: "film"}, false, ErrInvalidType},
+		{ValidateYear, SearchRequest{ReleaseYear: "1979"}, true, nil},
+		{ValidateYear, SearchRequest{ReleaseYear: "79"}, false, ErrInvalidYear},
+		{ValidateMinYear, SearchRequest{MinYear: 1979, ReleaseYear: "1986"}, true, nil},
+		{ValidateMinYear, SearchRequest{MinYear: 1990, ReleaseYear: "1986"}, false, ErrInvalidMinYear},
+		{ValidateMinYear, SearchRequest{MinYear: -1}, false, ErrInvalidMinYear},
+	} {
+		err := test.v(&test.r)
+		if test.valid != (err == nil) || (err != nil && !errors.Is(err, test.err)) {
+			t.Errorf("%+v: got error %v, want %v", test.r, err, test.err)
+		}
+	}
+}
+
+func TestValidationCollectsErrors(t *testing.T) {
+	omdb := newFakeOMDb(t, pagedResults(1))
+	s := newTestApp(t, omdb, Config{})
+
+	err := s.validate(&SearchRequest{Title: "", Type: "film", ReleaseYear: "79"})
+	var verr *ValidationError
+	if !errors.As(err, &verr) || len(verr.Errors) != 3 {
+		t.Fatalf("got error %v, want all three failures", err)
+	}
+	for _, want := range []error{ErrEmptyTitle, ErrInvalidType, ErrInvalidYear} {
+		if !errors.Is(err, want) {
+			t.Errorf("got error %v, want %v", err, want)
+		}
+	}
+
+	w := serveRequest(s, newSearchRequest(`{"title":"","type":"film","release_year":"79"}`))
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("got status %d, want 422", w.Code)
+	}
+	if lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n"); len(lines) != 3 {
+		t.Errorf("got %q, want a line for each failure", w.Body)
+	}
+	if n := omdb.calls(); n != 0 {
+		t.Errorf("got %d calls to OMDb, want none", n)
+	}
+}
+
+func TestAddValidator(t *testing.T) {
+	errNoSequels := errors.New("sequels aren't searchable")
+	omdb := newFakeOMDb(t, pagedResults(1))
+	s := newTestApp(t, omdb, Config{})
+
+	var validated []string
+	s.AddValidator(func(r *SearchRequest) error {
+		validated = append(validated, r.Title)
+		if strings.HasSuffix(r.Title, " 2") {
+			return errNoSequels
+		}
+		return nil
+	})
+	s.AddValidator(nil)
+
+	if w := serveRequest(s, newSearchRequest(`{"title":"alien"}`)); w.Code != http.StatusOK {
+		t.Errorf("got status %d, want 200", w.Code)
+	}
+	w := serveRequest(s, newSearchRequest(`{"title":"aliens 2"}`))
+	if w.Code != http.StatusUnprocessableEntity || !strings.Contains(w.Body.String(), errNoSequels.Error()) {
+		t.Errorf("got %d %q, want a 422 from the custom validator", w.Code, w.Body)
+	}
+
+	// It runs after the built-in validators, alongside them.
+	err := s.validate(&SearchRequest{Title: "aliens 2", Type: "film"})
+	if !errors.Is(err, errNoSequels) || !errors.Is(err, ErrInvalidType) {
+		t.Errorf("got error %v, want both failures", err)
+	}
+	if want := []string{"alien", "aliens 2", "aliens 2"}; !reflect.DeepEqual(validated, want) {
+		t.Errorf("validated %q, want %q", validated, want)
+	}
+	if n := omdb.calls(); n != 1 {
+		t.Errorf("got %d calls to OMDb, want 1", n)
+	}
+}
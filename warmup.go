@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+// readWarmupFile returns the search titles in the warmup file at path, one
+// per line. Blank lines and lines starting with # are skipped.
+func readWarmupFile(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var titles []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		titles = append(titles, line)
+	}
+	return titles, scanner.Err()
+}
+
+// Warmup searches for each of the titles to populate the cache, waiting
+// interval between searches so that it doesn't use up the upstream rate limit.
+// It stops early if the quota is exceeded or ctx is done.
+func (s *SearchApp) Warmup(ctx context.Context, titles []string, interval time.Duration) {
+	if s.cache == nil {
+		log.Println("warmup: caching is disabled, skipping warmup")
+		return
+	}
+
+	var failed int
+	for i, title := range titles {
+		if i > 0 {
+			if err := sleep(ctx, interval); err != nil {
+				log.Printf("warmup: stopped after %d of %d titles: %s", i, len(titles), errorMessage(err))
+				return
+			}
+		}
+
+		if _, err := s.search(ctx, NewSearchRequest(title)); err != nil {
+			failed++
+			log.Printf("warmup: searching for %q failed: %s", title, errorMessage(err))
+			if errors.Is(err, ErrQuotaExceeded) {
+				log.Printf("warmup: stopped after %d of %d titles", i+1, len(titles))
+				return
+			}
+		}
+
+		if (i+1)%10 == 0 {
+			log.Printf("warmup: searched for %d of %d titles", i+1, len(titles))
+		}
+	}
+
+	log.Printf("warmup: finished, %d of %d searches failed", failed, len(titles))
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// ErrorNotification is the JSON payload POSTed to the webhook URL.
+type ErrorNotification struct {
+	ErrorType string `json:"error_type"`
+	Count     int    `json:"count"`
+	Window    string `json:"window"`
+	LastError string `json:"last_error"`
+}
+
+// ErrorNotifier keeps track of upstream errors and fires a webhook when the
+// number of errors of a single type crosses a threshold within a window.
+type ErrorNotifier struct {
+	url       string
+	threshold int
+	window    time.Duration
+	client    *http.Client
+
+	mu     sync.Mutex
+	events map[string][]time.Time
+}
+
+// NewErrorNotifier returns a new *ErrorNotifier that POSTs to webhookURL. Each
+// webhook request is bounded by timeout.
+func NewErrorNotifier(webhookURL string, threshold int, window, timeout time.Duration) *ErrorNotifier {
+	return &ErrorNotifier{
+		url:       webhookURL,
+		threshold: threshold,
+		window:    window,
+		client:    &http.Client{Timeout: timeout},
+		events:    make(map[string][]time.Time),
+	}
+}
+
+// errorType returns a short, stable name for the kind of error that err is.
+func errorType(err error) string {
+	var (
+		statusErr *StatusError
+		syntaxErr *json.SyntaxError
+		typeErr   *json.UnmarshalTypeError
+		urlErr    *url.Error
+		apiErr    *APIError
+	)
+
+	switch {
+	case errors.Is(err, ErrQuotaExceeded):
+		return "quota_exceeded"
+	case errors.Is(err, ErrInvalidAPIKey):
+		return "invalid_api_key"
+	case errors.As(err, &statusErr):
+		return fmt.Sprintf("status_%d", statusErr.StatusCode)
+	case errors.As(err, &syntaxErr), errors.As(err, &typeErr):
+		return "invalid_response"
+	case errors.As(err, &urlErr), errors.Is(err, ErrConnectionDropped):
+		return "connection"
+	case errors.As(err, &apiErr):
+		return "omdb_error"
+	}
+	return "unknown"
+}
+
+// Record counts err against its error type and fires the webhook if the
+// threshold has been reached. The count for that type is reset after firing.
+// It is safe to call Record on a nil *ErrorNotifier.
+func (n *ErrorNotifier) Record(err error) {
+	if n == nil || err == nil {
+		return
+	}
+
+	t := errorType(err)
+	now := time.Now()
+
+	n.mu.Lock()
+	var recent []time.Time
+	for _, ts := range n.events[t] {
+		if now.Sub(ts) < n.window {
+			recent = append(recent, ts)
+		}
+	}
+	recent = append(recent, now)
+
+	if len(recent) < n.threshold {
+		n.events[t] = recent
+		n.mu.Unlock()
+		return
+	}
+	delete(n.events, t)
+	n.mu.Unlock()
+
+	go n.send(&ErrorNotification{
+		ErrorType: t,
+		Count:     len(recent),
+		Window:    n.window.String(),
+		LastError: errorMessage(err),
+	})
+}
+
+// errorMessage returns the message of err that is safe to report outside of
+// the service. The *url.Error message contains the request URL, which
+// includes the API key, so only the underlying error is reported for it.
+func errorMessage(err error) string {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return urlErr.Err.Error()
+	}
+	return err.Error()
+}
+
+// send POSTs the notification to the webhook. Failures are logged and
+// otherwise ignored.
+func (n *ErrorNotifier) send(notification *ErrorNotification) {
+	b, err := json.Marshal(notification)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	resp, err := n.client.Post(n.url, "application/json", bytes.NewReader(b))
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		log.Printf("webhook returned status %d", resp.StatusCode)
+	}
+}